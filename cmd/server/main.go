@@ -1,28 +1,196 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
+	"github.com/astanx/anime_api/internal/analytics"
+	"github.com/astanx/anime_api/internal/cache"
 	"github.com/astanx/anime_api/internal/config"
 	"github.com/astanx/anime_api/internal/db"
+	"github.com/astanx/anime_api/internal/logging"
+	"github.com/astanx/anime_api/internal/metrics"
+	"github.com/astanx/anime_api/internal/ratelimit"
+	"github.com/astanx/anime_api/internal/repository"
 	"github.com/astanx/anime_api/internal/router"
+	"github.com/astanx/anime_api/internal/tracing"
+	"github.com/astanx/anime_api/internal/upstream"
 )
 
 func main() {
+	logging.Setup(os.Stdout, "info")
+
 	cfg, err := config.LoadConfig()
 	if err != nil {
-		log.Fatalf("failed to load config: %v", err)
+		fatal("failed to load config", err)
+	}
+	logging.Setup(os.Stdout, cfg.LogLevel)
+
+	databases, err := db.Connect(cfg)
+	if err != nil {
+		fatal("failed to connect databases", err)
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(databases, os.Args[2:])
+		if err := databases.Close(); err != nil {
+			slog.Error("failed to close databases", "error", err)
+		}
+		return
+	}
+
+	if cfg.AutoMigrate {
+		if err := db.MigratePostgres(databases.Postgres, db.MigrateUp, 0); err != nil {
+			fatal("failed to migrate postgres", err)
+		}
+		if databases.ClickHouse != nil {
+			if err := db.MigrateClickHouse(databases.ClickHouse, db.MigrateUp, 0); err != nil {
+				slog.Error("failed to migrate clickhouse", "error", err)
+			}
+		}
+	}
+
+	if cfg.MappingFile != "" {
+		count, err := repository.NewMappingRepo(databases).SeedFromFile(context.Background(), cfg.MappingFile)
+		if err != nil {
+			slog.Error("failed to seed anime mappings", "error", err)
+		} else {
+			slog.Info("seeded anime mappings", "count", count, "file", cfg.MappingFile)
+		}
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		fatal("failed to set up tracing", err)
 	}
 
-	databases, err := db.Connect(cfg.PostgresDSN, cfg.ClickhouseUser, cfg.ClickhousePass, cfg.ClickhouseHost, cfg.RedisURL)
+	upstreamOpts, err := upstreamOptions(cfg)
 	if err != nil {
-		log.Fatalf("failed to connect databases: %v", err)
+		fatal("invalid upstream rate limit", err)
+	}
+	upstream.SetDefault(upstream.NewClient(upstreamOpts))
+
+	metrics.RegisterDB(databases.Postgres, "postgres")
+	if sink, ok := databases.Analytics.(*analytics.BufferedSink); ok {
+		metrics.RegisterAnalytics(sink)
+	}
+
+	responseCache := cache.New(databases.Redis, cfg.Cache, cfg.MemoryCacheSize)
+	r := router.NewRouter(databases, cfg, responseCache)
+
+	srv := &http.Server{
+		Addr:              cfg.ServerAddress,
+		Handler:           r,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("starting server", "addr", cfg.ServerAddress)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
+
+	var failed bool
+	select {
+	case err := <-serverErr:
+		slog.Error("server failed", "error", err)
+		failed = true
+	case <-ctx.Done():
+		slog.Info("shutting down")
 	}
+	stop()
 
-	r := router.NewRouter(databases)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
 
-	log.Printf("starting server on %s", cfg.ServerAddress)
-	if err := r.Run(cfg.ServerAddress); err != nil {
-		log.Fatalf("server failed: %v", err)
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("failed to drain requests", "error", err)
 	}
+	if err := responseCache.Close(shutdownCtx); err != nil {
+		slog.Error("failed to wait for cache refreshes", "error", err)
+	}
+	if err := databases.Close(); err != nil {
+		slog.Error("failed to close databases", "error", err)
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("failed to flush traces", "error", err)
+	}
+	slog.Info("server stopped")
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// upstreamOptions sets per-provider timeouts and rate limits. The Consumet
+// and Prowlarr instances run on onrender.com and can take a while to wake up.
+func upstreamOptions(cfg *config.Config) (upstream.Options, error) {
+	opts := upstream.DefaultOptions()
+
+	hosts := []struct {
+		name      string
+		host      string
+		timeout   time.Duration
+		retries   int
+		rateLimit string
+	}{
+		{"consumet", upstream.Host(cfg.Upstream.ConsumetURL), 30 * time.Second, 2, cfg.ConsumetRateLimit},
+		{"prowlarr", upstream.Host(cfg.Upstream.ProwlarrURL), 20 * time.Second, 1, cfg.ProwlarrRateLimit},
+		{"jikan", upstream.Host(cfg.Upstream.JikanURL), 10 * time.Second, 3, cfg.JikanRateLimit},
+		{"anilibria", upstream.Host(cfg.Upstream.AnilibriaURL), 10 * time.Second, 2, cfg.AnilibriaRateLimit},
+	}
+	for _, h := range hosts {
+		rates, err := ratelimit.ParseRates(h.rateLimit)
+		if err != nil {
+			return upstream.Options{}, fmt.Errorf("%s: %w", h.host, err)
+		}
+		opts.Hosts[h.host] = upstream.HostPolicy{Name: h.name, Timeout: h.timeout, MaxRetries: h.retries, RateLimit: rates}
+	}
+
+	return opts, nil
+}
+
+// runMigrate handles `server migrate [up|down] [steps]`. Down rolls back one
+// migration unless a step count is given; up always applies everything.
+func runMigrate(databases *db.DB, args []string) {
+	direction := db.MigrateUp
+	if len(args) > 0 {
+		direction = db.MigrationDirection(args[0])
+	}
+
+	steps := 1
+	if len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			fatal("invalid migration steps", err)
+		}
+		steps = n
+	}
+	if direction == db.MigrateUp {
+		steps = 0
+	}
+
+	if err := databases.Migrate(direction, steps); err != nil {
+		fatal("migration failed", err)
+	}
+	slog.Info("migrations complete", "direction", direction)
+}
+
+// fatal logs err and exits, like log.Fatal.
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
@@ -0,0 +1,154 @@
+package analytics
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// BatchWriter persists a batch of events in one go.
+type BatchWriter interface {
+	WriteBatch(ctx context.Context, events []Event) error
+	Name() string
+}
+
+type BufferOptions struct {
+	// BufferSize bounds the events waiting to be written. Events recorded
+	// while the buffer is full are dropped.
+	BufferSize    int
+	BatchSize     int
+	FlushInterval time.Duration
+	// WriteTimeout bounds a single batch write, including the final flush on
+	// Close.
+	WriteTimeout time.Duration
+}
+
+// Stats are running totals since startup.
+type Stats struct {
+	Written int64 `json:"written"`
+	Dropped int64 `json:"dropped"`
+	Failed  int64 `json:"failed"`
+}
+
+// BufferedSink queues events in memory and writes them in batches from a
+// single goroutine, flushing when a batch fills up or FlushInterval passes.
+// Record never blocks.
+type BufferedSink struct {
+	writer BatchWriter
+	opts   BufferOptions
+	events chan Event
+
+	mu     sync.RWMutex
+	closed bool
+	done   chan struct{}
+
+	written atomic.Int64
+	dropped atomic.Int64
+	failed  atomic.Int64
+}
+
+func NewBufferedSink(writer BatchWriter, opts BufferOptions) *BufferedSink {
+	s := &BufferedSink{
+		writer: writer,
+		opts:   opts,
+		events: make(chan Event, opts.BufferSize),
+		done:   make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+func (s *BufferedSink) Name() string {
+	return s.writer.Name()
+}
+
+func (s *BufferedSink) Record(_ context.Context, event Event) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		s.dropped.Add(1)
+		return nil
+	}
+	select {
+	case s.events <- event:
+	default:
+		s.dropped.Add(1)
+	}
+	return nil
+}
+
+func (s *BufferedSink) Stats() Stats {
+	return Stats{
+		Written: s.written.Load(),
+		Dropped: s.dropped.Load(),
+		Failed:  s.failed.Load(),
+	}
+}
+
+// Close stops accepting events and flushes everything still buffered.
+func (s *BufferedSink) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.events)
+	s.mu.Unlock()
+
+	<-s.done
+	stats := s.Stats()
+	slog.Info("analytics writer stopped", "written", stats.Written, "dropped", stats.Dropped, "failed", stats.Failed)
+	return nil
+}
+
+func (s *BufferedSink) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.opts.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Event, 0, s.opts.BatchSize)
+	var reportedDrops int64
+	for {
+		select {
+		case event, ok := <-s.events:
+			if !ok {
+				s.flush(batch)
+				return
+			}
+			batch = append(batch, event)
+			if len(batch) >= s.opts.BatchSize {
+				s.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			s.flush(batch)
+			batch = batch[:0]
+
+			if dropped := s.dropped.Load(); dropped > reportedDrops {
+				slog.Warn("analytics buffer full", "dropped", dropped-reportedDrops)
+				reportedDrops = dropped
+			}
+		}
+	}
+}
+
+func (s *BufferedSink) flush(batch []Event) {
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.opts.WriteTimeout)
+	defer cancel()
+
+	if err := s.writer.WriteBatch(ctx, batch); err != nil {
+		s.failed.Add(int64(len(batch)))
+		slog.ErrorContext(ctx, "analytics batch failed", "events", len(batch), "error", err)
+		return
+	}
+	s.written.Add(int64(len(batch)))
+}
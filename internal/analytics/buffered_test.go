@@ -0,0 +1,133 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeWriter hands every batch it is given to batches. If release is set,
+// WriteBatch waits on it before returning.
+type fakeWriter struct {
+	batches chan []Event
+	release chan struct{}
+	err     error
+}
+
+func newFakeWriter() *fakeWriter {
+	return &fakeWriter{batches: make(chan []Event, 16)}
+}
+
+func (w *fakeWriter) WriteBatch(_ context.Context, events []Event) error {
+	w.batches <- append([]Event(nil), events...)
+	if w.release != nil {
+		<-w.release
+	}
+	return w.err
+}
+
+func (w *fakeWriter) Name() string { return "fake" }
+
+// next waits for the next batch written.
+func (w *fakeWriter) next(t *testing.T) []Event {
+	t.Helper()
+
+	select {
+	case batch := <-w.batches:
+		return batch
+	case <-time.After(time.Second):
+		require.FailNow(t, "no batch written")
+		return nil
+	}
+}
+
+func event(query string) Event {
+	return SearchEvent{Query: query}
+}
+
+func TestBufferedSinkFlushesFullBatch(t *testing.T) {
+	w := newFakeWriter()
+	s := NewBufferedSink(w, BufferOptions{BufferSize: 10, BatchSize: 2, FlushInterval: time.Hour, WriteTimeout: time.Second})
+	defer s.Close()
+	ctx := context.Background()
+
+	require.NoError(t, s.Record(ctx, event("a")))
+	require.NoError(t, s.Record(ctx, event("b")))
+	require.NoError(t, s.Record(ctx, event("c")))
+
+	assert.Equal(t, []Event{event("a"), event("b")}, w.next(t))
+	assert.Empty(t, w.batches)
+}
+
+func TestBufferedSinkFlushesOnInterval(t *testing.T) {
+	w := newFakeWriter()
+	s := NewBufferedSink(w, BufferOptions{BufferSize: 10, BatchSize: 100, FlushInterval: 10 * time.Millisecond, WriteTimeout: time.Second})
+	defer s.Close()
+
+	require.NoError(t, s.Record(context.Background(), event("a")))
+
+	assert.Equal(t, []Event{event("a")}, w.next(t))
+}
+
+func TestBufferedSinkFlushesOnClose(t *testing.T) {
+	w := newFakeWriter()
+	s := NewBufferedSink(w, BufferOptions{BufferSize: 10, BatchSize: 100, FlushInterval: time.Hour, WriteTimeout: time.Second})
+	ctx := context.Background()
+
+	require.NoError(t, s.Record(ctx, event("a")))
+	require.NoError(t, s.Record(ctx, event("b")))
+	require.NoError(t, s.Close())
+
+	assert.Equal(t, []Event{event("a"), event("b")}, w.next(t))
+	assert.Equal(t, Stats{Written: 2}, s.Stats())
+}
+
+func TestBufferedSinkDropsWhenFull(t *testing.T) {
+	w := newFakeWriter()
+	w.release = make(chan struct{})
+	s := NewBufferedSink(w, BufferOptions{BufferSize: 1, BatchSize: 1, FlushInterval: time.Hour, WriteTimeout: time.Second})
+	ctx := context.Background()
+
+	// The first event is taken out of the buffer and held up in the writer,
+	// the second fills the buffer and the third has nowhere to go.
+	require.NoError(t, s.Record(ctx, event("a")))
+	assert.Equal(t, []Event{event("a")}, w.next(t))
+	require.NoError(t, s.Record(ctx, event("b")))
+	require.NoError(t, s.Record(ctx, event("c")))
+	assert.Equal(t, int64(1), s.Stats().Dropped)
+
+	close(w.release)
+	require.NoError(t, s.Close())
+	assert.Equal(t, []Event{event("b")}, w.next(t))
+	assert.Equal(t, Stats{Written: 2, Dropped: 1}, s.Stats())
+}
+
+func TestBufferedSinkCountsFailures(t *testing.T) {
+	w := newFakeWriter()
+	w.err = errors.New("write failed")
+	s := NewBufferedSink(w, BufferOptions{BufferSize: 10, BatchSize: 100, FlushInterval: time.Hour, WriteTimeout: time.Second})
+	ctx := context.Background()
+
+	require.NoError(t, s.Record(ctx, event("a")))
+	require.NoError(t, s.Record(ctx, event("b")))
+	require.NoError(t, s.Close())
+
+	assert.Equal(t, Stats{Failed: 2}, s.Stats())
+}
+
+func TestBufferedSinkRecordAfterClose(t *testing.T) {
+	w := newFakeWriter()
+	s := NewBufferedSink(w, BufferOptions{BufferSize: 10, BatchSize: 100, FlushInterval: time.Hour, WriteTimeout: time.Second})
+	ctx := context.Background()
+
+	require.NoError(t, s.Close())
+	require.NoError(t, s.Record(ctx, event("a")))
+	require.NoError(t, s.Close())
+
+	assert.Empty(t, w.batches)
+	assert.Equal(t, Stats{Dropped: 1}, s.Stats())
+}
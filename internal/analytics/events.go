@@ -0,0 +1,43 @@
+package analytics
+
+import "time"
+
+// Event is a single analytics row. Each type maps to one ClickHouse table.
+type Event interface {
+	Table() string
+}
+
+type SearchEvent struct {
+	Query      string    `json:"query"`
+	Type       string    `json:"type"`
+	Results    int       `json:"results"`
+	SearchedAt time.Time `json:"searched_at"`
+}
+
+func (SearchEvent) Table() string { return "search_analytics" }
+
+type DeviceEvent struct {
+	DeviceID  string    `json:"device_id"`
+	From      string    `json:"from"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (DeviceEvent) Table() string { return "device_analytics" }
+
+// CollectionEvent adjusts the number of devices holding an anime in a
+// collection type; Count is +1 or -1.
+type CollectionEvent struct {
+	AnimeID string `json:"anime_id"`
+	Type    string `json:"type"`
+	Count   int    `json:"count"`
+}
+
+func (CollectionEvent) Table() string { return "collection_analytics" }
+
+// FavouriteEvent adjusts an anime's favourite count by Favourites.
+type FavouriteEvent struct {
+	AnimeID    string `json:"anime_id"`
+	Favourites int    `json:"favourites"`
+}
+
+func (FavouriteEvent) Table() string { return "favourite_analytics" }
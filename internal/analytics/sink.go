@@ -0,0 +1,158 @@
+package analytics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	clickhouse "github.com/ClickHouse/clickhouse-go/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Sink stores analytics events. Failures are never fatal to the request that
+// produced the event, so callers only log returned errors.
+type Sink interface {
+	Record(ctx context.Context, event Event) error
+	// Name identifies the backend on the readiness endpoint.
+	Name() string
+	Close() error
+}
+
+var tracer = otel.Tracer("github.com/astanx/anime_api/internal/analytics")
+
+type clickhouseWriter struct {
+	conn clickhouse.Conn
+}
+
+// NewClickHouseSink returns a buffered sink that batches events into
+// ClickHouse, one INSERT per table per flush.
+func NewClickHouseSink(conn clickhouse.Conn, opts BufferOptions) *BufferedSink {
+	return NewBufferedSink(&clickhouseWriter{conn: conn}, opts)
+}
+
+func (w *clickhouseWriter) Name() string { return "clickhouse" }
+
+var insertQueries = map[string]string{
+	"search_analytics":     "INSERT INTO search_analytics (query, type, results, searched_at)",
+	"device_analytics":     "INSERT INTO device_analytics (device_id, `from`, created_at)",
+	"collection_analytics": "INSERT INTO collection_analytics (anime_id, type, count)",
+	"favourite_analytics":  "INSERT INTO favourite_analytics (anime_id, favourites)",
+}
+
+func (w *clickhouseWriter) WriteBatch(ctx context.Context, events []Event) error {
+	byTable := make(map[string][]Event)
+	for _, e := range events {
+		byTable[e.Table()] = append(byTable[e.Table()], e)
+	}
+
+	var errs []error
+	for table, rows := range byTable {
+		if err := w.insert(ctx, table, rows); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", table, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (w *clickhouseWriter) insert(ctx context.Context, table string, events []Event) (err error) {
+	query, ok := insertQueries[table]
+	if !ok {
+		return fmt.Errorf("unknown analytics table %s", table)
+	}
+
+	ctx, span := tracer.Start(ctx, "INSERT "+table,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemNameClickHouse,
+			semconv.DBOperationName("INSERT"),
+			semconv.DBCollectionName(table),
+			semconv.DBOperationBatchSize(len(events)),
+		),
+	)
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	batch, err := w.conn.PrepareBatch(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer batch.Close()
+
+	for _, event := range events {
+		var err error
+		switch e := event.(type) {
+		case SearchEvent:
+			err = batch.Append(e.Query, e.Type, uint32(e.Results), e.SearchedAt)
+		case DeviceEvent:
+			err = batch.Append(e.DeviceID, e.From, e.CreatedAt)
+		case CollectionEvent:
+			err = batch.Append(e.AnimeID, e.Type, int32(e.Count))
+		case FavouriteEvent:
+			err = batch.Append(e.AnimeID, int32(e.Favourites))
+		default:
+			err = fmt.Errorf("unknown analytics event %T", event)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return batch.Send()
+}
+
+// fileSink appends events as JSON lines, for running without ClickHouse
+// while still being able to inspect what would have been recorded.
+type fileSink struct {
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+}
+
+func NewFileSink(path string) (Sink, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open analytics file: %w", err)
+	}
+	return &fileSink{file: file, enc: json.NewEncoder(file)}, nil
+}
+
+func (s *fileSink) Name() string { return "file" }
+
+func (s *fileSink) Record(_ context.Context, event Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(struct {
+		Table      string    `json:"table"`
+		RecordedAt time.Time `json:"recorded_at"`
+		Event      Event     `json:"event"`
+	}{event.Table(), time.Now(), event})
+}
+
+func (s *fileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+type noopSink struct{}
+
+// NewNoopSink returns a sink that discards every event.
+func NewNoopSink() Sink {
+	return noopSink{}
+}
+
+func (noopSink) Name() string                        { return "noop" }
+func (noopSink) Record(context.Context, Event) error { return nil }
+func (noopSink) Close() error                        { return nil }
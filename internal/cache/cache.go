@@ -0,0 +1,235 @@
+package cache
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/astanx/anime_api/internal/config"
+	"github.com/astanx/anime_api/internal/metrics"
+	"github.com/astanx/anime_api/internal/upstream"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
+)
+
+// ErrNotFound is returned while a "not found" result is negatively cached.
+var ErrNotFound = errors.New("not found")
+
+// refreshTimeout bounds background refreshes of stale entries, which are not
+// tied to any request.
+const refreshTimeout = time.Minute
+
+// Cache is a read-through cache shared by the repositories, backed by Redis
+// or, without it, by an in-process LRU. Concurrent misses for the same key
+// are coalesced into one fetch.
+type Cache struct {
+	store store
+	ttl   config.CacheTTL
+	group singleflight.Group
+
+	flightMu sync.Mutex
+	flights  map[string]*flight
+
+	// refreshes tracks background refreshes so shutdown can wait for them.
+	mu        sync.Mutex
+	closed    bool
+	refreshes sync.WaitGroup
+}
+
+// New returns a Redis-backed cache, or an in-memory one holding at most
+// memorySize entries when client is nil.
+func New(client *redis.Client, ttl config.CacheTTL, memorySize int) *Cache {
+	var s store = redisStore{client: client}
+	if client == nil {
+		s = newLRUStore(memorySize)
+	}
+	return &Cache{
+		store:   s,
+		ttl:     ttl,
+		flights: make(map[string]*flight),
+	}
+}
+
+// Backend names the store in use, "redis" or "memory".
+func (c *Cache) Backend() string {
+	return c.store.name()
+}
+
+// TTL returns the configured TTLs, for picking the fresh TTL of a key.
+func (c *Cache) TTL() config.CacheTTL {
+	return c.ttl
+}
+
+type entry[T any] struct {
+	Value      T         `json:"value"`
+	FreshUntil time.Time `json:"fresh_until"`
+	NotFound   string    `json:"not_found,omitempty"`
+}
+
+// Fetch returns the cached value for key, calling fetch on a miss. Entries
+// older than ttl are returned as-is while a background refresh runs, until
+// the stale window passes too. Not-found errors are cached for the negative
+// TTL.
+func Fetch[T any](ctx context.Context, c *Cache, key string, ttl time.Duration, fetch func(ctx context.Context) (T, error)) (T, error) {
+	var zero T
+
+	if e, ok := get[T](ctx, c, key); ok {
+		if e.NotFound != "" {
+			observe(key, "negative")
+			return zero, fmt.Errorf("%w: %s", ErrNotFound, e.NotFound)
+		}
+		if time.Now().After(e.FreshUntil) {
+			observe(key, "stale")
+			c.goRefresh(func() { refresh(c, key, ttl, fetch) })
+		} else {
+			observe(key, "hit")
+		}
+		return e.Value, nil
+	}
+	observe(key, "miss")
+
+	v, err := c.do(ctx, key, func(ctx context.Context) (any, error) {
+		return load(ctx, c, key, ttl, fetch)
+	})
+	if err != nil {
+		return zero, err
+	}
+	return v.(T), nil
+}
+
+// Remember is Fetch without the stale window or negative caching, for data
+// that must not be served long after it changes. Writers call Invalidate.
+func Remember[T any](ctx context.Context, c *Cache, key string, ttl time.Duration, fetch func(ctx context.Context) (T, error)) (T, error) {
+	var zero T
+
+	if e, ok := get[T](ctx, c, key); ok && e.NotFound == "" && time.Now().Before(e.FreshUntil) {
+		observe(key, "hit")
+		return e.Value, nil
+	}
+	observe(key, "miss")
+
+	v, err := c.do(ctx, key, func(ctx context.Context) (any, error) {
+		value, err := fetch(ctx)
+		if err != nil {
+			return value, err
+		}
+		set(ctx, c, key, entry[T]{Value: value, FreshUntil: time.Now().Add(ttl)}, ttl)
+		return value, nil
+	})
+	if err != nil {
+		return zero, err
+	}
+	return v.(T), nil
+}
+
+// Invalidate drops key so the next read fetches it again.
+func (c *Cache) Invalidate(ctx context.Context, key string) {
+	if err := c.store.delete(ctx, key); err != nil {
+		slog.ErrorContext(ctx, "failed to delete cache key", "key", key, "error", err)
+	}
+}
+
+func (c *Cache) goRefresh(fn func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+	c.refreshes.Add(1)
+	go func() {
+		defer c.refreshes.Done()
+		fn()
+	}()
+}
+
+// Close stops new background refreshes and waits for running ones until ctx
+// is done.
+func (c *Cache) Close(ctx context.Context) error {
+	c.mu.Lock()
+	c.closed = true
+	c.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		c.refreshes.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func refresh[T any](c *Cache, key string, ttl time.Duration, fetch func(ctx context.Context) (T, error)) {
+	ctx, cancel := context.WithTimeout(context.Background(), refreshTimeout)
+	defer cancel()
+
+	_, err, _ := c.group.Do(key, func() (any, error) {
+		return load(ctx, c, key, ttl, fetch)
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to refresh cache key", "key", key, "error", err)
+	}
+}
+
+func load[T any](ctx context.Context, c *Cache, key string, ttl time.Duration, fetch func(ctx context.Context) (T, error)) (T, error) {
+	value, err := fetch(ctx)
+	if err != nil {
+		if isNotFound(err) && c.ttl.Negative > 0 {
+			set(ctx, c, key, entry[T]{NotFound: err.Error()}, c.ttl.Negative)
+		}
+		return value, err
+	}
+
+	set(ctx, c, key, entry[T]{Value: value, FreshUntil: time.Now().Add(ttl)}, ttl+c.ttl.Stale)
+	return value, nil
+}
+
+func get[T any](ctx context.Context, c *Cache, key string) (entry[T], bool) {
+	var e entry[T]
+	cached, err := c.store.get(ctx, key)
+	if err != nil {
+		if !errors.Is(err, errMiss) {
+			slog.ErrorContext(ctx, "failed to get cache key", "key", key, "error", err)
+		}
+		return e, false
+	}
+	// Values written before entries were wrapped have no FreshUntil and are
+	// treated as misses.
+	if err := json.Unmarshal(cached, &e); err != nil || (e.FreshUntil.IsZero() && e.NotFound == "") {
+		return e, false
+	}
+	return e, true
+}
+
+func set[T any](ctx context.Context, c *Cache, key string, e entry[T], expiration time.Duration) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to marshal cache key", "key", key, "error", err)
+		return
+	}
+	if err := c.store.set(ctx, key, data, expiration); err != nil {
+		slog.ErrorContext(ctx, "failed to set cache key", "key", key, "error", err)
+	}
+}
+
+func observe(key, result string) {
+	metrics.CacheLookups.WithLabelValues(metrics.KeyPrefix(key), result).Inc()
+}
+
+func isNotFound(err error) bool {
+	if errors.Is(err, sql.ErrNoRows) {
+		return true
+	}
+	var statusErr *upstream.StatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound
+}
@@ -0,0 +1,59 @@
+package cache
+
+import "context"
+
+// flight is a fetch shared by every caller missing the same key.
+type flight struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	waiters int
+}
+
+// do runs fn once for concurrent callers of key. fn keeps the context values
+// of the caller that started it but is only cancelled once every caller has
+// given up, so one client disconnecting does not fail the others while the
+// upstream call still stops when nobody is waiting for it.
+func (c *Cache) do(ctx context.Context, key string, fn func(ctx context.Context) (any, error)) (any, error) {
+	fctx := c.join(ctx, key)
+	defer c.leave(key)
+
+	ch := c.group.DoChan(key, func() (any, error) {
+		return fn(fctx)
+	})
+	select {
+	case res := <-ch:
+		return res.Val, res.Err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *Cache) join(ctx context.Context, key string) context.Context {
+	c.flightMu.Lock()
+	defer c.flightMu.Unlock()
+
+	f, ok := c.flights[key]
+	if !ok {
+		fctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		f = &flight{ctx: fctx, cancel: cancel}
+		c.flights[key] = f
+	}
+	f.waiters++
+	return f.ctx
+}
+
+func (c *Cache) leave(key string) {
+	c.flightMu.Lock()
+	defer c.flightMu.Unlock()
+
+	f := c.flights[key]
+	f.waiters--
+	if f.waiters > 0 {
+		return
+	}
+	f.cancel()
+	delete(c.flights, key)
+	// A cancelled fetch may still be unwinding; later callers start afresh
+	// instead of inheriting its error.
+	c.group.Forget(key)
+}
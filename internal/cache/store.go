@@ -0,0 +1,119 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var errMiss = errors.New("cache miss")
+
+// store is where cache entries live: Redis when configured, otherwise an
+// in-process LRU.
+type store interface {
+	get(ctx context.Context, key string) ([]byte, error)
+	set(ctx context.Context, key string, value []byte, expiration time.Duration) error
+	delete(ctx context.Context, key string) error
+	name() string
+}
+
+type redisStore struct {
+	client *redis.Client
+}
+
+func (s redisStore) get(ctx context.Context, key string) ([]byte, error) {
+	data, err := s.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, errMiss
+	}
+	return data, err
+}
+
+func (s redisStore) set(ctx context.Context, key string, value []byte, expiration time.Duration) error {
+	return s.client.Set(ctx, key, value, expiration).Err()
+}
+
+func (s redisStore) delete(ctx context.Context, key string) error {
+	return s.client.Del(ctx, key).Err()
+}
+
+func (s redisStore) name() string { return "redis" }
+
+type lruEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// lruStore is a size-bounded in-memory store. Expired entries are dropped
+// when read or when evicted as least recently used.
+type lruStore struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List
+	items    map[string]*list.Element
+}
+
+func newLRUStore(capacity int) *lruStore {
+	return &lruStore{
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (s *lruStore) get(_ context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, ok := s.items[key]
+	if !ok {
+		return nil, errMiss
+	}
+	e := el.Value.(*lruEntry)
+	if time.Now().After(e.expiresAt) {
+		s.order.Remove(el)
+		delete(s.items, key)
+		return nil, errMiss
+	}
+	s.order.MoveToFront(el)
+	return e.value, nil
+}
+
+func (s *lruStore) set(_ context.Context, key string, value []byte, expiration time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expiresAt := time.Now().Add(expiration)
+	if el, ok := s.items[key]; ok {
+		e := el.Value.(*lruEntry)
+		e.value, e.expiresAt = value, expiresAt
+		s.order.MoveToFront(el)
+		return nil
+	}
+
+	s.items[key] = s.order.PushFront(&lruEntry{key: key, value: value, expiresAt: expiresAt})
+	for s.order.Len() > s.capacity {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.items, oldest.Value.(*lruEntry).key)
+	}
+	return nil
+}
+
+func (s *lruStore) delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if el, ok := s.items[key]; ok {
+		s.order.Remove(el)
+		delete(s.items, key)
+	}
+	return nil
+}
+
+func (s *lruStore) name() string { return "memory" }
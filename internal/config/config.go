@@ -1,37 +1,468 @@
 package config
 
 import (
-	"log"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
 	"os"
+	"strconv"
+	"time"
 
+	"github.com/astanx/anime_api/internal/ratelimit"
 	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	ServerAddress  string
-	PostgresDSN    string
-	ClickhouseUser string
-	ClickhouseHost string
-	ClickhousePass string
-	RedisURL       string
+	ServerAddress  string `yaml:"server_address"`
+	PostgresDSN    string `yaml:"postgres_dsn"`
+	ClickhouseUser string `yaml:"clickhouse_username"`
+	ClickhouseHost string `yaml:"clickhouse_host"`
+	ClickhousePass string `yaml:"clickhouse_password"`
+	RedisURL       string `yaml:"redis_url"`
+	MappingFile    string `yaml:"mapping_file"`
+	AnalyticsFile  string `yaml:"analytics_file"`
+	AutoMigrate    bool   `yaml:"auto_migrate"`
+
+	// LogLevel is the minimum slog level: debug, info, warn or error.
+	LogLevel string `yaml:"log_level"`
+
+	// ShutdownTimeout is how long in-flight requests and background work get
+	// to finish after SIGINT/SIGTERM.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+
+	// Outbound rate limits per provider, e.g. "3/s,60/m".
+	JikanRateLimit     string `yaml:"jikan_rate_limit"`
+	AnilibriaRateLimit string `yaml:"anilibria_rate_limit"`
+	ConsumetRateLimit  string `yaml:"consumet_rate_limit"`
+	ProwlarrRateLimit  string `yaml:"prowlarr_rate_limit"`
+
+	// RateLimits are per-client limits for each route group.
+	RateLimits RateLimitConfig `yaml:"rate_limits"`
+
+	// RequestTimeouts bound how long each route group may run before its
+	// queries and upstream calls are cancelled.
+	RequestTimeouts RequestTimeoutConfig `yaml:"request_timeouts"`
+
+	Upstream UpstreamConfig `yaml:"upstream"`
+	Cache    CacheTTL       `yaml:"cache"`
+
+	Analytics AnalyticsConfig `yaml:"analytics"`
+	Tracing   TracingConfig   `yaml:"tracing"`
+
+	// AuthSecret signs account and device tokens. It is required unless
+	// AllowRandomAuthSecret is set.
+	AuthSecret     string        `yaml:"auth_secret"`
+	AuthTokenTTL   time.Duration `yaml:"auth_token_ttl"`
+	DeviceTokenTTL time.Duration `yaml:"device_token_ttl"`
+
+	// AllowRandomAuthSecret generates a random AuthSecret when none is
+	// set, for local development. Tokens then do not survive a restart.
+	AllowRandomAuthSecret bool `yaml:"allow_random_auth_secret"`
+
+	// AllowLegacyDeviceIDs keeps accepting bare device IDs from clients that
+	// predate device tokens, as long as the device is registered.
+	AllowLegacyDeviceIDs bool `yaml:"allow_legacy_device_ids"`
+
+	// PairingCodeTTL is how long a device pairing code can be redeemed.
+	PairingCodeTTL time.Duration `yaml:"pairing_code_ttl"`
+
+	// MemoryCacheSize caps the in-process cache used when Redis is not
+	// configured.
+	MemoryCacheSize int `yaml:"memory_cache_size"`
+}
+
+// UpstreamConfig holds the base URLs and credentials of the anime sources.
+type UpstreamConfig struct {
+	ConsumetURL        string `yaml:"consumet_url"`
+	AnilibriaURL       string `yaml:"anilibria_url"`
+	JikanURL           string `yaml:"jikan_url"`
+	ProwlarrURL        string `yaml:"prowlarr_url"`
+	ProwlarrAPIKey     string `yaml:"prowlarr_api_key"`
+	ProwlarrCategories string `yaml:"prowlarr_categories"`
+	TorrServerURL      string `yaml:"torrserver_url"`
 }
 
+// RateLimitConfig holds inbound request limits per route group, in the same
+// "<count>/<s|m|h>" format as the outbound ones. Empty disables a limit.
+type RateLimitConfig struct {
+	// Default covers every authenticated route.
+	Default string `yaml:"default"`
+	// Anime additionally covers routes that call upstream providers.
+	Anime string `yaml:"anime"`
+	// Auth covers device creation, register, login and pairing, keyed by
+	// IP where there is no device yet.
+	Auth string `yaml:"auth"`
+}
+
+// RequestTimeoutConfig holds the deadline of each route group.
+type RequestTimeoutConfig struct {
+	// Default covers every route without a more specific timeout.
+	Default time.Duration `yaml:"default"`
+	// Upstream covers anime and torrent routes, which call providers with
+	// retries.
+	Upstream time.Duration `yaml:"upstream"`
+	// Import covers MAL list import and export, which look up every entry.
+	Import time.Duration `yaml:"import"`
+}
+
+// AnalyticsConfig tunes the buffered ClickHouse analytics writer.
+type AnalyticsConfig struct {
+	BufferSize    int           `yaml:"buffer_size"`
+	BatchSize     int           `yaml:"batch_size"`
+	FlushInterval time.Duration `yaml:"flush_interval"`
+}
+
+// TracingConfig selects where OpenTelemetry spans are exported.
+type TracingConfig struct {
+	// Exporter is "otlp", "stdout" or empty to disable tracing.
+	Exporter string `yaml:"exporter"`
+	// Endpoint is the OTLP/HTTP collector URL, e.g. http://localhost:4318.
+	// When empty the standard OTEL_EXPORTER_OTLP_* variables apply.
+	Endpoint string `yaml:"endpoint"`
+	// SampleRatio is the share of new traces recorded, from 0 to 1.
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+// CacheTTL holds how long cached upstream data stays fresh. After that,
+// entries are still served for Stale while being refreshed in the
+// background. Negative is how long "not found" results are remembered.
+type CacheTTL struct {
+	Lookup   time.Duration `yaml:"lookup"`
+	Anime    time.Duration `yaml:"anime"`
+	Episode  time.Duration `yaml:"episode"`
+	Search   time.Duration `yaml:"search"`
+	Genres   time.Duration `yaml:"genres"`
+	Stale    time.Duration `yaml:"stale"`
+	Negative time.Duration `yaml:"negative"`
+}
+
+func defaultConfig() *Config {
+	return &Config{
+		ServerAddress:   ":8080",
+		AutoMigrate:     true,
+		LogLevel:        "info",
+		ShutdownTimeout: 30 * time.Second,
+		AuthTokenTTL:    30 * 24 * time.Hour,
+		DeviceTokenTTL:  90 * 24 * time.Hour,
+		PairingCodeTTL:  10 * time.Minute,
+		MemoryCacheSize: 10000,
+
+		Analytics: AnalyticsConfig{
+			BufferSize:    10000,
+			BatchSize:     500,
+			FlushInterval: 5 * time.Second,
+		},
+
+		RequestTimeouts: RequestTimeoutConfig{
+			Default:  10 * time.Second,
+			Upstream: time.Minute,
+			Import:   5 * time.Minute,
+		},
+
+		Tracing: TracingConfig{
+			SampleRatio: 1,
+		},
+
+		JikanRateLimit:     "3/s,60/m",
+		AnilibriaRateLimit: "10/s",
+		ConsumetRateLimit:  "5/s",
+		ProwlarrRateLimit:  "2/s",
+
+		RateLimits: RateLimitConfig{
+			Default: "20/s,600/m",
+			Anime:   "5/s,120/m",
+			Auth:    "10/m",
+		},
+
+		Upstream: UpstreamConfig{
+			ConsumetURL:        "https://consumet-new.onrender.com",
+			AnilibriaURL:       "https://aniliberty.top",
+			JikanURL:           "https://api.jikan.moe/v4",
+			ProwlarrURL:        "https://prowlarr-kj0q.onrender.com/api/v1",
+			ProwlarrCategories: "5070",
+			TorrServerURL:      "http://localhost:8090",
+		},
+
+		Cache: CacheTTL{
+			Lookup:   time.Hour,
+			Anime:    4 * time.Hour,
+			Episode:  12 * time.Hour,
+			Search:   12 * time.Hour,
+			Genres:   24 * time.Hour,
+			Stale:    6 * time.Hour,
+			Negative: time.Minute,
+		},
+	}
+}
+
+// LoadConfig builds the config from defaults, then the YAML file named by
+// CONFIG_FILE if set, then environment variables, each overriding the last.
 func LoadConfig() (*Config, error) {
 	if _, err := os.Stat(".env"); err == nil {
 		if err := godotenv.Load(); err != nil {
-			log.Println("Failed to load .env file:", err)
+			slog.Warn("failed to load .env file", "error", err)
 		}
 	}
-	addr := os.Getenv("SERVER_ADDRESS")
-	if addr == "" {
-		addr = ":8080"
+
+	cfg := defaultConfig()
+
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
 	}
-	return &Config{
-		ServerAddress:  addr,
-		PostgresDSN:    os.Getenv("POSTGRES_DSN"),
-		ClickhouseUser: os.Getenv("CLICKHOUSE_USERNAME"),
-		ClickhouseHost: os.Getenv("CLICKHOUSE_HOST"),
-		ClickhousePass: os.Getenv("CLICKHOUSE_PASSWORD"),
-		RedisURL:       os.Getenv("REDIS_URL"),
-	}, nil
+
+	if err := cfg.loadEnv(); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	if cfg.AuthSecret == "" {
+		if !cfg.AllowRandomAuthSecret {
+			return nil, errors.New("AUTH_SECRET is required, set ALLOW_RANDOM_AUTH_SECRET=true to generate one for development")
+		}
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, fmt.Errorf("failed to generate auth secret: %w", err)
+		}
+		cfg.AuthSecret = hex.EncodeToString(secret)
+		slog.Warn("AUTH_SECRET is not set, account and device tokens will be invalidated on restart")
+	}
+
+	return cfg, nil
+}
+
+func (c *Config) loadEnv() error {
+	setString(&c.ServerAddress, "SERVER_ADDRESS")
+	setString(&c.PostgresDSN, "POSTGRES_DSN")
+	setString(&c.ClickhouseUser, "CLICKHOUSE_USERNAME")
+	setString(&c.ClickhouseHost, "CLICKHOUSE_HOST")
+	setString(&c.ClickhousePass, "CLICKHOUSE_PASSWORD")
+	setString(&c.RedisURL, "REDIS_URL")
+	setString(&c.MappingFile, "MAPPING_FILE")
+	setString(&c.AnalyticsFile, "ANALYTICS_FILE")
+	setString(&c.LogLevel, "LOG_LEVEL")
+	setString(&c.Tracing.Exporter, "TRACING_EXPORTER")
+	setString(&c.Tracing.Endpoint, "TRACING_ENDPOINT")
+	if err := setFloat(&c.Tracing.SampleRatio, "TRACING_SAMPLE_RATIO"); err != nil {
+		return err
+	}
+	setString(&c.AuthSecret, "AUTH_SECRET")
+	if err := setBool(&c.AllowRandomAuthSecret, "ALLOW_RANDOM_AUTH_SECRET"); err != nil {
+		return err
+	}
+	if err := setBool(&c.AllowLegacyDeviceIDs, "ALLOW_LEGACY_DEVICE_IDS"); err != nil {
+		return err
+	}
+	if err := setBool(&c.AutoMigrate, "AUTO_MIGRATE"); err != nil {
+		return err
+	}
+	if err := setInt(&c.MemoryCacheSize, "MEMORY_CACHE_SIZE"); err != nil {
+		return err
+	}
+	if err := setInt(&c.Analytics.BufferSize, "ANALYTICS_BUFFER_SIZE"); err != nil {
+		return err
+	}
+	if err := setInt(&c.Analytics.BatchSize, "ANALYTICS_BATCH_SIZE"); err != nil {
+		return err
+	}
+
+	setString(&c.JikanRateLimit, "JIKAN_RATE_LIMIT")
+	setString(&c.AnilibriaRateLimit, "ANILIBRIA_RATE_LIMIT")
+	setString(&c.ConsumetRateLimit, "CONSUMET_RATE_LIMIT")
+	setString(&c.ProwlarrRateLimit, "PROWLARR_RATE_LIMIT")
+	setString(&c.RateLimits.Default, "RATE_LIMIT_DEFAULT")
+	setString(&c.RateLimits.Anime, "RATE_LIMIT_ANIME")
+	setString(&c.RateLimits.Auth, "RATE_LIMIT_AUTH")
+
+	setString(&c.Upstream.ConsumetURL, "CONSUMET_URL")
+	setString(&c.Upstream.AnilibriaURL, "ANILIBRIA_URL")
+	setString(&c.Upstream.JikanURL, "JIKAN_URL")
+	setString(&c.Upstream.ProwlarrURL, "PROWLARR_URL")
+	setString(&c.Upstream.ProwlarrAPIKey, "PROWLARR_API_KEY")
+	setString(&c.Upstream.ProwlarrCategories, "PROWLARR_CATEGORIES")
+	setString(&c.Upstream.TorrServerURL, "TORRSERVER_URL")
+
+	durations := []struct {
+		key    string
+		target *time.Duration
+	}{
+		{"SHUTDOWN_TIMEOUT", &c.ShutdownTimeout},
+		{"REQUEST_TIMEOUT_DEFAULT", &c.RequestTimeouts.Default},
+		{"REQUEST_TIMEOUT_UPSTREAM", &c.RequestTimeouts.Upstream},
+		{"REQUEST_TIMEOUT_IMPORT", &c.RequestTimeouts.Import},
+		{"AUTH_TOKEN_TTL", &c.AuthTokenTTL},
+		{"DEVICE_TOKEN_TTL", &c.DeviceTokenTTL},
+		{"PAIRING_CODE_TTL", &c.PairingCodeTTL},
+		{"ANALYTICS_FLUSH_INTERVAL", &c.Analytics.FlushInterval},
+		{"CACHE_TTL_LOOKUP", &c.Cache.Lookup},
+		{"CACHE_TTL_ANIME", &c.Cache.Anime},
+		{"CACHE_TTL_EPISODE", &c.Cache.Episode},
+		{"CACHE_TTL_SEARCH", &c.Cache.Search},
+		{"CACHE_TTL_GENRES", &c.Cache.Genres},
+		{"CACHE_TTL_STALE", &c.Cache.Stale},
+		{"CACHE_TTL_NEGATIVE", &c.Cache.Negative},
+	}
+	for _, d := range durations {
+		if err := setDuration(d.target, d.key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Validate reports every invalid setting at once.
+func (c *Config) Validate() error {
+	var errs []error
+
+	urls := []struct {
+		name  string
+		value string
+	}{
+		{"consumet_url", c.Upstream.ConsumetURL},
+		{"anilibria_url", c.Upstream.AnilibriaURL},
+		{"jikan_url", c.Upstream.JikanURL},
+		{"prowlarr_url", c.Upstream.ProwlarrURL},
+		{"torrserver_url", c.Upstream.TorrServerURL},
+	}
+	if c.Tracing.Endpoint != "" {
+		urls = append(urls, struct {
+			name  string
+			value string
+		}{"tracing.endpoint", c.Tracing.Endpoint})
+	}
+	for _, u := range urls {
+		parsed, err := url.Parse(u.value)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			errs = append(errs, fmt.Errorf("%s must be an absolute http(s) URL, got %q", u.name, u.value))
+		}
+	}
+
+	rateLimits := []struct {
+		name  string
+		value string
+	}{
+		{"jikan_rate_limit", c.JikanRateLimit},
+		{"anilibria_rate_limit", c.AnilibriaRateLimit},
+		{"consumet_rate_limit", c.ConsumetRateLimit},
+		{"prowlarr_rate_limit", c.ProwlarrRateLimit},
+		{"rate_limits.default", c.RateLimits.Default},
+		{"rate_limits.anime", c.RateLimits.Anime},
+		{"rate_limits.auth", c.RateLimits.Auth},
+	}
+	for _, r := range rateLimits {
+		if _, err := ratelimit.ParseRates(r.value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", r.name, err))
+		}
+	}
+
+	if c.Cache.Lookup <= 0 || c.Cache.Anime <= 0 || c.Cache.Episode <= 0 || c.Cache.Search <= 0 || c.Cache.Genres <= 0 {
+		errs = append(errs, errors.New("cache TTLs must be positive"))
+	}
+	if c.Analytics.BufferSize <= 0 || c.Analytics.BatchSize <= 0 || c.Analytics.FlushInterval <= 0 {
+		errs = append(errs, errors.New("analytics buffer_size, batch_size and flush_interval must be positive"))
+	}
+	if c.MemoryCacheSize <= 0 {
+		errs = append(errs, errors.New("memory_cache_size must be positive"))
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		errs = append(errs, fmt.Errorf("log_level: %w", err))
+	}
+	switch c.Tracing.Exporter {
+	case "", "otlp", "stdout":
+	default:
+		errs = append(errs, fmt.Errorf("tracing.exporter: unknown exporter %q", c.Tracing.Exporter))
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, errors.New("tracing.sample_ratio must be between 0 and 1"))
+	}
+	if c.RequestTimeouts.Default <= 0 || c.RequestTimeouts.Upstream <= 0 || c.RequestTimeouts.Import <= 0 {
+		errs = append(errs, errors.New("request_timeouts must be positive"))
+	}
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("shutdown_timeout must be positive"))
+	}
+	if c.AuthTokenTTL <= 0 || c.DeviceTokenTTL <= 0 || c.PairingCodeTTL <= 0 {
+		errs = append(errs, errors.New("auth_token_ttl, device_token_ttl and pairing_code_ttl must be positive"))
+	}
+	if c.Cache.Stale < 0 || c.Cache.Negative < 0 {
+		errs = append(errs, errors.New("cache stale and negative TTLs must not be negative"))
+	}
+
+	if c.Upstream.ProwlarrAPIKey == "" {
+		slog.Warn("PROWLARR_API_KEY is not set, torrent sources will be unavailable")
+	}
+
+	return errors.Join(errs...)
+}
+
+func setString(target *string, key string) {
+	if value, ok := os.LookupEnv(key); ok {
+		*target = value
+	}
+}
+
+func setBool(target *bool, key string) error {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("invalid boolean for %s: %q", key, value)
+	}
+	*target = b
+	return nil
+}
+
+func setInt(target *int, key string) error {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("invalid integer for %s: %q", key, value)
+	}
+	*target = n
+	return nil
+}
+
+func setFloat(target *float64, key string) error {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("invalid number for %s: %q", key, value)
+	}
+	*target = f
+	return nil
+}
+
+func setDuration(target *time.Duration, key string) error {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("invalid duration for %s: %q", key, value)
+	}
+	*target = d
+	return nil
 }
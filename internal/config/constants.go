@@ -1,10 +0,0 @@
-package config
-
-const (
-	ConsumetUrl     = "https://consumet-new.onrender.com"
-	PROWLARR_URL    = "https://prowlarr-kj0q.onrender.com/api/v1"
-	PROWLARR_APIKEY = "8ca9710cdaf64275ba91d63af3659998"
-	CATEGORY        = "5070"
-
-	TORR_URL = "http://localhost:8090"
-)
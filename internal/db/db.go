@@ -4,24 +4,49 @@ import (
 	"context"
 	"crypto/tls"
 	"database/sql"
-	"log"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
 	"time"
 
 	clickhouse "github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/XSAM/otelsql"
+	"github.com/astanx/anime_api/internal/analytics"
+	"github.com/astanx/anime_api/internal/config"
 	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type DB struct {
-	Postgres   *sql.DB
+	Postgres *sql.DB
+	// ClickHouse and Redis are nil when not configured.
 	ClickHouse clickhouse.Conn
 	Redis      *redis.Client
+	// Analytics writes to ClickHouse in batches when it is configured,
+	// otherwise to ANALYTICS_FILE or nowhere.
+	Analytics analytics.Sink
 }
 
-func Connect(postgresDSN, clickhouseUser, clickhousePass, clickhouseHost, redisURL string) (*DB, error) {
-	pg, err := sql.Open("pgx", postgresDSN)
+// Connect opens every configured database. PostgreSQL is required; Redis and
+// ClickHouse are skipped when REDIS_URL or CLICKHOUSE_HOST is empty.
+func Connect(cfg *config.Config) (*DB, error) {
+	pg, err := otelsql.Open("pgx", cfg.PostgresDSN,
+		otelsql.WithAttributes(semconv.DBSystemNamePostgreSQL),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			OmitConnResetSession: true,
+			OmitConnPrepare:      true,
+			OmitRows:             true,
+			OmitConnectorConnect: true,
+			SpanFilter:           withParentSpan,
+		}),
+	)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to open PostgreSQL: %w", err)
 	}
 
 	pg.SetMaxOpenConns(25)
@@ -29,44 +54,172 @@ func Connect(postgresDSN, clickhouseUser, clickhousePass, clickhouseHost, redisU
 	pg.SetConnMaxLifetime(5 * time.Minute)
 
 	if err := pg.Ping(); err != nil {
-		return nil, err
+		pg.Close()
+		return nil, fmt.Errorf("failed to ping PostgreSQL: %w", err)
 	}
-	opt, err := redis.ParseURL(redisURL)
-	if err != nil {
-		log.Fatalf("failed to parse Redis URL: %v", err)
+
+	d := &DB{Postgres: pg}
+
+	if cfg.RedisURL != "" {
+		opt, err := redis.ParseURL(cfg.RedisURL)
+		if err != nil {
+			d.Close()
+			return nil, fmt.Errorf("failed to parse Redis URL: %w", err)
+		}
+
+		d.Redis = redis.NewClient(opt)
+		// Cached values can be large, so spans carry the command name only.
+		if err := redisotel.InstrumentTracing(d.Redis, redisotel.WithDBStatement(false)); err != nil {
+			d.Close()
+			return nil, fmt.Errorf("failed to instrument Redis: %w", err)
+		}
+		pong, err := d.Redis.Ping(context.Background()).Result()
+		if err != nil {
+			d.Close()
+			return nil, fmt.Errorf("failed to ping Redis: %w", err)
+		}
+		slog.Info("redis connected", "ping", pong)
+	} else {
+		slog.Warn("REDIS_URL is not set, using in-process cache")
 	}
 
-	rdb := redis.NewClient(opt)
-	ctx := context.Background()
+	if cfg.ClickhouseHost != "" {
+		ch, err := clickhouse.Open(&clickhouse.Options{
+			Addr: []string{cfg.ClickhouseHost},
+			Auth: clickhouse.Auth{
+				Database: "default",
+				Username: cfg.ClickhouseUser,
+				Password: cfg.ClickhousePass,
+			},
+			TLS: &tls.Config{
+				InsecureSkipVerify: true,
+			},
+			DialTimeout:  5 * time.Second,
+			MaxOpenConns: 10,
+			MaxIdleConns: 5,
+		})
+		if err != nil {
+			d.Close()
+			return nil, fmt.Errorf("failed to open ClickHouse: %w", err)
+		}
+		d.ClickHouse = ch
+		d.Analytics = analytics.NewClickHouseSink(ch, analytics.BufferOptions{
+			BufferSize:    cfg.Analytics.BufferSize,
+			BatchSize:     cfg.Analytics.BatchSize,
+			FlushInterval: cfg.Analytics.FlushInterval,
+			WriteTimeout:  10 * time.Second,
+		})
+	} else if cfg.AnalyticsFile != "" {
+		sink, err := analytics.NewFileSink(cfg.AnalyticsFile)
+		if err != nil {
+			d.Close()
+			return nil, err
+		}
+		d.Analytics = sink
+		slog.Warn("CLICKHOUSE_HOST is not set, writing analytics to file", "file", cfg.AnalyticsFile)
+	} else {
+		d.Analytics = analytics.NewNoopSink()
+		slog.Warn("CLICKHOUSE_HOST is not set, analytics are disabled")
+	}
 
-	pong, err := rdb.Ping(ctx).Result()
-	if err != nil {
-		log.Fatalf("failed to ping Redis: %v", err)
+	slog.Info("connected to postgres")
+	return d, nil
+}
+
+// withParentSpan limits Postgres spans to queries made on behalf of a traced
+// request, leaving out migrations and pool maintenance.
+func withParentSpan(ctx context.Context, _ otelsql.Method, _ string, _ []driver.NamedValue) bool {
+	return trace.SpanContextFromContext(ctx).IsValid()
+}
+
+// Close flushes buffered analytics and closes every connection pool,
+// returning all errors encountered.
+func (d *DB) Close() error {
+	var errs []error
+	if d.Analytics != nil {
+		if err := d.Analytics.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("analytics: %w", err))
+		}
 	}
-	if err != nil {
-		log.Fatalf("Failed to connect to Redis: %v", err)
+	if d.Postgres != nil {
+		if err := d.Postgres.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("postgres: %w", err))
+		}
 	}
-	log.Println("Redis connected:", pong)
-
-	ch, _ := clickhouse.Open(&clickhouse.Options{
-		Addr: []string{clickhouseHost},
-		Auth: clickhouse.Auth{
-			Database: "default",
-			Username: clickhouseUser,
-			Password: clickhousePass,
-		},
-		TLS: &tls.Config{
-			InsecureSkipVerify: true,
-		},
-		DialTimeout:  5 * time.Second,
-		MaxOpenConns: 10,
-		MaxIdleConns: 5,
-	})
-
-	log.Println("Connected to PostgreSQL and ClickHouse Cloud")
-	return &DB{
-		Postgres:   pg,
-		ClickHouse: ch,
-		Redis:      rdb,
-	}, nil
+	if d.Redis != nil {
+		if err := d.Redis.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("redis: %w", err))
+		}
+	}
+	if d.ClickHouse != nil {
+		if err := d.ClickHouse.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("clickhouse: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// errDisabled marks an optional dependency that was not configured.
+var errDisabled = errors.New("not configured")
+
+type DependencyStatus struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Check pings every database concurrently and reports each one's status:
+// "up", "down", or "disabled" for optional ones that are not configured.
+func (d *DB) Check(ctx context.Context) []DependencyStatus {
+	checks := []struct {
+		name string
+		ping func(ctx context.Context) error
+	}{
+		{"postgres", func(ctx context.Context) error {
+			if d.Postgres == nil {
+				return errDisabled
+			}
+			return d.Postgres.PingContext(ctx)
+		}},
+		{"redis", func(ctx context.Context) error {
+			if d.Redis == nil {
+				return errDisabled
+			}
+			return d.Redis.Ping(ctx).Err()
+		}},
+		{"clickhouse", func(ctx context.Context) error {
+			if d.ClickHouse == nil {
+				return errDisabled
+			}
+			return d.ClickHouse.Ping(ctx)
+		}},
+	}
+
+	statuses := make([]DependencyStatus, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			err := check.ping(ctx)
+			status := DependencyStatus{
+				Name:      check.name,
+				Status:    "up",
+				LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			switch {
+			case errors.Is(err, errDisabled) && check.name != "postgres":
+				status.Status = "disabled"
+			case err != nil:
+				status.Status = "down"
+				status.Error = err.Error()
+			}
+			statuses[i] = status
+		}()
+	}
+	wg.Wait()
+
+	return statuses
 }
@@ -0,0 +1,354 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	clickhouse "github.com/ClickHouse/clickhouse-go/v2"
+)
+
+//go:embed migrations/postgres/*.sql
+var postgresMigrations embed.FS
+
+//go:embed migrations/clickhouse/*.sql
+var clickhouseMigrations embed.FS
+
+type MigrationDirection string
+
+const (
+	MigrateUp   MigrationDirection = "up"
+	MigrateDown MigrationDirection = "down"
+)
+
+// migrationLockID is the Postgres advisory lock held while migrating, so
+// several instances starting at once do not apply the same migration twice.
+const migrationLockID = 7243019
+
+type migration struct {
+	version int
+	name    string
+	up      string
+	down    string
+}
+
+// loadMigrations reads NNNN_name.up.sql / NNNN_name.down.sql pairs from dir,
+// sorted by version.
+func loadMigrations(fsys embed.FS, dir string) ([]migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*migration)
+	for _, e := range entries {
+		file := e.Name()
+		var direction MigrationDirection
+		switch {
+		case strings.HasSuffix(file, ".up.sql"):
+			direction = MigrateUp
+		case strings.HasSuffix(file, ".down.sql"):
+			direction = MigrateDown
+		default:
+			continue
+		}
+
+		base := strings.TrimSuffix(file, "."+string(direction)+".sql")
+		prefix, name, found := strings.Cut(base, "_")
+		if !found {
+			return nil, fmt.Errorf("invalid migration file name %s", file)
+		}
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %w", file, err)
+		}
+
+		body, err := fsys.ReadFile(path.Join(dir, file))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &migration{version: version, name: name}
+			byVersion[version] = m
+		}
+		if direction == MigrateUp {
+			m.up = string(body)
+		} else {
+			m.down = string(body)
+		}
+	}
+
+	migrations := make([]migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" || m.down == "" {
+			return nil, fmt.Errorf("migration %04d_%s is missing its up or down file", m.version, m.name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].version < migrations[j].version
+	})
+
+	return migrations, nil
+}
+
+// splitStatements splits a migration body into single statements. ClickHouse
+// only accepts one statement per query, so both engines go through this.
+// Semicolons inside dollar-quoted bodies ($$ ... $$) do not end a statement.
+func splitStatements(body string) []string {
+	var (
+		statements []string
+		quote      string
+		start      int
+	)
+	for i := 0; i < len(body); i++ {
+		switch body[i] {
+		case '$':
+			tag := dollarTag(body[i:])
+			if tag == "" {
+				continue
+			}
+			switch quote {
+			case "":
+				quote = tag
+			case tag:
+				quote = ""
+			}
+			i += len(tag) - 1
+		case ';':
+			if quote != "" {
+				continue
+			}
+			if stmt := strings.TrimSpace(body[start:i]); stmt != "" {
+				statements = append(statements, stmt)
+			}
+			start = i + 1
+		}
+	}
+	if stmt := strings.TrimSpace(body[start:]); stmt != "" {
+		statements = append(statements, stmt)
+	}
+	return statements
+}
+
+// dollarTag returns the $tag$ delimiter s starts with, or "" if it does not
+// start with one.
+func dollarTag(s string) string {
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '$':
+			return s[:i+1]
+		case c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || i > 1 && '0' <= c && c <= '9':
+		default:
+			return ""
+		}
+	}
+	return ""
+}
+
+// plan returns the migrations to run. Up applies every pending migration;
+// down rolls back the last steps applied migrations (all when steps <= 0).
+func plan(migrations []migration, applied map[int]bool, direction MigrationDirection, steps int) ([]migration, error) {
+	var selected []migration
+	switch direction {
+	case MigrateUp:
+		for _, m := range migrations {
+			if !applied[m.version] {
+				selected = append(selected, m)
+			}
+		}
+	case MigrateDown:
+		for i := len(migrations) - 1; i >= 0; i-- {
+			if steps > 0 && len(selected) == steps {
+				break
+			}
+			if applied[migrations[i].version] {
+				selected = append(selected, migrations[i])
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown migration direction %q", direction)
+	}
+	return selected, nil
+}
+
+func MigratePostgres(pg *sql.DB, direction MigrationDirection, steps int) error {
+	ctx := context.Background()
+
+	migrations, err := loadMigrations(postgresMigrations, "migrations/postgres")
+	if err != nil {
+		return err
+	}
+
+	conn, err := pg.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockID); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", migrationLockID)
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		name       TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		return err
+	}
+
+	rows, err := conn.QueryContext(ctx, "SELECT version FROM schema_migrations")
+	if err != nil {
+		return err
+	}
+	applied := make(map[int]bool)
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			rows.Close()
+			return err
+		}
+		applied[version] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	selected, err := plan(migrations, applied, direction, steps)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range selected {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+
+		body := m.up
+		if direction == MigrateDown {
+			body = m.down
+		}
+		for _, stmt := range splitStatements(body) {
+			if _, err := tx.ExecContext(ctx, stmt); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("postgres migration %04d_%s %s failed: %w", m.version, m.name, direction, err)
+			}
+		}
+
+		if direction == MigrateUp {
+			_, err = tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", m.version, m.name)
+		} else {
+			_, err = tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = $1", m.version)
+		}
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		slog.Info("postgres migration applied", "version", m.version, "name", m.name, "direction", direction)
+	}
+
+	return nil
+}
+
+// MigrateClickHouse applies ClickHouse migrations. ClickHouse has no
+// transactions or cheap deletes, so the version table is append-only and the
+// latest row per version records whether it is currently applied.
+func MigrateClickHouse(conn clickhouse.Conn, direction MigrationDirection, steps int) error {
+	ctx := context.Background()
+
+	migrations, err := loadMigrations(clickhouseMigrations, "migrations/clickhouse")
+	if err != nil {
+		return err
+	}
+
+	err = conn.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    UInt32,
+		name       String,
+		applied    UInt8,
+		changed_at DateTime64(6) DEFAULT now64(6)
+	) ENGINE = ReplacingMergeTree(changed_at)
+	ORDER BY version`)
+	if err != nil {
+		return err
+	}
+
+	rows, err := conn.Query(ctx, "SELECT version FROM schema_migrations FINAL WHERE applied = 1")
+	if err != nil {
+		return err
+	}
+	applied := make(map[int]bool)
+	for rows.Next() {
+		var version uint32
+		if err := rows.Scan(&version); err != nil {
+			rows.Close()
+			return err
+		}
+		applied[int(version)] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	selected, err := plan(migrations, applied, direction, steps)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range selected {
+		body := m.up
+		if direction == MigrateDown {
+			body = m.down
+		}
+		for _, stmt := range splitStatements(body) {
+			if err := conn.Exec(ctx, stmt); err != nil {
+				return fmt.Errorf("clickhouse migration %04d_%s %s failed: %w", m.version, m.name, direction, err)
+			}
+		}
+
+		var state uint8
+		if direction == MigrateUp {
+			state = 1
+		}
+		err := conn.Exec(ctx,
+			"INSERT INTO schema_migrations (version, name, applied) VALUES (?, ?, ?)",
+			uint32(m.version), m.name, state,
+		)
+		if err != nil {
+			return err
+		}
+		slog.Info("clickhouse migration applied", "version", m.version, "name", m.name, "direction", direction)
+	}
+
+	return nil
+}
+
+// Migrate runs migrations for Postgres and, when configured, ClickHouse.
+func (d *DB) Migrate(direction MigrationDirection, steps int) error {
+	if err := MigratePostgres(d.Postgres, direction, steps); err != nil {
+		return err
+	}
+	if d.ClickHouse == nil {
+		return nil
+	}
+	return MigrateClickHouse(d.ClickHouse, direction, steps)
+}
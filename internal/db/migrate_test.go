@@ -0,0 +1,109 @@
+package db
+
+import (
+	"embed"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{
+			name: "empty",
+			body: "  \n ",
+			want: nil,
+		},
+		{
+			name: "single statement without semicolon",
+			body: "CREATE TABLE a (id INT)",
+			want: []string{"CREATE TABLE a (id INT)"},
+		},
+		{
+			name: "several statements",
+			body: "CREATE TABLE a (id INT);\n\nCREATE TABLE b (id INT);\n",
+			want: []string{"CREATE TABLE a (id INT)", "CREATE TABLE b (id INT)"},
+		},
+		{
+			name: "empty statements are dropped",
+			body: ";; SELECT 1;;",
+			want: []string{"SELECT 1"},
+		},
+		{
+			name: "semicolons inside $$ body",
+			body: "CREATE FUNCTION f() RETURNS trigger AS $$\nBEGIN\n  RETURN NEW;\nEND;\n$$ LANGUAGE plpgsql;\nSELECT 1;",
+			want: []string{
+				"CREATE FUNCTION f() RETURNS trigger AS $$\nBEGIN\n  RETURN NEW;\nEND;\n$$ LANGUAGE plpgsql",
+				"SELECT 1",
+			},
+		},
+		{
+			name: "semicolons inside tagged body",
+			body: "DO $body$ BEGIN PERFORM 1; END $body$; SELECT 2",
+			want: []string{"DO $body$ BEGIN PERFORM 1; END $body$", "SELECT 2"},
+		},
+		{
+			name: "other tags do not close the body",
+			body: "DO $a$ SELECT $$;$$; $b$;$b$ $a$; SELECT 3",
+			want: []string{"DO $a$ SELECT $$;$$; $b$;$b$ $a$", "SELECT 3"},
+		},
+		{
+			name: "positional parameters are not tags",
+			body: "SELECT $1; SELECT $2",
+			want: []string{"SELECT $1", "SELECT $2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, splitStatements(tt.body))
+		})
+	}
+}
+
+func TestDollarTag(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{"$$ BEGIN", "$$"},
+		{"$body$ BEGIN", "$body$"},
+		{"$_x1$", "$_x1$"},
+		{"$1", ""},
+		{"$1$", ""},
+		{"$a1$", "$a1$"},
+		{"$a-b$", ""},
+		{"$abc", ""},
+		{"$", ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, dollarTag(tt.s), tt.s)
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	tests := []struct {
+		dir  string
+		fsys embed.FS
+	}{
+		{"migrations/postgres", postgresMigrations},
+		{"migrations/clickhouse", clickhouseMigrations},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dir, func(t *testing.T) {
+			migrations, err := loadMigrations(tt.fsys, tt.dir)
+			require.NoError(t, err)
+			require.NotEmpty(t, migrations)
+			for i, m := range migrations {
+				assert.Equal(t, i+1, m.version, m.name)
+				assert.NotEmpty(t, splitStatements(m.up), m.name)
+				assert.NotEmpty(t, splitStatements(m.down), m.name)
+			}
+		})
+	}
+}
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS favourite_analytics;
+DROP TABLE IF EXISTS collection_analytics;
+DROP TABLE IF EXISTS device_analytics;
+DROP TABLE IF EXISTS search_analytics;
@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS search_analytics (
+    query       String,
+    type        LowCardinality(String),
+    results     UInt32,
+    searched_at DateTime
+) ENGINE = MergeTree
+ORDER BY (type, searched_at);
+
+CREATE TABLE IF NOT EXISTS device_analytics (
+    device_id  String,
+    `from`     LowCardinality(String),
+    created_at DateTime
+) ENGINE = MergeTree
+ORDER BY created_at;
+
+CREATE TABLE IF NOT EXISTS collection_analytics (
+    anime_id String,
+    type     LowCardinality(String),
+    count    Int32
+) ENGINE = SummingMergeTree(count)
+ORDER BY (anime_id, type);
+
+CREATE TABLE IF NOT EXISTS favourite_analytics (
+    anime_id   String,
+    favourites Int32
+) ENGINE = SummingMergeTree(favourites)
+ORDER BY anime_id;
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS favourites;
+DROP TABLE IF EXISTS collections;
+DROP TABLE IF EXISTS history;
+DROP TABLE IF EXISTS timecodes;
+DROP TABLE IF EXISTS episode_subtitles;
+DROP TABLE IF EXISTS episode_sources;
+DROP TABLE IF EXISTS episodes;
+DROP TABLE IF EXISTS search;
+DROP TABLE IF EXISTS devices;
@@ -0,0 +1,84 @@
+CREATE TABLE IF NOT EXISTS devices (
+    device_id    TEXT PRIMARY KEY,
+    created_from TEXT NOT NULL DEFAULT 'api',
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS search (
+    id          TEXT PRIMARY KEY,
+    title       TEXT NOT NULL,
+    year        INTEGER NOT NULL DEFAULT 0,
+    poster      TEXT NOT NULL DEFAULT '',
+    type        TEXT NOT NULL DEFAULT '',
+    parser_type TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS episodes (
+    id            TEXT PRIMARY KEY,
+    ordinal       INTEGER NOT NULL,
+    title         TEXT NOT NULL DEFAULT '',
+    opening_start INTEGER,
+    opening_end   INTEGER,
+    ending_start  INTEGER,
+    ending_end    INTEGER
+);
+
+CREATE TABLE IF NOT EXISTS episode_sources (
+    id         SERIAL PRIMARY KEY,
+    episode_id TEXT NOT NULL REFERENCES episodes (id) ON DELETE CASCADE,
+    url        TEXT NOT NULL,
+    type       TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS episode_sources_episode_id_idx ON episode_sources (episode_id);
+
+CREATE TABLE IF NOT EXISTS episode_subtitles (
+    id         SERIAL PRIMARY KEY,
+    episode_id TEXT NOT NULL REFERENCES episodes (id) ON DELETE CASCADE,
+    vtt        TEXT NOT NULL,
+    language   TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS episode_subtitles_episode_id_idx ON episode_subtitles (episode_id);
+
+CREATE TABLE IF NOT EXISTS timecodes (
+    id         SERIAL PRIMARY KEY,
+    device_id  TEXT NOT NULL,
+    anime_id   TEXT NOT NULL,
+    episode_id TEXT NOT NULL,
+    time       INTEGER NOT NULL DEFAULT 0,
+    is_watched BOOLEAN NOT NULL DEFAULT false
+);
+
+CREATE INDEX IF NOT EXISTS timecodes_device_episode_idx ON timecodes (device_id, episode_id);
+CREATE INDEX IF NOT EXISTS timecodes_device_anime_idx ON timecodes (device_id, anime_id);
+
+CREATE TABLE IF NOT EXISTS history (
+    id           SERIAL PRIMARY KEY,
+    device_id    TEXT NOT NULL,
+    anime_id     TEXT NOT NULL,
+    last_watched INTEGER NOT NULL DEFAULT 0,
+    is_watched   BOOLEAN NOT NULL DEFAULT false,
+    watched_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS history_device_anime_idx ON history (device_id, anime_id);
+CREATE INDEX IF NOT EXISTS history_device_watched_at_idx ON history (device_id, watched_at DESC);
+
+CREATE TABLE IF NOT EXISTS collections (
+    id        SERIAL PRIMARY KEY,
+    device_id TEXT NOT NULL,
+    anime_id  TEXT NOT NULL,
+    type      TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS collections_device_anime_idx ON collections (device_id, anime_id);
+CREATE INDEX IF NOT EXISTS collections_device_type_idx ON collections (device_id, type);
+
+CREATE TABLE IF NOT EXISTS favourites (
+    id        SERIAL PRIMARY KEY,
+    device_id TEXT NOT NULL,
+    anime_id  TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS favourites_device_anime_idx ON favourites (device_id, anime_id);
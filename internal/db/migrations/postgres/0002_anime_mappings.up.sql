@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS anime_mappings (
+    id           BIGSERIAL PRIMARY KEY,
+    mal_id       INTEGER UNIQUE,
+    anilibria_id TEXT UNIQUE,
+    consumet_id  TEXT UNIQUE,
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS devices_account_id_idx;
+
+ALTER TABLE devices DROP COLUMN IF EXISTS account_id;
+
+DROP TABLE IF EXISTS accounts;
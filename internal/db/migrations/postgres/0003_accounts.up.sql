@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS accounts (
+    id            TEXT PRIMARY KEY,
+    email         TEXT NOT NULL UNIQUE,
+    password_hash TEXT NOT NULL,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+ALTER TABLE devices ADD COLUMN IF NOT EXISTS account_id TEXT REFERENCES accounts (id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS devices_account_id_idx ON devices (account_id);
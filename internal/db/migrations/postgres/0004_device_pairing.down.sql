@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS pairing_codes;
+
+DROP INDEX IF EXISTS devices_library_id_idx;
+
+ALTER TABLE devices DROP COLUMN IF EXISTS library_id;
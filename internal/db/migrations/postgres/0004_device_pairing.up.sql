@@ -0,0 +1,10 @@
+ALTER TABLE devices ADD COLUMN IF NOT EXISTS library_id TEXT;
+CREATE INDEX IF NOT EXISTS devices_library_id_idx ON devices (library_id);
+
+CREATE TABLE IF NOT EXISTS pairing_codes (
+    code       TEXT PRIMARY KEY,
+    device_id  TEXT NOT NULL REFERENCES devices (device_id) ON DELETE CASCADE,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS pairing_codes_expires_at_idx ON pairing_codes (expires_at);
@@ -0,0 +1 @@
+ALTER TABLE collections DROP COLUMN IF EXISTS updated_at;
@@ -0,0 +1,29 @@
+DROP TRIGGER IF EXISTS timecodes_sync_prune ON timecodes;
+DROP TRIGGER IF EXISTS history_sync_prune ON history;
+DROP TRIGGER IF EXISTS collections_sync_prune ON collections;
+DROP TRIGGER IF EXISTS favourites_sync_prune ON favourites;
+
+DROP TRIGGER IF EXISTS timecodes_sync_tombstone ON timecodes;
+DROP TRIGGER IF EXISTS history_sync_tombstone ON history;
+DROP TRIGGER IF EXISTS collections_sync_tombstone ON collections;
+DROP TRIGGER IF EXISTS favourites_sync_tombstone ON favourites;
+
+DROP TRIGGER IF EXISTS timecodes_sync_touch ON timecodes;
+DROP TRIGGER IF EXISTS history_sync_touch ON history;
+DROP TRIGGER IF EXISTS collections_sync_touch ON collections;
+DROP TRIGGER IF EXISTS favourites_sync_touch ON favourites;
+
+DROP FUNCTION IF EXISTS sync_prune_tombstones();
+DROP FUNCTION IF EXISTS sync_tombstone();
+DROP FUNCTION IF EXISTS sync_touch();
+
+DROP TABLE IF EXISTS sync_tombstones;
+
+ALTER TABLE timecodes DROP COLUMN IF EXISTS sync_xid;
+ALTER TABLE history DROP COLUMN IF EXISTS sync_xid;
+ALTER TABLE collections DROP COLUMN IF EXISTS sync_xid;
+ALTER TABLE favourites DROP COLUMN IF EXISTS sync_xid;
+
+ALTER TABLE timecodes DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE history DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE favourites DROP COLUMN IF EXISTS updated_at;
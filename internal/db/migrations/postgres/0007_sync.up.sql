@@ -0,0 +1,80 @@
+ALTER TABLE timecodes ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+ALTER TABLE history ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+ALTER TABLE favourites ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+-- sync_xid is the id of the transaction that last wrote the row. /sync
+-- returns rows written after the snapshot its cursor was taken from.
+ALTER TABLE timecodes ADD COLUMN IF NOT EXISTS sync_xid xid8 NOT NULL DEFAULT pg_current_xact_id();
+ALTER TABLE history ADD COLUMN IF NOT EXISTS sync_xid xid8 NOT NULL DEFAULT pg_current_xact_id();
+ALTER TABLE collections ADD COLUMN IF NOT EXISTS sync_xid xid8 NOT NULL DEFAULT pg_current_xact_id();
+ALTER TABLE favourites ADD COLUMN IF NOT EXISTS sync_xid xid8 NOT NULL DEFAULT pg_current_xact_id();
+
+CREATE INDEX IF NOT EXISTS timecodes_device_sync_idx ON timecodes (device_id, sync_xid);
+CREATE INDEX IF NOT EXISTS history_device_sync_idx ON history (device_id, sync_xid);
+CREATE INDEX IF NOT EXISTS collections_device_sync_idx ON collections (device_id, sync_xid);
+CREATE INDEX IF NOT EXISTS favourites_device_sync_idx ON favourites (device_id, sync_xid);
+
+CREATE TABLE IF NOT EXISTS sync_tombstones (
+    id         BIGSERIAL PRIMARY KEY,
+    device_id  TEXT NOT NULL,
+    entity     TEXT NOT NULL,
+    anime_id   TEXT NOT NULL,
+    episode_id TEXT NOT NULL DEFAULT '',
+    deleted_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    sync_xid   xid8 NOT NULL DEFAULT pg_current_xact_id()
+);
+
+CREATE INDEX IF NOT EXISTS sync_tombstones_device_sync_idx ON sync_tombstones (device_id, sync_xid);
+CREATE INDEX IF NOT EXISTS sync_tombstones_deleted_at_idx ON sync_tombstones (deleted_at);
+
+CREATE OR REPLACE FUNCTION sync_touch() RETURNS trigger AS $$
+BEGIN
+    NEW.sync_xid := pg_current_xact_id();
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+-- TG_ARGV[0] names the entity in sync_tombstones. Only timecodes are keyed by
+-- episode.
+CREATE OR REPLACE FUNCTION sync_tombstone() RETURNS trigger AS $$
+BEGIN
+    INSERT INTO sync_tombstones (device_id, entity, anime_id, episode_id)
+    VALUES (OLD.device_id, TG_ARGV[0], OLD.anime_id, COALESCE(to_jsonb(OLD) ->> 'episode_id', ''));
+    RETURN OLD;
+END
+$$ LANGUAGE plpgsql;
+
+-- Tombstones are kept for 30 days. Older cursors get a full resync.
+CREATE OR REPLACE FUNCTION sync_prune_tombstones() RETURNS trigger AS $$
+BEGIN
+    DELETE FROM sync_tombstones WHERE deleted_at < now() - interval '30 days';
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER timecodes_sync_touch BEFORE INSERT OR UPDATE ON timecodes
+    FOR EACH ROW EXECUTE FUNCTION sync_touch();
+CREATE TRIGGER history_sync_touch BEFORE INSERT OR UPDATE ON history
+    FOR EACH ROW EXECUTE FUNCTION sync_touch();
+CREATE TRIGGER collections_sync_touch BEFORE INSERT OR UPDATE ON collections
+    FOR EACH ROW EXECUTE FUNCTION sync_touch();
+CREATE TRIGGER favourites_sync_touch BEFORE INSERT OR UPDATE ON favourites
+    FOR EACH ROW EXECUTE FUNCTION sync_touch();
+
+CREATE TRIGGER timecodes_sync_tombstone AFTER DELETE ON timecodes
+    FOR EACH ROW EXECUTE FUNCTION sync_tombstone('timecode');
+CREATE TRIGGER history_sync_tombstone AFTER DELETE ON history
+    FOR EACH ROW EXECUTE FUNCTION sync_tombstone('history');
+CREATE TRIGGER collections_sync_tombstone AFTER DELETE ON collections
+    FOR EACH ROW EXECUTE FUNCTION sync_tombstone('collection');
+CREATE TRIGGER favourites_sync_tombstone AFTER DELETE ON favourites
+    FOR EACH ROW EXECUTE FUNCTION sync_tombstone('favourite');
+
+CREATE TRIGGER timecodes_sync_prune AFTER DELETE ON timecodes
+    FOR EACH STATEMENT EXECUTE FUNCTION sync_prune_tombstones();
+CREATE TRIGGER history_sync_prune AFTER DELETE ON history
+    FOR EACH STATEMENT EXECUTE FUNCTION sync_prune_tombstones();
+CREATE TRIGGER collections_sync_prune AFTER DELETE ON collections
+    FOR EACH STATEMENT EXECUTE FUNCTION sync_prune_tombstones();
+CREATE TRIGGER favourites_sync_prune AFTER DELETE ON favourites
+    FOR EACH STATEMENT EXECUTE FUNCTION sync_prune_tombstones();
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS timecodes_device_episode_key;
+DROP INDEX IF EXISTS history_device_anime_key;
+DROP INDEX IF EXISTS collections_device_anime_key;
+DROP INDEX IF EXISTS favourites_device_anime_key;
+
+CREATE INDEX IF NOT EXISTS timecodes_device_episode_idx ON timecodes (device_id, episode_id);
+CREATE INDEX IF NOT EXISTS history_device_anime_idx ON history (device_id, anime_id);
+CREATE INDEX IF NOT EXISTS collections_device_anime_idx ON collections (device_id, anime_id);
+CREATE INDEX IF NOT EXISTS favourites_device_anime_idx ON favourites (device_id, anime_id);
@@ -0,0 +1,39 @@
+-- Collapse duplicates left by concurrent writes before adding the unique
+-- indexes, keeping the row the merge rules would pick.
+DELETE FROM timecodes WHERE id IN (
+    SELECT id FROM (
+        SELECT id, row_number() OVER (PARTITION BY device_id, episode_id ORDER BY time DESC, is_watched DESC, id DESC) AS n
+        FROM timecodes
+    ) ranked WHERE n > 1
+);
+
+DELETE FROM history WHERE id IN (
+    SELECT id FROM (
+        SELECT id, row_number() OVER (PARTITION BY device_id, anime_id ORDER BY last_watched DESC, is_watched DESC, watched_at DESC, id DESC) AS n
+        FROM history
+    ) ranked WHERE n > 1
+);
+
+DELETE FROM collections WHERE id IN (
+    SELECT id FROM (
+        SELECT id, row_number() OVER (PARTITION BY device_id, anime_id ORDER BY updated_at DESC, id DESC) AS n
+        FROM collections
+    ) ranked WHERE n > 1
+);
+
+DELETE FROM favourites WHERE id IN (
+    SELECT id FROM (
+        SELECT id, row_number() OVER (PARTITION BY device_id, anime_id ORDER BY id) AS n
+        FROM favourites
+    ) ranked WHERE n > 1
+);
+
+DROP INDEX IF EXISTS timecodes_device_episode_idx;
+DROP INDEX IF EXISTS history_device_anime_idx;
+DROP INDEX IF EXISTS collections_device_anime_idx;
+DROP INDEX IF EXISTS favourites_device_anime_idx;
+
+CREATE UNIQUE INDEX IF NOT EXISTS timecodes_device_episode_key ON timecodes (device_id, episode_id);
+CREATE UNIQUE INDEX IF NOT EXISTS history_device_anime_key ON history (device_id, anime_id);
+CREATE UNIQUE INDEX IF NOT EXISTS collections_device_anime_key ON collections (device_id, anime_id);
+CREATE UNIQUE INDEX IF NOT EXISTS favourites_device_anime_key ON favourites (device_id, anime_id);
@@ -0,0 +1,3 @@
+-- token_version is carried in device tokens. Refreshing a token bumps it,
+-- which invalidates the tokens issued before.
+ALTER TABLE devices ADD COLUMN IF NOT EXISTS token_version INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/astanx/anime_api/internal/model"
+	"github.com/astanx/anime_api/internal/repository"
+	"github.com/astanx/anime_api/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+type AccountHandler struct {
+	service *service.AccountService
+}
+
+func NewAccountHandler(s *service.AccountService) *AccountHandler {
+	return &AccountHandler{
+		service: s,
+	}
+}
+
+// Register creates an account. A device sent as "Authorization: Device
+// <token>" is linked to it and its data moves to the account.
+func (h *AccountHandler) Register(c *gin.Context) {
+	var creds model.Credentials
+	if err := c.ShouldBindJSON(&creds); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	token, err := h.service.Register(c.Request.Context(), creds, requestDeviceToken(c))
+	switch {
+	case errors.Is(err, service.ErrInvalidEmail), errors.Is(err, service.ErrWeakPassword):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, repository.ErrEmailTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, service.ErrInvalidToken), errors.Is(err, service.ErrDeviceRevoked):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		slog.ErrorContext(c.Request.Context(), "AccountHandler: failed to register", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't register"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, token)
+}
+
+// Login issues an account token. A device sent as "Authorization: Device
+// <token>" is linked to the account.
+func (h *AccountHandler) Login(c *gin.Context) {
+	var creds model.Credentials
+	if err := c.ShouldBindJSON(&creds); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	token, err := h.service.Login(c.Request.Context(), creds, requestDeviceToken(c))
+	if errors.Is(err, service.ErrInvalidCredentials) || errors.Is(err, service.ErrInvalidToken) || errors.Is(err, service.ErrDeviceRevoked) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "AccountHandler: failed to login", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't login"})
+		return
+	}
+
+	c.JSON(http.StatusOK, token)
+}
+
+func (h *AccountHandler) Me(c *gin.Context) {
+	accountID := c.GetString("accountID")
+	if accountID == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "device is not linked to an account"})
+		return
+	}
+
+	account, err := h.service.GetAccount(c.Request.Context(), accountID)
+	if errors.Is(err, repository.ErrAccountNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "AccountHandler: failed to get account", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't get account"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"result": account})
+}
+
+// requestDeviceToken returns the token from an optional "Device <token>"
+// header.
+func requestDeviceToken(c *gin.Context) string {
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Device ")
+	if !ok {
+		return ""
+	}
+	return token
+}
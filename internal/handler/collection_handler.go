@@ -1,7 +1,7 @@
 package handler
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
 
@@ -29,13 +29,13 @@ func (h *CollectionHandler) AddCollection(c *gin.Context) {
 
 	var collection model.Collection
 	if err := c.ShouldBindJSON(&collection); err != nil {
-		log.Printf("invalid request body: %v", err)
+		slog.WarnContext(c.Request.Context(), "invalid request body", "error", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
 		return
 	}
 
-	if err := h.service.AddCollection(deviceID, collection); err != nil {
-		log.Printf("failed to add collection: %v", err)
+	if err := h.service.AddCollection(c.Request.Context(), deviceID, collection); err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to add collection", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't add collection"})
 		return
 	}
@@ -56,7 +56,7 @@ func (h *CollectionHandler) RemoveCollection(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		log.Printf("invalid request body: %v", err)
+		slog.WarnContext(c.Request.Context(), "invalid request body", "error", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
 		return
 	}
@@ -66,8 +66,8 @@ func (h *CollectionHandler) RemoveCollection(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.RemoveCollection(deviceID, req.AnimeID, req.CollectionType); err != nil {
-		log.Printf("failed to remove collection: %v", err)
+	if err := h.service.RemoveCollection(c.Request.Context(), deviceID, req.AnimeID, req.CollectionType); err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to remove collection", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't remove collection"})
 		return
 	}
@@ -82,9 +82,9 @@ func (h *CollectionHandler) GetAllCollections(c *gin.Context) {
 		return
 	}
 
-	collections, err := h.service.GetAllCollections(deviceID)
+	collections, err := h.service.GetAllCollections(c.Request.Context(), deviceID)
 	if err != nil {
-		log.Printf("failed to get all collections: %v", err)
+		slog.ErrorContext(c.Request.Context(), "failed to get all collections", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't get collections"})
 		return
 	}
@@ -119,9 +119,9 @@ func (h *CollectionHandler) GetCollections(c *gin.Context) {
 		return
 	}
 
-	collections, err := h.service.GetCollections(deviceID, T, page, limit)
+	collections, err := h.service.GetCollections(c.Request.Context(), deviceID, T, page, limit)
 	if err != nil {
-		log.Printf("failed to get paginated collections: %v", err)
+		slog.ErrorContext(c.Request.Context(), "failed to get paginated collections", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't get collections"})
 		return
 	}
@@ -142,9 +142,9 @@ func (h *CollectionHandler) GetCollectionForAnime(c *gin.Context) {
 		return
 	}
 
-	collection, err := h.service.GetCollectionForAnime(deviceID, animeID)
+	collection, err := h.service.GetCollectionForAnime(c.Request.Context(), deviceID, animeID)
 	if err != nil {
-		log.Printf("failed to get collection for anime: %v", err)
+		slog.ErrorContext(c.Request.Context(), "failed to get collection for anime", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't get collection"})
 		return
 	}
@@ -1,9 +1,11 @@
 package handler
 
 import (
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
 
+	"github.com/astanx/anime_api/internal/repository"
 	"github.com/astanx/anime_api/internal/service"
 	"github.com/google/uuid"
 
@@ -19,20 +21,196 @@ func NewDeviceHandler(s *service.DeviceService) *DeviceHandler {
 		service: s,
 	}
 }
+
 func (h *DeviceHandler) AddDeviceID(c *gin.Context) {
 	deviceId := uuid.New()
 	from := c.DefaultQuery("from", "api")
 
-	_, err := h.service.AddDeviceID(deviceId, from)
+	token, err := h.service.AddDeviceID(c.Request.Context(), deviceId, from)
 	if err != nil {
-		log.Printf("failed to add device: %v", err)
+		slog.ErrorContext(c.Request.Context(), "failed to add device", "error", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "can't add device"})
 		return
 	}
 
-	type response struct {
-		ID string `json:"id"`
+	c.JSON(http.StatusOK, token)
+}
+
+// RefreshToken issues a new token for the calling device and invalidates
+// its earlier tokens.
+func (h *DeviceHandler) RefreshToken(c *gin.Context) {
+	deviceID := c.GetString("clientDeviceID")
+	if deviceID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "refresh requires a Device authorization header"})
+		return
+	}
+
+	token, err := h.service.RefreshToken(c.Request.Context(), deviceID)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "DeviceHandler: failed to refresh token", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't refresh token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, token)
+}
+
+// RevokeDevice permanently invalidates the tokens of a device in the caller's
+// library, or of the calling device when no device_id is given.
+func (h *DeviceHandler) RevokeDevice(c *gin.Context) {
+	var body struct {
+		DeviceID string `json:"device_id"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+			return
+		}
+	}
+	if body.DeviceID == "" {
+		body.DeviceID = c.GetString("clientDeviceID")
+	}
+	if body.DeviceID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "device_id is required"})
+		return
+	}
+
+	err := h.service.RevokeDevice(c.Request.Context(), c.GetString("deviceID"), body.DeviceID)
+	if errors.Is(err, repository.ErrDeviceNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "DeviceHandler: failed to revoke device", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't revoke device"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// CreatePairingCode issues a short-lived code for the calling device. Another
+// device redeems it to share this device's library.
+func (h *DeviceHandler) CreatePairingCode(c *gin.Context) {
+	deviceID := c.GetString("clientDeviceID")
+	if deviceID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "pairing requires a Device authorization header"})
+		return
+	}
+
+	code, err := h.service.CreatePairingCode(c.Request.Context(), deviceID)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "DeviceHandler: failed to create pairing code", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't create pairing code"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"result": code})
+}
+
+// RedeemPairingCode links the calling device to the library of the device
+// that issued the code and returns the devices now sharing it.
+func (h *DeviceHandler) RedeemPairingCode(c *gin.Context) {
+	deviceID := c.GetString("clientDeviceID")
+	if deviceID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "pairing requires a Device authorization header"})
+		return
+	}
+
+	var body struct {
+		Code string `json:"code"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || body.Code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code is required"})
+		return
+	}
+
+	owner, err := h.service.RedeemPairingCode(c.Request.Context(), body.Code, deviceID)
+	switch {
+	case errors.Is(err, repository.ErrPairingCodeInvalid):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, repository.ErrPairSelf):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, repository.ErrPairAccount):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		slog.ErrorContext(c.Request.Context(), "DeviceHandler: failed to redeem pairing code", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't redeem pairing code"})
+		return
+	}
+
+	devices, err := h.service.ListDevices(c.Request.Context(), owner.Key(), deviceID)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "DeviceHandler: failed to list devices", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't list devices"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": devices})
+}
+
+// ListDevices returns the devices sharing the caller's library.
+func (h *DeviceHandler) ListDevices(c *gin.Context) {
+	devices, err := h.service.ListDevices(c.Request.Context(), c.GetString("deviceID"), c.GetString("clientDeviceID"))
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "DeviceHandler: failed to list devices", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't list devices"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": devices})
+}
+
+// UnlinkDevice removes a device from the caller's library.
+func (h *DeviceHandler) UnlinkDevice(c *gin.Context) {
+	err := h.service.UnlinkDevice(c.Request.Context(), c.GetString("deviceID"), c.Param("id"))
+	if errors.Is(err, repository.ErrDeviceNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "DeviceHandler: failed to unlink device", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't unlink device"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// MergeDevice moves the favourites, history, timecodes and collections of
+// another device, and the device itself, into the caller's library and
+// reports what changed.
+func (h *DeviceHandler) MergeDevice(c *gin.Context) {
+	var body struct {
+		SourceToken string `json:"source_token"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || body.SourceToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "source_token is required"})
+		return
+	}
+
+	report, err := h.service.MergeDevice(c.Request.Context(), body.SourceToken, c.GetString("deviceID"), c.GetString("clientDeviceID"))
+	switch {
+	case errors.Is(err, service.ErrInvalidToken), errors.Is(err, service.ErrDeviceRevoked):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid source_token: " + err.Error()})
+		return
+	case errors.Is(err, repository.ErrMergeSameLibrary):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, repository.ErrMergeAccount):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, repository.ErrMergeShared):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		slog.ErrorContext(c.Request.Context(), "DeviceHandler: failed to merge devices", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't merge devices"})
+		return
 	}
 
-	c.JSON(http.StatusOK, response{ID: deviceId.String()})
+	c.JSON(http.StatusOK, gin.H{"result": report})
 }
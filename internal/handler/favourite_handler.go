@@ -1,7 +1,7 @@
 package handler
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
 
@@ -29,13 +29,13 @@ func (h *FavouriteHandler) AddFavourite(c *gin.Context) {
 
 	var favourite model.Favourite
 	if err := c.ShouldBindJSON(&favourite); err != nil {
-		log.Printf("invalid request body: %v", err)
+		slog.WarnContext(c.Request.Context(), "invalid request body", "error", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
 		return
 	}
 
-	if err := h.service.AddFavourite(deviceID, favourite); err != nil {
-		log.Printf("failed to add favourite: %v", err)
+	if err := h.service.AddFavourite(c.Request.Context(), deviceID, favourite); err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to add favourite", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't add favourite"})
 		return
 	}
@@ -51,13 +51,13 @@ func (h *FavouriteHandler) RemoveFavourite(c *gin.Context) {
 	}
 	var favourite model.Favourite
 	if err := c.ShouldBindJSON(&favourite); err != nil {
-		log.Printf("invalid request body: %v", err)
+		slog.WarnContext(c.Request.Context(), "invalid request body", "error", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
 		return
 	}
 
-	if err := h.service.RemoveFavourite(deviceID, favourite); err != nil {
-		log.Printf("failed to remove favourite: %v", err)
+	if err := h.service.RemoveFavourite(c.Request.Context(), deviceID, favourite); err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to remove favourite", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't remove favourite"})
 		return
 	}
@@ -72,9 +72,9 @@ func (h *FavouriteHandler) GetAllFavourites(c *gin.Context) {
 		return
 	}
 
-	favourites, err := h.service.GetAllFavourites(deviceID)
+	favourites, err := h.service.GetAllFavourites(c.Request.Context(), deviceID)
 	if err != nil {
-		log.Printf("failed to get all favourites: %v", err)
+		slog.ErrorContext(c.Request.Context(), "failed to get all favourites", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't get favourites"})
 		return
 	}
@@ -104,9 +104,9 @@ func (h *FavouriteHandler) GetFavourites(c *gin.Context) {
 		return
 	}
 
-	favourites, err := h.service.GetFavourites(deviceID, page, limit)
+	favourites, err := h.service.GetFavourites(c.Request.Context(), deviceID, page, limit)
 	if err != nil {
-		log.Printf("failed to get paginated favourites: %v", err)
+		slog.ErrorContext(c.Request.Context(), "failed to get paginated favourites", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't get favourites"})
 		return
 	}
@@ -127,9 +127,9 @@ func (h *FavouriteHandler) GetFavouriteForAnime(c *gin.Context) {
 		return
 	}
 
-	favourite, err := h.service.GetFavouriteForAnime(deviceID, animeID)
+	favourite, err := h.service.GetFavouriteForAnime(c.Request.Context(), deviceID, animeID)
 	if err != nil {
-		log.Printf("failed to get favourite for anime: %v", err)
+		slog.ErrorContext(c.Request.Context(), "failed to get favourite for anime", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't get favourite"})
 		return
 	}
@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/astanx/anime_api/internal/analytics"
+	"github.com/astanx/anime_api/internal/cache"
+	"github.com/astanx/anime_api/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+// readyTimeout bounds the dependency pings done by Readyz.
+const readyTimeout = 3 * time.Second
+
+type HealthHandler struct {
+	databases *db.DB
+	cache     *cache.Cache
+}
+
+func NewHealthHandler(databases *db.DB, cache *cache.Cache) *HealthHandler {
+	return &HealthHandler{
+		databases: databases,
+		cache:     cache,
+	}
+}
+
+// Healthz reports that the process is alive. It never touches dependencies.
+func (h *HealthHandler) Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readyz pings every database and returns 503 if any of them is down.
+// Optional databases that are not configured are reported as "disabled",
+// along with the fallback cache and analytics backends in use.
+func (h *HealthHandler) Readyz(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readyTimeout)
+	defer cancel()
+
+	dependencies := h.databases.Check(ctx)
+
+	status, code := "ready", http.StatusOK
+	for _, d := range dependencies {
+		if d.Status != "up" {
+			status, code = "not ready", http.StatusServiceUnavailable
+			break
+		}
+	}
+
+	response := gin.H{
+		"status":       status,
+		"dependencies": dependencies,
+		"backends": gin.H{
+			"cache":     h.cache.Backend(),
+			"analytics": h.databases.Analytics.Name(),
+		},
+	}
+	if buffered, ok := h.databases.Analytics.(*analytics.BufferedSink); ok {
+		response["analytics"] = buffered.Stats()
+	}
+
+	c.JSON(code, response)
+}
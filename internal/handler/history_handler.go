@@ -1,7 +1,8 @@
 package handler
 
 import (
-	"log"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"strconv"
 
@@ -29,13 +30,13 @@ func (h *HistoryHandler) AddHistory(c *gin.Context) {
 
 	var history model.History
 	if err := c.ShouldBindJSON(&history); err != nil {
-		log.Printf("invalid request body: %v", err)
+		slog.WarnContext(c.Request.Context(), "invalid request body", "error", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
 		return
 	}
 
-	if err := h.service.AddHistory(deviceID, history); err != nil {
-		log.Printf("failed to add history: %v", err)
+	if err := h.service.AddHistory(c.Request.Context(), deviceID, history); err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to add history", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't add history"})
 		return
 	}
@@ -43,6 +44,36 @@ func (h *HistoryHandler) AddHistory(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
+// AddHistoryBatch applies a JSON array of history entries recorded offline,
+// each with the client's updated_at, and reports the outcome of every item.
+func (h *HistoryHandler) AddHistoryBatch(c *gin.Context) {
+	deviceID := c.GetString("deviceID")
+	if deviceID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "deviceID is required"})
+		return
+	}
+
+	var history []model.SyncHistory
+	if err := c.ShouldBindJSON(&history); err != nil {
+		slog.WarnContext(c.Request.Context(), "invalid request body", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	if len(history) > maxBatchSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("at most %d history entries per batch", maxBatchSize)})
+		return
+	}
+
+	results, err := h.service.ApplyHistory(c.Request.Context(), deviceID, history)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to apply history", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't add history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
+
 func (h *HistoryHandler) GetAllHistory(c *gin.Context) {
 	deviceID := c.GetString("deviceID")
 	if deviceID == "" {
@@ -50,9 +81,9 @@ func (h *HistoryHandler) GetAllHistory(c *gin.Context) {
 		return
 	}
 
-	history, err := h.service.GetAllHistory(deviceID)
+	history, err := h.service.GetAllHistory(c.Request.Context(), deviceID)
 	if err != nil {
-		log.Printf("failed to get all history: %v", err)
+		slog.ErrorContext(c.Request.Context(), "failed to get all history", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't get history"})
 		return
 	}
@@ -82,9 +113,9 @@ func (h *HistoryHandler) GetHistory(c *gin.Context) {
 		return
 	}
 
-	history, err := h.service.GetHistory(deviceID, page, limit)
+	history, err := h.service.GetHistory(c.Request.Context(), deviceID, page, limit)
 	if err != nil {
-		log.Printf("failed to get paginated history: %v", err)
+		slog.ErrorContext(c.Request.Context(), "failed to get paginated history", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't get history"})
 		return
 	}
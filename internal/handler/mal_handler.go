@@ -2,7 +2,7 @@ package handler
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 
 	"github.com/astanx/anime_api/internal/service"
@@ -26,9 +26,9 @@ func (h *MALHandler) ExportMALList(c *gin.Context) {
 		return
 	}
 
-	malList, err := h.service.ExportMALList(deviceID)
+	malList, err := h.service.ExportMALList(c.Request.Context(), deviceID)
 	if err != nil {
-		log.Printf("failed to export MAL list: %v", err)
+		slog.ErrorContext(c.Request.Context(), "failed to export MAL list", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't export MAL list"})
 		return
 	}
@@ -48,14 +48,14 @@ func (h *MALHandler) ImportMALList(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&malList); err != nil {
-		log.Printf("invalid request body: %v", err)
+		slog.WarnContext(c.Request.Context(), "invalid request body", "error", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
 		return
 	}
 
-	count, err := h.service.ImportMALList(deviceID, malList.MalList)
+	count, err := h.service.ImportMALList(c.Request.Context(), deviceID, malList.MalList)
 	if err != nil {
-		log.Printf("failed to import MAL list: %v", err)
+		slog.ErrorContext(c.Request.Context(), "failed to import MAL list", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't import MAL list", "message": "Error occured during MAL import."})
 		return
 	}
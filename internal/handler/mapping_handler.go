@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/astanx/anime_api/internal/repository"
+	"github.com/astanx/anime_api/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+type MappingHandler struct {
+	service *service.MappingService
+}
+
+func NewMappingHandler(s *service.MappingService) *MappingHandler {
+	return &MappingHandler{
+		service: s,
+	}
+}
+
+func (h *MappingHandler) GetMapping(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		slog.WarnContext(c.Request.Context(), "missing id param in GetMapping")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id param is required"})
+		return
+	}
+	provider := c.Query("provider")
+
+	mappings, err := h.service.GetMappings(c.Request.Context(), provider, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrUnknownProvider) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown provider"})
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "GetMapping: failed to get mapping", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't get mapping"})
+		return
+	}
+
+	if len(mappings) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "mapping not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": mappings})
+}
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/astanx/anime_api/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// maxContinueWatching caps the limit of /continue-watching, since every
+// title needs its episode list.
+const maxContinueWatching = 50
+
+type ProgressHandler struct {
+	service *service.ProgressService
+}
+
+func NewProgressHandler(s *service.ProgressService) *ProgressHandler {
+	return &ProgressHandler{
+		service: s,
+	}
+}
+
+func (h *ProgressHandler) ContinueWatching(c *gin.Context) {
+	deviceID := c.GetString("deviceID")
+	if deviceID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "deviceID is required"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > maxContinueWatching {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+		return
+	}
+
+	titles, err := h.service.ContinueWatching(c.Request.Context(), deviceID, limit)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to get continue watching", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't get continue watching"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": titles})
+}
+
+// NextEpisode returns the episode the device should play next for the anime
+// in the path. With ?dub=true only dubbed episodes count, with ?dub=false
+// only subbed ones.
+func (h *ProgressHandler) NextEpisode(c *gin.Context) {
+	deviceID := c.GetString("deviceID")
+	if deviceID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "deviceID is required"})
+		return
+	}
+	animeID := c.Param("id")
+	if animeID == "" {
+		slog.WarnContext(c.Request.Context(), "missing id param in NextEpisode")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id param is required"})
+		return
+	}
+
+	var audio string
+	if raw, ok := c.GetQuery("dub"); ok {
+		dub, err := strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "dub must be a boolean"})
+			return
+		}
+		audio = "sub"
+		if dub {
+			audio = "dub"
+		}
+	}
+
+	next, err := h.service.NextEpisode(c.Request.Context(), deviceID, animeID, audio)
+	if errors.Is(err, service.ErrAnimeInfo) {
+		slog.ErrorContext(c.Request.Context(), "NextEpisode: failed to get anime info", "anime_id", animeID, "error", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to get anime info"})
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "NextEpisode: failed to get next episode", "anime_id", animeID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't get next episode"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"result": next})
+}
@@ -0,0 +1,240 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/astanx/anime_api/internal/repository"
+	"github.com/astanx/anime_api/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+type ProviderHandler struct {
+	service *service.ProviderService
+}
+
+func NewProviderHandler(s *service.ProviderService) *ProviderHandler {
+	return &ProviderHandler{
+		service: s,
+	}
+}
+
+func providerError(c *gin.Context, err error, message string) {
+	if errors.Is(err, repository.ErrUnknownProvider) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown provider"})
+		return
+	}
+	if errors.Is(err, repository.ErrLimitNotSupported) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": message})
+}
+
+// consumetProvider keeps the genre shapes its routes had before providers
+// were unified: a list of genre names and a bare list of releases.
+const consumetProvider = "consumet"
+
+// pageAndLimit reads the paging query. A missing limit is returned as 0 and
+// left to the provider's default.
+func pageAndLimit(c *gin.Context) (int, int, bool) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "page must be an integer"})
+		return 0, 0, false
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "0"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be an integer"})
+		return 0, 0, false
+	}
+	return page, limit, true
+}
+
+func (h *ProviderHandler) Search(c *gin.Context) {
+	provider := c.GetString("provider")
+	query := c.Query("query")
+	if query == "" {
+		slog.WarnContext(c.Request.Context(), "missing query param in Search", "provider", provider)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "query param is required"})
+		return
+	}
+	page, _, ok := pageAndLimit(c)
+	if !ok {
+		return
+	}
+
+	anime, err := h.service.Search(c.Request.Context(), provider, query, page)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Search: failed to search", "provider", provider, "error", err)
+		providerError(c, err, "failed to search")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": anime})
+}
+
+func (h *ProviderHandler) Latest(c *gin.Context) {
+	provider := c.GetString("provider")
+	page, limit, ok := pageAndLimit(c)
+	if !ok {
+		return
+	}
+
+	releases, err := h.service.Latest(c.Request.Context(), provider, limit, page)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Latest: failed to get releases", "provider", provider, "error", err)
+		providerError(c, err, "failed to get releases")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": releases})
+}
+
+func (h *ProviderHandler) Recommended(c *gin.Context) {
+	provider := c.GetString("provider")
+	page, limit, ok := pageAndLimit(c)
+	if !ok {
+		return
+	}
+
+	anime, err := h.service.Recommended(c.Request.Context(), provider, limit, page)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Recommended: failed to get releases", "provider", provider, "error", err)
+		providerError(c, err, "failed to get releases")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": anime})
+}
+
+func (h *ProviderHandler) Genres(c *gin.Context) {
+	provider := c.GetString("provider")
+
+	genres, err := h.service.Genres(c.Request.Context(), provider)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Genres: failed to get genres", "provider", provider, "error", err)
+		providerError(c, err, "failed to get genres")
+		return
+	}
+
+	if provider == consumetProvider {
+		names := make([]string, len(genres))
+		for i, genre := range genres {
+			names[i] = genre.Name
+		}
+		c.JSON(http.StatusOK, gin.H{"results": names})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"results": genres})
+}
+
+func (h *ProviderHandler) GenreReleases(c *gin.Context) {
+	provider := c.GetString("provider")
+	genre := c.Query("genre")
+	if genre == "" {
+		slog.WarnContext(c.Request.Context(), "missing genre param in GenreReleases", "provider", provider)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "genre param is required"})
+		return
+	}
+	page, limit, ok := pageAndLimit(c)
+	if !ok {
+		return
+	}
+
+	releases, err := h.service.GenreReleases(c.Request.Context(), provider, genre, limit, page)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "GenreReleases: failed to get releases", "provider", provider, "error", err)
+		providerError(c, err, "failed to get releases")
+		return
+	}
+
+	if provider == consumetProvider {
+		c.JSON(http.StatusOK, gin.H{"results": releases.Data})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"results": releases})
+}
+
+func (h *ProviderHandler) AnimeInfo(c *gin.Context) {
+	provider := c.GetString("provider")
+	id := c.Param("id")
+	if id == "" {
+		slog.WarnContext(c.Request.Context(), "missing id param in AnimeInfo", "provider", provider)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id param is required"})
+		return
+	}
+
+	anime, err := h.service.AnimeInfo(c.Request.Context(), provider, id)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "AnimeInfo: failed to get anime info", "provider", provider, "error", err)
+		providerError(c, err, "failed to get anime info")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"result": anime})
+}
+
+func (h *ProviderHandler) EpisodeInfo(c *gin.Context) {
+	provider := c.GetString("provider")
+	id := c.Param("id")
+	if id == "" {
+		slog.WarnContext(c.Request.Context(), "missing id param in EpisodeInfo", "provider", provider)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id param is required"})
+		return
+	}
+
+	params := repository.EpisodeParams{
+		AnimeID: c.Query("anime_id"),
+		Title:   c.Query("title"),
+		Ordinal: -1,
+	}
+	if ordinalStr := c.Query("ordinal"); ordinalStr != "" {
+		ordinal, err := strconv.Atoi(ordinalStr)
+		if err != nil {
+			slog.WarnContext(c.Request.Context(), "EpisodeInfo: invalid ordinal", "provider", provider, "error", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ordinal must be an integer"})
+			return
+		}
+		params.Ordinal = ordinal
+	}
+	dub, err := strconv.ParseBool(c.DefaultQuery("dub", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "dub must be a boolean"})
+		return
+	}
+	params.Dub = dub
+
+	episode, err := h.service.EpisodeInfo(c.Request.Context(), provider, id, params)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "EpisodeInfo: failed to get episode info", "provider", provider, "error", err)
+		providerError(c, err, "failed to get episode info")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"result": episode})
+}
+
+func (h *ProviderHandler) SearchAll(c *gin.Context) {
+	query := c.Query("query")
+	if query == "" {
+		slog.WarnContext(c.Request.Context(), "missing query param in SearchAll")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "query param is required"})
+		return
+	}
+	page, _, ok := pageAndLimit(c)
+	if !ok {
+		return
+	}
+
+	anime, err := h.service.SearchAll(c.Request.Context(), query, page)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "SearchAll: failed to search", "error", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to search"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": anime})
+}
@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/astanx/anime_api/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+type SyncHandler struct {
+	service *service.SyncService
+}
+
+func NewSyncHandler(s *service.SyncService) *SyncHandler {
+	return &SyncHandler{
+		service: s,
+	}
+}
+
+// Sync returns the timecodes, history, collections and favourites changed or
+// deleted since the since cursor, with the cursor to send next time.
+func (h *SyncHandler) Sync(c *gin.Context) {
+	deviceID := c.GetString("deviceID")
+	if deviceID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "deviceID is required"})
+		return
+	}
+
+	changes, err := h.service.Changes(c.Request.Context(), deviceID, c.Query("since"))
+	if errors.Is(err, service.ErrInvalidCursor) {
+		slog.WarnContext(c.Request.Context(), "SyncHandler: invalid cursor", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "SyncHandler: failed to get changes", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't get changes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"result": changes})
+}
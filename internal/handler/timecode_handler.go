@@ -1,7 +1,8 @@
 package handler
 
 import (
-	"log"
+	"fmt"
+	"log/slog"
 	"net/http"
 
 	"github.com/astanx/anime_api/internal/model"
@@ -9,6 +10,9 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// maxBatchSize caps the number of items in one batch write.
+const maxBatchSize = 500
+
 type TimecodeHandler struct {
 	service *service.TimecodeService
 }
@@ -26,9 +30,9 @@ func (h *TimecodeHandler) GetAllTimecodes(c *gin.Context) {
 		return
 	}
 
-	timecodes, err := h.service.GetAllTimecodes(deviceID)
+	timecodes, err := h.service.GetAllTimecodes(c.Request.Context(), deviceID)
 	if err != nil {
-		log.Printf("failed to get all timecodes: %v", err)
+		slog.ErrorContext(c.Request.Context(), "failed to get all timecodes", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't get timecodes"})
 		return
 	}
@@ -49,9 +53,9 @@ func (h *TimecodeHandler) GetTimecode(c *gin.Context) {
 		return
 	}
 
-	timecode, err := h.service.GetTimecode(deviceID, episodeID)
+	timecode, err := h.service.GetTimecode(c.Request.Context(), deviceID, episodeID)
 	if err != nil {
-		log.Printf("failed to get timecode: %v", err)
+		slog.ErrorContext(c.Request.Context(), "failed to get timecode", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't get timecode"})
 		return
 	}
@@ -73,13 +77,13 @@ func (h *TimecodeHandler) AddOrUpdateTimecode(c *gin.Context) {
 
 	var timecode model.Timecode
 	if err := c.ShouldBindJSON(&timecode); err != nil {
-		log.Printf("invalid request body: %v", err)
+		slog.WarnContext(c.Request.Context(), "invalid request body", "error", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
 		return
 	}
 
-	if err := h.service.AddOrUpdateTimecode(deviceID, timecode); err != nil {
-		log.Printf("failed to add/update timecode: %v", err)
+	if err := h.service.AddOrUpdateTimecode(c.Request.Context(), deviceID, timecode); err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to add/update timecode", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't add/update timecode"})
 		return
 	}
@@ -87,6 +91,36 @@ func (h *TimecodeHandler) AddOrUpdateTimecode(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
+// AddTimecodeBatch applies a JSON array of timecodes recorded offline, each with
+// the client's updated_at, and reports the outcome of every item.
+func (h *TimecodeHandler) AddTimecodeBatch(c *gin.Context) {
+	deviceID := c.GetString("deviceID")
+	if deviceID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "deviceID is required"})
+		return
+	}
+
+	var timecodes []model.SyncTimecode
+	if err := c.ShouldBindJSON(&timecodes); err != nil {
+		slog.WarnContext(c.Request.Context(), "invalid request body", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	if len(timecodes) > maxBatchSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("at most %d timecodes per batch", maxBatchSize)})
+		return
+	}
+
+	results, err := h.service.ApplyTimecodes(c.Request.Context(), deviceID, timecodes)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to apply timecodes", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't add/update timecodes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
+
 func (h *TimecodeHandler) GetTimecodesForAnime(c *gin.Context) {
 	deviceID := c.GetString("deviceID")
 	if deviceID == "" {
@@ -100,9 +134,9 @@ func (h *TimecodeHandler) GetTimecodesForAnime(c *gin.Context) {
 		return
 	}
 
-	timecodes, err := h.service.GetTimecodesForAnime(deviceID, animeID)
+	timecodes, err := h.service.GetTimecodesForAnime(c.Request.Context(), deviceID, animeID)
 	if err != nil {
-		log.Printf("failed to get timecode: %v", err)
+		slog.ErrorContext(c.Request.Context(), "failed to get timecode", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "can't get timecodes"})
 		return
 	}
@@ -1,7 +1,7 @@
 package handler
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
 
@@ -22,7 +22,7 @@ func NewTorrentHandler(s *service.TorrentService) *TorrentHandler {
 func (h *TorrentHandler) SearchMALAnime(c *gin.Context) {
 	query := c.Query("query")
 	if query == "" {
-		log.Println("missing query param in SearchMALAnime")
+		slog.WarnContext(c.Request.Context(), "missing query param in SearchMALAnime")
 		c.JSON(http.StatusBadRequest, gin.H{"error": "query param is required"})
 		return
 	}
@@ -32,14 +32,14 @@ func (h *TorrentHandler) SearchMALAnime(c *gin.Context) {
 	}
 	pageInt, err := strconv.Atoi(page)
 	if err != nil {
-		log.Printf("SearchMALAnime: invalid page: %v", err)
+		slog.WarnContext(c.Request.Context(), "SearchMALAnime: invalid page", "error", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "page must be an integer"})
 		return
 	}
 
-	anime, err := h.service.SearchMALAnime(query, pageInt)
+	anime, err := h.service.SearchMALAnime(c.Request.Context(), query, pageInt)
 	if err != nil {
-		log.Printf("SearchMALAnime: failed to search: %v", err)
+		slog.ErrorContext(c.Request.Context(), "SearchMALAnime: failed to search", "error", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to search"})
 		return
 	}
@@ -54,7 +54,7 @@ func (h *TorrentHandler) SearchMALRecommendedAnime(c *gin.Context) {
 	}
 	pageInt, err := strconv.Atoi(page)
 	if err != nil {
-		log.Printf("SearchMALRecommendedAnime: invalid page: %v", err)
+		slog.WarnContext(c.Request.Context(), "SearchMALRecommendedAnime: invalid page", "error", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "page must be an integer"})
 		return
 	}
@@ -65,14 +65,14 @@ func (h *TorrentHandler) SearchMALRecommendedAnime(c *gin.Context) {
 	}
 	limitInt, err := strconv.Atoi(limit)
 	if err != nil {
-		log.Printf("SearchMALRecommendedAnime: invalid limit: %v", err)
+		slog.WarnContext(c.Request.Context(), "SearchMALRecommendedAnime: invalid limit", "error", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be an integer"})
 		return
 	}
 
-	data, err := h.service.SearchMALRecommendedAnime(limitInt, pageInt)
+	data, err := h.service.SearchMALRecommendedAnime(c.Request.Context(), limitInt, pageInt)
 	if err != nil {
-		log.Printf("SearchMALRecommendedAnime: failed to search: %v", err)
+		slog.ErrorContext(c.Request.Context(), "SearchMALRecommendedAnime: failed to search", "error", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to search"})
 		return
 	}
@@ -87,7 +87,7 @@ func (h *TorrentHandler) SearchMALLatestReleases(c *gin.Context) {
 	}
 	pageInt, err := strconv.Atoi(page)
 	if err != nil {
-		log.Printf("SearchMALLatestReleases: invalid page: %v", err)
+		slog.WarnContext(c.Request.Context(), "SearchMALLatestReleases: invalid page", "error", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "page must be an integer"})
 		return
 	}
@@ -98,14 +98,14 @@ func (h *TorrentHandler) SearchMALLatestReleases(c *gin.Context) {
 	}
 	limitInt, err := strconv.Atoi(limit)
 	if err != nil {
-		log.Printf("SearchMALLatestReleases: invalid limit: %v", err)
+		slog.WarnContext(c.Request.Context(), "SearchMALLatestReleases: invalid limit", "error", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be an integer"})
 		return
 	}
 
-	data, err := h.service.SearchMALLatestReleases(pageInt, limitInt)
+	data, err := h.service.SearchMALLatestReleases(c.Request.Context(), pageInt, limitInt)
 	if err != nil {
-		log.Printf("SearchMALLatestReleases: failed to search: %v", err)
+		slog.ErrorContext(c.Request.Context(), "SearchMALLatestReleases: failed to search", "error", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to search"})
 		return
 	}
@@ -115,14 +115,14 @@ func (h *TorrentHandler) SearchMALLatestReleases(c *gin.Context) {
 func (h *TorrentHandler) SearchMALById(c *gin.Context) {
 	id := c.Param("id")
 	if id == "" {
-		log.Println("missing id param in SearchMALById")
+		slog.WarnContext(c.Request.Context(), "missing id param in SearchMALById")
 		c.JSON(http.StatusBadRequest, gin.H{"error": "id param is required"})
 		return
 	}
 
-	data, err := h.service.SearchMALById(id)
+	data, err := h.service.SearchMALById(c.Request.Context(), id)
 	if err != nil {
-		log.Printf("SearchMALById: failed to search: %v", err)
+		slog.ErrorContext(c.Request.Context(), "SearchMALById: failed to search", "error", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to search"})
 		return
 	}
@@ -132,20 +132,20 @@ func (h *TorrentHandler) SearchMALById(c *gin.Context) {
 func (h *TorrentHandler) SearchMALByEpisodeId(c *gin.Context) {
 	animeId := c.Param("id")
 	if animeId == "" {
-		log.Println("missing id param in SearchMALByEpisodeId")
+		slog.WarnContext(c.Request.Context(), "missing id param in SearchMALByEpisodeId")
 		c.JSON(http.StatusBadRequest, gin.H{"error": "id param is required"})
 		return
 	}
 	episodeId := c.Param("episodeId")
 	if episodeId == "" {
-		log.Println("missing episodeId param in SearchMALByEpisodeId")
+		slog.WarnContext(c.Request.Context(), "missing episodeId param in SearchMALByEpisodeId")
 		c.JSON(http.StatusBadRequest, gin.H{"error": "episodeId param is required"})
 		return
 	}
 
-	data, err := h.service.SearchMALByEpisodeId(animeId, episodeId)
+	data, err := h.service.SearchMALByEpisodeId(c.Request.Context(), animeId, episodeId)
 	if err != nil {
-		log.Printf("SearchMALByEpisodeId: failed to search: %v", err)
+		slog.ErrorContext(c.Request.Context(), "SearchMALByEpisodeId: failed to search", "error", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to search"})
 		return
 	}
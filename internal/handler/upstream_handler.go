@@ -0,0 +1,23 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/astanx/anime_api/internal/upstream"
+	"github.com/gin-gonic/gin"
+)
+
+type UpstreamHandler struct {
+	client *upstream.Client
+}
+
+func NewUpstreamHandler(client *upstream.Client) *UpstreamHandler {
+	return &UpstreamHandler{
+		client: client,
+	}
+}
+
+// Status reports the circuit breaker state of every upstream host.
+func (h *UpstreamHandler) Status(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"results": h.client.Breakers()})
+}
@@ -0,0 +1,85 @@
+// Package metrics holds the Prometheus collectors shared across the API. They
+// are registered with the default registry and served on /metrics.
+package metrics
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/astanx/anime_api/internal/analytics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests by route template, method and status.",
+	}, []string{"method", "route", "status"})
+
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by route template and method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	UpstreamRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "upstream_requests_total",
+		Help: "Upstream request attempts by provider and status code, \"error\" for transport errors or \"circuit_open\" when the breaker rejected the call.",
+	}, []string{"provider", "status"})
+
+	UpstreamDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "upstream_request_duration_seconds",
+		Help:    "Upstream request attempt latency by provider.",
+		Buckets: []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 20, 30},
+	}, []string{"provider"})
+
+	CacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_lookups_total",
+		Help: "Cache lookups by key prefix and result (hit, stale, negative, miss).",
+	}, []string{"prefix", "result"})
+)
+
+// KeyPrefix reduces a cache key to its first two segments, e.g.
+// "anime:search:consumet:query:x" to "anime:search", to keep label
+// cardinality bounded.
+func KeyPrefix(key string) string {
+	parts := strings.SplitN(key, ":", 3)
+	if len(parts) < 2 {
+		return parts[0]
+	}
+	return parts[0] + ":" + parts[1]
+}
+
+// RegisterDB exports connection pool stats of db under the given name.
+func RegisterDB(db *sql.DB, name string) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// RegisterAnalytics exports the running totals of the buffered analytics
+// writer, read on every scrape.
+func RegisterAnalytics(sink *analytics.BufferedSink) {
+	desc := prometheus.NewDesc(
+		"analytics_events_total",
+		"Analytics events by outcome: written, dropped because the buffer was full, or failed to write.",
+		[]string{"result"}, prometheus.Labels{"sink": sink.Name()},
+	)
+	prometheus.MustRegister(analyticsCollector{desc: desc, sink: sink})
+}
+
+type analyticsCollector struct {
+	desc *prometheus.Desc
+	sink *analytics.BufferedSink
+}
+
+func (c analyticsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c analyticsCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.sink.Stats()
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.CounterValue, float64(stats.Written), "written")
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.CounterValue, float64(stats.Dropped), "dropped")
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.CounterValue, float64(stats.Failed), "failed")
+}
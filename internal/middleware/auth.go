@@ -1,27 +1,77 @@
 package middleware
 
 import (
+	"context"
+	"errors"
+	"log/slog"
 	"net/http"
 	"strings"
 
+	"github.com/astanx/anime_api/internal/model"
+	"github.com/astanx/anime_api/internal/service"
 	"github.com/gin-gonic/gin"
 )
 
-func DeviceMiddleware() gin.HandlerFunc {
+// DeviceResolver verifies a device token and returns the library the device
+// reads and writes.
+type DeviceResolver interface {
+	ResolveDeviceToken(ctx context.Context, token string) (model.DeviceOwner, error)
+}
+
+// TokenResolver returns the account an account token was issued for.
+type TokenResolver interface {
+	ResolveToken(token string) (string, error)
+}
+
+// DeviceMiddleware accepts either "Device <device token>" or "Bearer <account
+// token>"
+// and sets "deviceID" to the key user data is stored under: the account,
+// else the shared library, else the device itself. "accountID" and
+// "clientDeviceID" are set when known.
+func DeviceMiddleware(devices DeviceResolver, tokens TokenResolver) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		auth := c.GetHeader("Authorization")
-		if !strings.HasPrefix(auth, "Device ") {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid auth header"})
-			return
-		}
 
-		deviceID := strings.TrimPrefix(auth, "Device ")
-		if deviceID == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "deviceID required"})
+		switch {
+		case strings.HasPrefix(auth, "Device "):
+			token := strings.TrimPrefix(auth, "Device ")
+			if token == "" {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "device token required"})
+				return
+			}
+
+			owner, err := devices.ResolveDeviceToken(c.Request.Context(), token)
+			switch {
+			case errors.Is(err, service.ErrInvalidToken), errors.Is(err, service.ErrDeviceRevoked):
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+				return
+			case err != nil:
+				slog.ErrorContext(c.Request.Context(), "DeviceMiddleware: failed to resolve device", "error", err)
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "can't resolve device"})
+				return
+			}
+
+			c.Set("clientDeviceID", owner.DeviceID)
+			if owner.AccountID != "" {
+				c.Set("accountID", owner.AccountID)
+			}
+			c.Set("deviceID", owner.Key())
+
+		case strings.HasPrefix(auth, "Bearer "):
+			accountID, err := tokens.ResolveToken(strings.TrimPrefix(auth, "Bearer "))
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+				return
+			}
+
+			c.Set("accountID", accountID)
+			c.Set("deviceID", accountID)
+
+		default:
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid auth header"})
 			return
 		}
 
-		c.Set("deviceID", deviceID)
 		c.Next()
 	}
 }
@@ -0,0 +1,14 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// Provider tags every request in a route group with the upstream provider
+// that serves it, so provider handlers can stay generic.
+func Provider(name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("provider", name)
+		c.Next()
+	}
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/astanx/anime_api/internal/ratelimit"
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimit rejects clients that exceed rates within group with 429.
+// Clients are told apart by device, then account, then IP, so it should run
+// after DeviceMiddleware where there is one. If the limiter fails, requests
+// are let through.
+func RateLimit(limiter ratelimit.Limiter, group string, rates []ratelimit.Rate) gin.HandlerFunc {
+	if len(rates) == 0 {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	return func(c *gin.Context) {
+		res, err := limiter.Allow(c.Request.Context(), group+":"+clientKey(c), rates)
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "RateLimit: failed to check limit", "group", group, "error", err)
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Limit", strconv.Itoa(res.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		c.Header("RateLimit-Reset", seconds(res.Reset))
+
+		if !res.Allowed {
+			c.Header("Retry-After", seconds(res.Reset))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "rate limit exceeded"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func clientKey(c *gin.Context) string {
+	if deviceID := c.GetString("clientDeviceID"); deviceID != "" {
+		return "device:" + deviceID
+	}
+	if accountID := c.GetString("accountID"); accountID != "" {
+		return "account:" + accountID
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// seconds rounds d up to whole seconds, as the headers expect.
+func seconds(d time.Duration) string {
+	return strconv.Itoa(max(int(math.Ceil(d.Seconds())), 0))
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// timeoutParentKey holds the request context as it was before any Timeout,
+// so a route group can replace the deadline of an enclosing group instead of
+// only shortening it.
+const timeoutParentKey = "timeoutParent"
+
+// Timeout cancels the request context after d, which stops the handler's
+// queries and upstream calls. Errors written because the deadline passed are
+// answered with 504 instead of 500.
+func Timeout(d time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		parent := c.Request.Context()
+		if p, ok := c.Get(timeoutParentKey); ok {
+			parent = p.(context.Context)
+		} else {
+			c.Set(timeoutParentKey, parent)
+		}
+
+		ctx, cancel := context.WithTimeout(parent, d)
+		defer cancel()
+
+		c.Request = c.Request.WithContext(ctx)
+		if w, ok := c.Writer.(*timeoutWriter); ok {
+			w.ctx = ctx
+		} else {
+			c.Writer = &timeoutWriter{ResponseWriter: c.Writer, ctx: ctx}
+		}
+		c.Next()
+	}
+}
+
+type timeoutWriter struct {
+	gin.ResponseWriter
+	ctx context.Context
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	if code == http.StatusInternalServerError && errors.Is(w.ctx.Err(), context.DeadlineExceeded) {
+		code = http.StatusGatewayTimeout
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
@@ -0,0 +1,16 @@
+package model
+
+// Outcomes of a single item in a batch write.
+const (
+	BatchApplied = "applied"
+	BatchStale   = "stale"
+	BatchInvalid = "invalid"
+)
+
+// BatchResult reports what happened to the item at Index of a batch. Stale
+// items were older than the value already stored and were skipped.
+type BatchResult struct {
+	Index  int    `json:"index"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
@@ -0,0 +1,62 @@
+package model
+
+import "time"
+
+// DeviceOwner describes whose library a device reads and writes. Known is
+// false for devices missing from the devices table. TokenVersion is the
+// version its current token must carry.
+type DeviceOwner struct {
+	DeviceID     string
+	AccountID    string
+	LibraryID    string
+	Known        bool
+	Revoked      bool
+	TokenVersion int
+}
+
+// Key is the device_id user data is stored under: the account, else the
+// shared library, else the device itself.
+func (o DeviceOwner) Key() string {
+	switch {
+	case o.AccountID != "":
+		return o.AccountID
+	case o.LibraryID != "":
+		return o.LibraryID
+	default:
+		return o.DeviceID
+	}
+}
+
+type Device struct {
+	ID          string    `json:"id"`
+	CreatedFrom string    `json:"created_from"`
+	CreatedAt   time.Time `json:"created_at"`
+	Current     bool      `json:"current"`
+}
+
+// DeviceToken is the credential a device sends as "Authorization: Device
+// <token>".
+type DeviceToken struct {
+	ID        string    `json:"id"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type PairingCode struct {
+	Code      string    `json:"code"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// MergeStats counts what a merge did to one kind of entity in the target.
+type MergeStats struct {
+	Added     int `json:"added"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+}
+
+type MergeReport struct {
+	Timecodes   MergeStats `json:"timecodes"`
+	History     MergeStats `json:"history"`
+	Collections MergeStats `json:"collections"`
+	Favourites  MergeStats `json:"favourites"`
+}
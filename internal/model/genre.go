@@ -5,3 +5,13 @@ type Genre struct {
 	Name          string `json:"name"`
 	TotalReleases int    `json:"total_releases"`
 }
+
+type MALGenre struct {
+	ID    int    `json:"mal_id"`
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+type MALGenres struct {
+	Data []MALGenre `json:"data"`
+}
@@ -9,4 +9,7 @@ type History struct {
 	LastWatchedEpisode int        `json:"last_watched"`
 	IsWatched          bool       `json:"is_watched"`
 	WatchedAt          *time.Time `json:"watched_at"`
+	// Reset lets a write move LastWatchedEpisode back, e.g. for a rewatch.
+	// Without it history only moves forward.
+	Reset bool `json:"reset,omitempty"`
 }
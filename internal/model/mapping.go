@@ -0,0 +1,7 @@
+package model
+
+type AnimeMapping struct {
+	MalID       int    `json:"malID,omitempty"`
+	AnilibriaID string `json:"anilibria_id,omitempty"`
+	ConsumetID  string `json:"consumet_id,omitempty"`
+}
@@ -0,0 +1,38 @@
+package model
+
+import "time"
+
+// ContinueWatching is a title a device is part way through, with the episode
+// to play next and where to resume it. EpisodeID is empty when the episode
+// list could not be loaded.
+type ContinueWatching struct {
+	AnimeID     string    `json:"anime_id"`
+	Title       string    `json:"title,omitempty"`
+	Poster      string    `json:"image,omitempty"`
+	EpisodeID   string    `json:"episode_id,omitempty"`
+	Ordinal     int       `json:"ordinal"`
+	Time        int       `json:"time"`
+	LastWatched int       `json:"last_watched"`
+	WatchedAt   time.Time `json:"watched_at"`
+}
+
+// Statuses of a NextEpisode.
+const (
+	NextReady    = "next"
+	NextWaiting  = "waiting"
+	NextFinished = "finished"
+)
+
+// NextEpisode is what a device should play next for an anime. With status
+// "next", Episode is the first unwatched episode after LastWatched and Time
+// where to resume it. "waiting" means that episode hasn't been released yet,
+// or not in the requested audio; "finished" that the show has ended and
+// everything was watched.
+type NextEpisode struct {
+	AnimeID       string          `json:"anime_id"`
+	Status        string          `json:"status"`
+	Episode       *PreviewEpisode `json:"episode,omitempty"`
+	Time          int             `json:"time"`
+	LastWatched   int             `json:"last_watched"`
+	TotalEpisodes int             `json:"total_episodes"`
+}
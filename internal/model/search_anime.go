@@ -2,9 +2,29 @@ package model
 
 type SearchAnime struct {
 	ID         string `json:"id"`
+	MalID      int    `json:"malID,omitempty"`
 	Title      string `json:"title"`
 	Poster     string `json:"image"`
 	Year       int    `json:"year"`
 	Type       string `json:"type"`
 	ParserType string `json:"parser_type"`
 }
+
+type ProviderAnime struct {
+	Provider string `json:"provider"`
+	ID       string `json:"id"`
+}
+
+type MergedSearchAnime struct {
+	MalID     int             `json:"malID,omitempty"`
+	Title     string          `json:"title"`
+	Poster    string          `json:"image"`
+	Year      int             `json:"year"`
+	Type      string          `json:"type"`
+	Providers []ProviderAnime `json:"providers"`
+}
+
+type MergedSearchResult struct {
+	Data            []MergedSearchAnime `json:"data"`
+	FailedProviders []string            `json:"failed_providers"`
+}
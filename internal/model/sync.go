@@ -0,0 +1,51 @@
+package model
+
+import "time"
+
+// SyncChanges is everything a library changed since a sync cursor. When Reset
+// is set the cursor could not be used, the lists hold the full library and
+// the client should replace its local copy.
+type SyncChanges struct {
+	Cursor      string           `json:"cursor"`
+	Reset       bool             `json:"reset"`
+	Timecodes   []SyncTimecode   `json:"timecodes"`
+	History     []SyncHistory    `json:"history"`
+	Collections []SyncCollection `json:"collections"`
+	Favourites  []SyncFavourite  `json:"favourites"`
+	Deleted     SyncDeleted      `json:"deleted"`
+}
+
+type SyncTimecode struct {
+	Timecode
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type SyncHistory struct {
+	History
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type SyncCollection struct {
+	Collection
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type SyncFavourite struct {
+	Favourite
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type SyncDeleted struct {
+	Timecodes   []SyncTombstone `json:"timecodes"`
+	History     []SyncTombstone `json:"history"`
+	Collections []SyncTombstone `json:"collections"`
+	Favourites  []SyncTombstone `json:"favourites"`
+}
+
+// SyncTombstone identifies a deleted row. EpisodeID is only set for
+// timecodes.
+type SyncTombstone struct {
+	AnimeID   string    `json:"anime_id"`
+	EpisodeID string    `json:"episode_id,omitempty"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
@@ -0,0 +1,47 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rate allows Limit requests per Per interval.
+type Rate struct {
+	Limit int
+	Per   time.Duration
+}
+
+// ParseRates parses a comma-separated list such as "3/s,60/m". Units are
+// s, m and h. An empty string means no limit.
+func ParseRates(spec string) ([]Rate, error) {
+	var rates []Rate
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		count, unit, found := strings.Cut(part, "/")
+		if !found {
+			return nil, fmt.Errorf("invalid rate %q: expected <count>/<unit>", part)
+		}
+		limit, err := strconv.Atoi(strings.TrimSpace(count))
+		if err != nil || limit <= 0 {
+			return nil, fmt.Errorf("invalid rate %q: count must be a positive integer", part)
+		}
+		var per time.Duration
+		switch strings.TrimSpace(unit) {
+		case "s":
+			per = time.Second
+		case "m":
+			per = time.Minute
+		case "h":
+			per = time.Hour
+		default:
+			return nil, fmt.Errorf("invalid rate %q: unit must be s, m or h", part)
+		}
+		rates = append(rates, Rate{Limit: limit, Per: per})
+	}
+	return rates, nil
+}
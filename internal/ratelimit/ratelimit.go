@@ -0,0 +1,150 @@
+// Package ratelimit counts requests per client in sliding windows, in Redis
+// when configured so limits hold across instances, otherwise in process.
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Result describes the most restrictive window after a request was counted.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is when the window frees up a slot again. For denied requests it
+	// is how long to wait before retrying.
+	Reset time.Duration
+}
+
+// Limiter admits a request for key if it fits every rate. Denied requests are
+// not counted.
+type Limiter interface {
+	Allow(ctx context.Context, key string, rates []Rate) (Result, error)
+	Name() string
+}
+
+// New returns a Redis-backed limiter, or an in-memory one when client is nil.
+func New(client *redis.Client) Limiter {
+	if client == nil {
+		return newMemoryLimiter()
+	}
+	return &redisLimiter{client: client}
+}
+
+// sweepEvery is how many calls the memory limiter handles between dropping
+// idle clients.
+const sweepEvery = 1024
+
+type memoryClient struct {
+	windows [][]time.Time
+	longest time.Duration
+}
+
+type memoryLimiter struct {
+	mu      sync.Mutex
+	clients map[string]*memoryClient
+	calls   int
+}
+
+func newMemoryLimiter() *memoryLimiter {
+	return &memoryLimiter{clients: make(map[string]*memoryClient)}
+}
+
+func (l *memoryLimiter) Name() string { return "memory" }
+
+func (l *memoryLimiter) Allow(_ context.Context, key string, rates []Rate) (Result, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.calls++
+	if l.calls%sweepEvery == 0 {
+		l.sweep(now)
+	}
+
+	client, ok := l.clients[key]
+	if !ok || len(client.windows) != len(rates) {
+		client = &memoryClient{windows: make([][]time.Time, len(rates))}
+		for _, rate := range rates {
+			client.longest = max(client.longest, rate.Per)
+		}
+		l.clients[key] = client
+	}
+	windows := client.windows
+
+	allowed := true
+	for i, rate := range rates {
+		windows[i] = trim(windows[i], now.Add(-rate.Per))
+		if len(windows[i]) >= rate.Limit {
+			allowed = false
+		}
+	}
+	if allowed {
+		for i := range windows {
+			windows[i] = append(windows[i], now)
+		}
+	}
+
+	return summarize(allowed, now, rates, func(i int) (int, time.Time) {
+		if len(windows[i]) == 0 {
+			return 0, now
+		}
+		return len(windows[i]), windows[i][0]
+	}), nil
+}
+
+// sweep drops clients with no requests in their longest window.
+func (l *memoryLimiter) sweep(now time.Time) {
+	for key, client := range l.clients {
+		idle := true
+		for _, w := range client.windows {
+			if len(w) > 0 && now.Sub(w[len(w)-1]) < client.longest {
+				idle = false
+				break
+			}
+		}
+		if idle {
+			delete(l.clients, key)
+		}
+	}
+}
+
+func trim(times []time.Time, cutoff time.Time) []time.Time {
+	i := 0
+	for i < len(times) && !times[i].After(cutoff) {
+		i++
+	}
+	return times[i:]
+}
+
+// summarize reports the window with the fewest remaining requests. window
+// returns how many requests window i holds and when its oldest one was made.
+func summarize(allowed bool, now time.Time, rates []Rate, window func(i int) (int, time.Time)) Result {
+	res := Result{Allowed: allowed, Remaining: -1}
+	for i, rate := range rates {
+		count, oldest := window(i)
+		remaining := max(rate.Limit-count, 0)
+		reset := oldest.Add(rate.Per).Sub(now)
+		if !allowed {
+			if count < rate.Limit {
+				continue
+			}
+			// The retry time is set by the window that stays full longest.
+			if res.Remaining == -1 || reset > res.Reset {
+				res.Limit, res.Remaining, res.Reset = rate.Limit, 0, reset
+			}
+			continue
+		}
+		if res.Remaining == -1 || remaining < res.Remaining {
+			res.Limit, res.Remaining, res.Reset = rate.Limit, remaining, reset
+		}
+	}
+	if res.Remaining == -1 {
+		res.Remaining = 0
+	}
+	return res
+}
@@ -0,0 +1,121 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrim(t *testing.T) {
+	base := time.Unix(1760000000, 0)
+	at := func(seconds ...int) []time.Time {
+		times := make([]time.Time, len(seconds))
+		for i, s := range seconds {
+			times[i] = base.Add(time.Duration(s) * time.Second)
+		}
+		return times
+	}
+
+	tests := []struct {
+		name   string
+		times  []time.Time
+		cutoff time.Time
+		want   []time.Time
+	}{
+		{"empty", nil, base, at()},
+		{"all newer", at(1, 2, 3), base, at(1, 2, 3)},
+		{"drops older", at(1, 2, 3), base.Add(2 * time.Second), at(3)},
+		{"drops equal to cutoff", at(1, 2), base.Add(time.Second), at(2)},
+		{"all older", at(1, 2), base.Add(5 * time.Second), at()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := trim(tt.times, tt.cutoff)
+			if len(tt.want) == 0 {
+				assert.Empty(t, got)
+				return
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	now := time.Unix(1760000000, 0)
+	rates := []Rate{{Limit: 3, Per: time.Second}, {Limit: 10, Per: time.Minute}}
+
+	type window struct {
+		count int
+		age   time.Duration
+	}
+
+	tests := []struct {
+		name    string
+		allowed bool
+		windows []window
+		want    Result
+	}{
+		{
+			name:    "fewest remaining wins",
+			allowed: true,
+			windows: []window{{1, 0}, {9, 10 * time.Second}},
+			want:    Result{Allowed: true, Limit: 10, Remaining: 1, Reset: 50 * time.Second},
+		},
+		{
+			name:    "short window is tighter",
+			allowed: true,
+			windows: []window{{2, 500 * time.Millisecond}, {2, 0}},
+			want:    Result{Allowed: true, Limit: 3, Remaining: 1, Reset: 500 * time.Millisecond},
+		},
+		{
+			name:    "denied by the short window",
+			allowed: false,
+			windows: []window{{3, 200 * time.Millisecond}, {5, 0}},
+			want:    Result{Limit: 3, Remaining: 0, Reset: 800 * time.Millisecond},
+		},
+		{
+			name:    "denied retries after the longest full window",
+			allowed: false,
+			windows: []window{{3, 200 * time.Millisecond}, {10, 30 * time.Second}},
+			want:    Result{Limit: 10, Remaining: 0, Reset: 30 * time.Second},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := summarize(tt.allowed, now, rates, func(i int) (int, time.Time) {
+				return tt.windows[i].count, now.Add(-tt.windows[i].age)
+			})
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSummarizeNoRates(t *testing.T) {
+	got := summarize(true, time.Now(), nil, nil)
+	assert.Equal(t, Result{Allowed: true}, got)
+}
+
+func TestMemoryLimiter(t *testing.T) {
+	l := newMemoryLimiter()
+	ctx := context.Background()
+	rates := []Rate{{Limit: 2, Per: time.Minute}}
+
+	for remaining := 1; remaining >= 0; remaining-- {
+		res, err := l.Allow(ctx, "client", rates)
+		require.NoError(t, err)
+		assert.True(t, res.Allowed)
+		assert.Equal(t, remaining, res.Remaining)
+	}
+
+	res, err := l.Allow(ctx, "client", rates)
+	require.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.Positive(t, res.Reset)
+
+	res, err = l.Allow(ctx, "other", rates)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+}
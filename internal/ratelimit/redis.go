@@ -0,0 +1,91 @@
+package ratelimit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// slidingWindow keeps one sorted set of request times (in ms) per window.
+// KEYS are the windows, ARGV is now, a unique member, then limit and window
+// length for each key. A request is recorded in every window or in none. It
+// returns whether it was allowed, then the count and oldest time per window.
+var slidingWindow = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local member = ARGV[2]
+local counts = {}
+local allowed = 1
+for i = 1, #KEYS do
+  local limit = tonumber(ARGV[1 + 2 * i])
+  local window = tonumber(ARGV[2 + 2 * i])
+  redis.call('ZREMRANGEBYSCORE', KEYS[i], '-inf', now - window)
+  counts[i] = redis.call('ZCARD', KEYS[i])
+  if counts[i] >= limit then
+    allowed = 0
+  end
+end
+local out = {allowed}
+for i = 1, #KEYS do
+  if allowed == 1 then
+    redis.call('ZADD', KEYS[i], now, member)
+    redis.call('PEXPIRE', KEYS[i], tonumber(ARGV[2 + 2 * i]))
+    counts[i] = counts[i] + 1
+  end
+  local first = now
+  local oldest = redis.call('ZRANGE', KEYS[i], 0, 0, 'WITHSCORES')
+  if #oldest > 0 then
+    first = tonumber(oldest[2])
+  end
+  table.insert(out, counts[i])
+  table.insert(out, first)
+end
+return out
+`)
+
+type redisLimiter struct {
+	client *redis.Client
+}
+
+func (l *redisLimiter) Name() string { return "redis" }
+
+func (l *redisLimiter) Allow(ctx context.Context, key string, rates []Rate) (Result, error) {
+	now := time.Now()
+	nowMs := now.UnixMilli()
+
+	member, err := uniqueMember(nowMs)
+	if err != nil {
+		return Result{}, err
+	}
+
+	keys := make([]string, len(rates))
+	args := []any{nowMs, member}
+	for i, rate := range rates {
+		keys[i] = "ratelimit:" + key + ":" + strconv.Itoa(rate.Limit) + "/" + rate.Per.String()
+		args = append(args, rate.Limit, rate.Per.Milliseconds())
+	}
+
+	values, err := slidingWindow.Run(ctx, l.client, keys, args...).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	if len(values) != 1+2*len(rates) {
+		return Result{}, fmt.Errorf("unexpected rate limit reply of %d values", len(values))
+	}
+
+	return summarize(values[0] == 1, now, rates, func(i int) (int, time.Time) {
+		return int(values[1+2*i]), time.UnixMilli(values[2+2*i])
+	}), nil
+}
+
+func uniqueMember(nowMs int64) (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(nowMs, 10) + "-" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/astanx/anime_api/internal/db"
+	"github.com/astanx/anime_api/internal/model"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	ErrEmailTaken      = errors.New("email already registered")
+	ErrAccountNotFound = errors.New("account not found")
+)
+
+type AccountRepo struct {
+	dbPostgres *sql.DB
+}
+
+func NewAccountRepo(db *db.DB) *AccountRepo {
+	return &AccountRepo{
+		dbPostgres: db.Postgres,
+	}
+}
+
+// CreateAccount stores a new account. When deviceID is set the device is
+// linked to the account and its existing data moves over to it. The devices
+// whose owner changed are returned.
+func (r *AccountRepo) CreateAccount(ctx context.Context, id, email, passwordHash, deviceID string) (model.Account, []string, error) {
+	tx, err := r.dbPostgres.BeginTx(ctx, nil)
+	if err != nil {
+		return model.Account{}, nil, err
+	}
+	defer tx.Rollback()
+
+	var account model.Account
+	err = tx.QueryRowContext(ctx,
+		"INSERT INTO accounts (id, email, password_hash) VALUES ($1, $2, $3) RETURNING id, email, created_at",
+		id, email, passwordHash,
+	).Scan(&account.ID, &account.Email, &account.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return model.Account{}, nil, ErrEmailTaken
+		}
+		return model.Account{}, nil, err
+	}
+
+	var linked []string
+	if deviceID != "" {
+		var current model.DeviceOwner
+		err := tx.QueryRowContext(ctx,
+			"SELECT COALESCE(account_id, ''), COALESCE(library_id, '') FROM devices WHERE device_id = $1 FOR UPDATE",
+			deviceID,
+		).Scan(&current.AccountID, &current.LibraryID)
+		if err != nil && err != sql.ErrNoRows {
+			return model.Account{}, nil, err
+		}
+
+		// A device already owned by another account keeps that account's data
+		// where it is. Otherwise its library, and every device sharing it,
+		// moves to the new account.
+		if current.AccountID == "" {
+			current.DeviceID = deviceID
+			if err := moveOwnerData(ctx, tx, current.Key(), account.ID); err != nil {
+				return model.Account{}, nil, err
+			}
+			if current.LibraryID != "" {
+				linked, err = queryStrings(ctx, tx,
+					"UPDATE devices SET account_id = $1, library_id = NULL WHERE library_id = $2 RETURNING device_id",
+					account.ID, current.LibraryID,
+				)
+				if err != nil {
+					return model.Account{}, nil, err
+				}
+			}
+		}
+
+		if err := linkDevice(ctx, tx, deviceID, account.ID); err != nil {
+			return model.Account{}, nil, err
+		}
+		linked = append(linked, deviceID)
+	}
+
+	return account, linked, tx.Commit()
+}
+
+// GetAccountByEmail returns the account and its password hash.
+func (r *AccountRepo) GetAccountByEmail(ctx context.Context, email string) (model.Account, string, error) {
+	var account model.Account
+	var passwordHash string
+	err := r.dbPostgres.QueryRowContext(ctx,
+		"SELECT id, email, created_at, password_hash FROM accounts WHERE email = $1",
+		email,
+	).Scan(&account.ID, &account.Email, &account.CreatedAt, &passwordHash)
+	if err == sql.ErrNoRows {
+		return model.Account{}, "", ErrAccountNotFound
+	}
+	return account, passwordHash, err
+}
+
+func (r *AccountRepo) GetAccount(ctx context.Context, id string) (model.Account, error) {
+	var account model.Account
+	err := r.dbPostgres.QueryRowContext(ctx,
+		"SELECT id, email, created_at FROM accounts WHERE id = $1",
+		id,
+	).Scan(&account.ID, &account.Email, &account.CreatedAt)
+	if err == sql.ErrNoRows {
+		return model.Account{}, ErrAccountNotFound
+	}
+	return account, err
+}
+
+// LinkDevice makes accountID the owner of deviceID. The device's own data is
+// left as is.
+func (r *AccountRepo) LinkDevice(ctx context.Context, deviceID, accountID string) error {
+	return linkDevice(ctx, r.dbPostgres, deviceID, accountID)
+}
@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/astanx/anime_api/internal/model"
+)
+
+type AnilibriaProvider struct {
+	repo *AnimeRepo
+}
+
+func NewAnilibriaProvider(repo *AnimeRepo) *AnilibriaProvider {
+	return &AnilibriaProvider{repo: repo}
+}
+
+func (p *AnilibriaProvider) Name() string {
+	return "anilibria"
+}
+
+func (p *AnilibriaProvider) Search(ctx context.Context, query string, page int) (model.PaginatedSearchAnime, error) {
+	return p.repo.SearchAnilibriaAnime(ctx, query, page)
+}
+
+func (p *AnilibriaProvider) Latest(ctx context.Context, limit, page int) ([]model.SearchAnime, error) {
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+	return p.repo.SearchAnilibriaLatestReleases(ctx, limit, page)
+}
+
+func (p *AnilibriaProvider) Recommended(ctx context.Context, limit, page int) ([]model.SearchAnime, error) {
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+	return p.repo.SearchAnilibriaRecommendedAnime(ctx, limit, page)
+}
+
+func (p *AnilibriaProvider) Genres(ctx context.Context) ([]model.Genre, error) {
+	return p.repo.GetAnilibriaGenres(ctx)
+}
+
+func (p *AnilibriaProvider) GenreReleases(ctx context.Context, genre string, limit, page int) (model.PaginatedSearchAnime, error) {
+	genreID, err := strconv.Atoi(genre)
+	if err != nil {
+		return model.PaginatedSearchAnime{}, fmt.Errorf("anilibria genre must be an integer id: %w", err)
+	}
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+	return p.repo.SearchAnilibriaGenreReleases(ctx, genreID, limit, page)
+}
+
+func (p *AnilibriaProvider) AnimeInfo(ctx context.Context, id string) (model.Anime, error) {
+	return p.repo.GetAnimeInfoByAnilibriaID(ctx, id)
+}
+
+func (p *AnilibriaProvider) EpisodeInfo(ctx context.Context, id string, params EpisodeParams) (model.Episode, error) {
+	return p.repo.GetAnilibriaEpisodeInfo(ctx, id)
+}
@@ -3,55 +3,31 @@ package repository
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
 	"fmt"
-	"io"
-	"log"
-	"net/http"
+	"log/slog"
 	"net/url"
+	"time"
 
-	clickhouse "github.com/ClickHouse/clickhouse-go/v2"
-	"github.com/astanx/anime_api/internal/config"
+	"github.com/astanx/anime_api/internal/analytics"
 	"github.com/astanx/anime_api/internal/model"
+	"github.com/astanx/anime_api/internal/upstream"
 )
 
 // --- HTTP helper ---
 
-func doJSONRequest(url string, target any) error {
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return err
-	}
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return err
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("Request failed [%d]: %s\n", resp.StatusCode, body)
-		return fmt.Errorf("request failed with status %d", resp.StatusCode)
-	}
-
-	return json.Unmarshal(body, target)
+func doJSONRequest(ctx context.Context, url string, target any) error {
+	return upstream.Default().GetJSON(ctx, url, target)
 }
 
-func fetchConsumet(endpoint string, page int) (model.PaginatedSearchAnime, error) {
+func fetchConsumet(ctx context.Context, consumetURL, endpoint string, page int) (model.PaginatedSearchAnime, error) {
 	if page <= 0 {
 		page = 1
 	}
 
-	url := fmt.Sprintf("%s/anime/hianime/%s?page=%d", config.ConsumetUrl, endpoint, page)
+	url := fmt.Sprintf("%s/anime/hianime/%s?page=%d", consumetURL, endpoint, page)
 
 	var res model.PaginatedConsumetSearchAnime
-	if err := doJSONRequest(url, &res); err != nil {
+	if err := doJSONRequest(ctx, url, &res); err != nil {
 		return model.PaginatedSearchAnime{}, err
 	}
 	return model.PaginatedSearchAnime{
@@ -64,12 +40,12 @@ func fetchConsumet(endpoint string, page int) (model.PaginatedSearchAnime, error
 	}, nil
 }
 
-func fetchAnilibriaReleases(endpoint string, query string, limit, page int) (model.PaginatedSearchAnime, error) {
+func fetchAnilibriaReleases(ctx context.Context, anilibriaURL, endpoint string, query string, limit, page int) (model.PaginatedSearchAnime, error) {
 	if page <= 0 {
 		page = 1
 	}
 
-	baseURL := fmt.Sprintf("https://aniliberty.top/api/v1/%s?include=id,type.value,year,poster.src,name.main&page=%d", endpoint, page)
+	baseURL := fmt.Sprintf("%s/api/v1/%s?include=id,type.value,year,poster.src,name.main&page=%d", anilibriaURL, endpoint, page)
 
 	if query != "" {
 		baseURL += "&query=" + url.QueryEscape(query)
@@ -93,7 +69,7 @@ func fetchAnilibriaReleases(endpoint string, query string, limit, page int) (mod
 		} `json:"poster"`
 	}
 
-	if err := doJSONRequest(baseURL, &rawArray); err != nil {
+	if err := doJSONRequest(ctx, baseURL, &rawArray); err != nil {
 		return model.PaginatedSearchAnime{}, err
 	}
 
@@ -114,7 +90,7 @@ func fetchAnilibriaReleases(endpoint string, query string, limit, page int) (mod
 		result.Data = append(result.Data, model.SearchAnime{
 			ID:         fmt.Sprint(a.ID),
 			Title:      a.Name.Main,
-			Poster:     "https://aniliberty.top" + poster,
+			Poster:     anilibriaURL + poster,
 			Year:       a.Year,
 			Type:       a.Type.Value,
 			ParserType: "Anilibria",
@@ -124,20 +100,19 @@ func fetchAnilibriaReleases(endpoint string, query string, limit, page int) (mod
 	return result, nil
 }
 
-func fetchConsumetReleases(endpoint string) ([]model.SearchAnime, error) {
-	baseURL := fmt.Sprintf("%s/anime/hianime/%s", config.ConsumetUrl, endpoint)
-
-	var rawResult struct {
-		Results []model.SearchAnime `json:"results"`
-	}
-	if err := doJSONRequest(baseURL, &rawResult); err != nil {
-		return nil, err
+func fetchConsumetReleases(ctx context.Context, consumetURL, endpoint string, page int) (model.PaginatedSearchAnime, error) {
+	raw, err := fetchConsumet(ctx, consumetURL, endpoint, page)
+	if err != nil {
+		return model.PaginatedSearchAnime{}, err
 	}
 
-	result := make([]model.SearchAnime, 0, len(rawResult.Results))
+	result := model.PaginatedSearchAnime{
+		Data: make([]model.SearchAnime, 0, len(raw.Data)),
+		Meta: raw.Meta,
+	}
 
-	for _, a := range rawResult.Results {
-		result = append(result, model.SearchAnime{
+	for _, a := range raw.Data {
+		result.Data = append(result.Data, model.SearchAnime{
 			ID:         a.ID,
 			Title:      a.Title,
 			Poster:     a.Poster,
@@ -152,52 +127,52 @@ func fetchConsumetReleases(endpoint string) ([]model.SearchAnime, error) {
 
 // --- PostgreSQL helpers ---
 
-func checkExists(db *sql.DB, id string) bool {
+func checkExists(ctx context.Context, db *sql.DB, id string) bool {
 	var exists bool
-	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM search WHERE id = $1)", id).Scan(&exists)
+	err := db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM search WHERE id = $1)", id).Scan(&exists)
 	if err != nil {
-		log.Printf("Error checking anime existence: %v", err)
+		slog.ErrorContext(ctx, "failed to check anime existence", "id", id, "error", err)
 		return true
 	}
 	return exists
 }
 
-func insertSearchAnime(db *sql.DB, anime model.SearchAnime) {
-	_, err := db.Exec(
+func insertSearchAnime(ctx context.Context, db *sql.DB, anime model.SearchAnime) {
+	_, err := db.ExecContext(ctx,
 		"INSERT INTO search (id, title, year, poster, type, parser_type) VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT (id) DO NOTHING",
 		anime.ID, anime.Title, anime.Year, anime.Poster, anime.Type, anime.ParserType,
 	)
 	if err != nil {
-		log.Printf("Error inserting anime: %v", err)
+		slog.ErrorContext(ctx, "failed to insert anime", "id", anime.ID, "error", err)
 	}
 }
 
-func insertEpisode(db *sql.DB, episode model.Episode) {
-	_, e := db.Exec("INSERT INTO episodes (id, ordinal, title, opening_start, opening_end, ending_start, ending_end) VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT (id) DO NOTHING",
+func insertEpisode(ctx context.Context, db *sql.DB, episode model.Episode) {
+	_, e := db.ExecContext(ctx, "INSERT INTO episodes (id, ordinal, title, opening_start, opening_end, ending_start, ending_end) VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT (id) DO NOTHING",
 		episode.ID, episode.Ordinal, episode.Title,
 		episode.Opening.Start, episode.Opening.End,
 		episode.Ending.Start, episode.Ending.End)
 	if e != nil {
-		log.Printf("Error inserting anime: %v", e)
+		slog.ErrorContext(ctx, "failed to insert episode", "id", episode.ID, "error", e)
 	}
 	for _, source := range episode.Sources {
-		_, e = db.Exec("INSERT INTO episode_sources (episode_id, url, type) VALUES ($1, $2, $3) ON CONFLICT (id) DO NOTHING", episode.ID, source.Url, source.Type)
+		_, e = db.ExecContext(ctx, "INSERT INTO episode_sources (episode_id, url, type) VALUES ($1, $2, $3) ON CONFLICT (id) DO NOTHING", episode.ID, source.Url, source.Type)
 		if e != nil {
-			log.Printf("Error inserting source: %v", e)
+			slog.ErrorContext(ctx, "failed to insert episode source", "id", episode.ID, "error", e)
 		}
 	}
 
 	for _, subtitle := range episode.Subtitles {
-		_, e = db.Exec("INSERT INTO episode_subtitles (episode_id, vtt, language) VALUES ($1, $2, $3) ON CONFLICT (id) DO NOTHING", episode.ID, subtitle.Vtt, subtitle.Language)
+		_, e = db.ExecContext(ctx, "INSERT INTO episode_subtitles (episode_id, vtt, language) VALUES ($1, $2, $3) ON CONFLICT (id) DO NOTHING", episode.ID, subtitle.Vtt, subtitle.Language)
 		if e != nil {
-			log.Printf("Error inserting subtitle: %v", e)
+			slog.ErrorContext(ctx, "failed to insert episode subtitle", "id", episode.ID, "error", e)
 		}
 	}
 }
 
-func getEpisode(db *sql.DB, id string) (model.Episode, bool, error) {
+func getEpisode(ctx context.Context, db *sql.DB, id string) (model.Episode, bool, error) {
 	var episode model.Episode
-	row := db.QueryRow(`
+	row := db.QueryRowContext(ctx, `
 		SELECT id, ordinal, title,
 		       opening_start, opening_end,
 		       ending_start, ending_end
@@ -227,7 +202,7 @@ func getEpisode(db *sql.DB, id string) (model.Episode, bool, error) {
 		End:   int(endingEnd.Int64),
 	}
 
-	rows, err := db.Query(`SELECT url, type FROM episode_sources WHERE episode_id = $1`, id)
+	rows, err := db.QueryContext(ctx, `SELECT url, type FROM episode_sources WHERE episode_id = $1`, id)
 	if err == nil {
 		defer rows.Close()
 		for rows.Next() {
@@ -238,7 +213,7 @@ func getEpisode(db *sql.DB, id string) (model.Episode, bool, error) {
 		}
 	}
 
-	srows, err := db.Query(`SELECT vtt, language FROM episode_subtitles WHERE episode_id = $1`, id)
+	srows, err := db.QueryContext(ctx, `SELECT vtt, language FROM episode_subtitles WHERE episode_id = $1`, id)
 	if err == nil {
 		defer rows.Close()
 		for srows.Next() {
@@ -253,15 +228,16 @@ func getEpisode(db *sql.DB, id string) (model.Episode, bool, error) {
 
 }
 
-// --- ClickHouse helper ---
+// --- Analytics helper ---
 
-func logSearchClickhouse(conn clickhouse.Conn, query, parserType string, resultCount int) {
-	err := conn.Exec(
-		context.Background(),
-		"INSERT INTO search_analytics (query, type, results, searched_at) VALUES (?, ?, ?, now())",
-		query, parserType, resultCount,
-	)
+func logSearch(ctx context.Context, sink analytics.Sink, query, parserType string, resultCount int) {
+	err := sink.Record(ctx, analytics.SearchEvent{
+		Query:      query,
+		Type:       parserType,
+		Results:    resultCount,
+		SearchedAt: time.Now(),
+	})
 	if err != nil {
-		log.Println("ClickHouse insert failed:", err)
+		slog.ErrorContext(ctx, "analytics insert failed", "error", err)
 	}
 }
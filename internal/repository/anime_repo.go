@@ -3,563 +3,479 @@ package repository
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
 	"fmt"
+	"log/slog"
 	"strconv"
-	"time"
 
-	clickhouse "github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/astanx/anime_api/internal/analytics"
+	"github.com/astanx/anime_api/internal/cache"
 	"github.com/astanx/anime_api/internal/config"
 	"github.com/astanx/anime_api/internal/db"
 	"github.com/astanx/anime_api/internal/model"
-	"github.com/redis/go-redis/v9"
 )
 
 type AnimeRepo struct {
-	dbPostgres   *sql.DB
-	dbClickhouse clickhouse.Conn
-	dbRedis      *redis.Client
+	dbPostgres *sql.DB
+	analytics  analytics.Sink
+	cache      *cache.Cache
+	endpoints  config.UpstreamConfig
 }
 
-func NewAnimeRepo(db *db.DB) *AnimeRepo {
+func NewAnimeRepo(db *db.DB, cache *cache.Cache, endpoints config.UpstreamConfig) *AnimeRepo {
 	return &AnimeRepo{
-		dbPostgres:   db.Postgres,
-		dbClickhouse: db.ClickHouse,
-		dbRedis:      db.Redis,
+		dbPostgres: db.Postgres,
+		analytics:  db.Analytics,
+		cache:      cache,
+		endpoints:  endpoints,
 	}
 }
 
-func (r *AnimeRepo) SearchAnimeByID(id string) (model.SearchAnime, error) {
-	ctx := context.Background()
+func (r *AnimeRepo) SearchAnimeByID(ctx context.Context, id string) (model.SearchAnime, error) {
 	cacheKey := fmt.Sprintf("anime:search:id:%s", id)
 
-	cached, err := r.dbRedis.Get(ctx, cacheKey).Result()
-	if err == nil {
+	return cache.Fetch(ctx, r.cache, cacheKey, r.cache.TTL().Lookup, func(ctx context.Context) (model.SearchAnime, error) {
+		row := r.dbPostgres.QueryRowContext(ctx, "SELECT id, title, year, poster, type, parser_type FROM search WHERE id = $1", id)
 		var anime model.SearchAnime
-		if err := json.Unmarshal([]byte(cached), &anime); err == nil {
-			return anime, nil
+		err := row.Scan(&anime.ID, &anime.Title, &anime.Year, &anime.Poster, &anime.Type, &anime.ParserType)
+		if err != nil {
+			return model.SearchAnime{}, err
 		}
+
+		return anime, nil
+	})
+}
+
+// GetAnimeInfoByID looks id up on Anilibria when it is numeric and on
+// Consumet otherwise.
+func (r *AnimeRepo) GetAnimeInfoByID(ctx context.Context, id string) (model.Anime, error) {
+	if isAnilibriaID(id) {
+		return r.GetAnimeInfoByAnilibriaID(ctx, id)
 	}
-	row := r.dbPostgres.QueryRow("SELECT id, title, year, poster, type, parser_type FROM search WHERE id = $1", id)
-	var anime model.SearchAnime
-	err = row.Scan(&anime.ID, &anime.Title, &anime.Year, &anime.Poster, &anime.Type, &anime.ParserType)
-	if err != nil {
-		return model.SearchAnime{}, err
-	}
-	animeJSON, _ := json.Marshal(anime)
-	r.dbRedis.Set(ctx, cacheKey, animeJSON, 1*time.Hour)
+	return r.GetAnimeInfoByConsumetID(ctx, id)
+}
+
+// SingleAudio reports whether the provider of id offers one audio track per
+// episode, so its sub and dub flags say nothing about availability.
+// Anilibria only has its own dub.
+func (r *AnimeRepo) SingleAudio(id string) bool {
+	return isAnilibriaID(id)
+}
 
-	return anime, nil
+func isAnilibriaID(id string) bool {
+	_, err := strconv.Atoi(id)
+	return err == nil
 }
 
-func (r *AnimeRepo) GetAnimeInfoByConsumetID(id string) (model.Anime, error) {
-	ctx := context.Background()
+func (r *AnimeRepo) GetAnimeInfoByConsumetID(ctx context.Context, id string) (model.Anime, error) {
 	cacheKey := fmt.Sprintf("anime:consumet:id:%s", id)
 
-	cached, err := r.dbRedis.Get(ctx, cacheKey).Result()
-	if err == nil {
-		var anime model.Anime
-		if err := json.Unmarshal([]byte(cached), &anime); err == nil {
-			return anime, nil
+	return cache.Fetch(ctx, r.cache, cacheKey, r.cache.TTL().Anime, func(ctx context.Context) (model.Anime, error) {
+		url := fmt.Sprintf("%s/anime/hianime/info?id=%s", r.endpoints.ConsumetURL, id)
+		var result model.ConsumetAnimeWithMAL
+		if err := doJSONRequest(ctx, url, &result); err != nil {
+			return model.Anime{}, err
 		}
-	}
-	url := fmt.Sprintf("%s/anime/hianime/info?id=%s", config.ConsumetUrl, id)
-	var result model.ConsumetAnimeWithMAL
-	if err := doJSONRequest(url, &result); err != nil {
-		return model.Anime{}, err
-	}
-	var anime = model.Anime{
-		ID:            result.ID,
-		MalID:         result.MalID,
-		Title:         result.Title,
-		Poster:        result.Image,
-		Description:   result.Description,
-		Genres:        result.Genres,
-		Status:        result.Status,
-		Type:          result.Type,
-		TotalEpisodes: result.TotalEpisodes,
-		Episodes: func() []model.PreviewEpisode {
-			episodes := make([]model.PreviewEpisode, len(result.Episodes))
-			for i, e := range result.Episodes {
-				episodes[i] = model.PreviewEpisode{
-					ID:       e.ID,
-					IsDubbed: e.IsDubbed,
-					IsSubbed: e.IsSubbed,
-					Ordinal:  e.Number,
-					Title:    e.Title,
+		var anime = model.Anime{
+			ID:            result.ID,
+			MalID:         result.MalID,
+			Title:         result.Title,
+			Poster:        result.Image,
+			Description:   result.Description,
+			Genres:        result.Genres,
+			Status:        result.Status,
+			Type:          result.Type,
+			TotalEpisodes: result.TotalEpisodes,
+			Episodes: func() []model.PreviewEpisode {
+				episodes := make([]model.PreviewEpisode, len(result.Episodes))
+				for i, e := range result.Episodes {
+					episodes[i] = model.PreviewEpisode{
+						ID:       e.ID,
+						IsDubbed: e.IsDubbed,
+						IsSubbed: e.IsSubbed,
+						Ordinal:  e.Number,
+						Title:    e.Title,
+					}
 				}
+				return episodes
+			}(),
+		}
+		if anime.MalID != 0 {
+			if err := saveMapping(ctx, r.dbPostgres, model.AnimeMapping{MalID: anime.MalID, ConsumetID: anime.ID}); err != nil {
+				slog.ErrorContext(ctx, "failed to save anime mapping", "error", err)
 			}
-			return episodes
-		}(),
-	}
-	animeJSON, _ := json.Marshal(anime)
-	r.dbRedis.Set(ctx, cacheKey, animeJSON, 4*time.Hour)
+		}
 
-	return anime, nil
+		return anime, nil
+	})
 }
 
-func (r *AnimeRepo) GetAnimeInfoByAnilibriaID(id string) (model.Anime, error) {
-	ctx := context.Background()
+func (r *AnimeRepo) GetAnimeInfoByAnilibriaID(ctx context.Context, id string) (model.Anime, error) {
 	cacheKey := fmt.Sprintf("anime:anilibria:id:%s", id)
 
-	cached, err := r.dbRedis.Get(ctx, cacheKey).Result()
-	if err == nil {
-		var anime model.Anime
-		if err := json.Unmarshal([]byte(cached), &anime); err == nil {
-			return anime, nil
+	return cache.Fetch(ctx, r.cache, cacheKey, r.cache.TTL().Anime, func(ctx context.Context) (model.Anime, error) {
+		url := fmt.Sprintf("%s/api/v1/anime/releases/%s?include=id,type.value,year,name.main,poster.src,is_ongoing,description,episodes_total,genres.name,episodes", r.endpoints.AnilibriaURL, id)
+		var result model.AnilibriaAnime
+		if err := doJSONRequest(ctx, url, &result); err != nil {
+			return model.Anime{}, err
 		}
-	}
-	url := fmt.Sprintf("https://aniliberty.top/api/v1/anime/releases/%s?include=id,type.value,year,name.main,poster.src,is_ongoing,description,episodes_total,genres.name,episodes", id)
-	var result model.AnilibriaAnime
-	if err := doJSONRequest(url, &result); err != nil {
-		return model.Anime{}, err
-	}
-
-	var anime model.Anime
-	anime.ID = fmt.Sprintf("%d", result.ID)
-	anime.Title = result.Name.Main
-	anime.Year = result.Year
-	anime.Poster =
-		fmt.Sprintf("https://aniliberty.top%s", result.Poster.Src)
-	anime.Type = result.Type.Value
-	anime.Status = "Completed"
-	if result.IsOngoing {
-		anime.Status = "Ongoing"
-	}
-	anime.Description = result.Description
-	anime.TotalEpisodes = result.EpisodesTotal
 
-	genres := make([]string, len(result.Genres))
-	for i, g := range result.Genres {
-		genres[i] = g.Name
-	}
-	anime.Genres = genres
-
-	episodes := make([]model.PreviewEpisode, len(result.Episodes))
-	for i, e := range result.Episodes {
-		episodes[i] = model.PreviewEpisode{
-			ID:       e.ID,
-			IsDubbed: true,
-			IsSubbed: false,
-			Title:    e.Name,
-			Ordinal:  e.Ordinal,
+		var anime model.Anime
+		anime.ID = fmt.Sprintf("%d", result.ID)
+		anime.Title = result.Name.Main
+		anime.Year = result.Year
+		anime.Poster = r.endpoints.AnilibriaURL + result.Poster.Src
+		anime.Type = result.Type.Value
+		anime.Status = "Completed"
+		if result.IsOngoing {
+			anime.Status = "Ongoing"
 		}
-	}
-	anime.Episodes = episodes
+		anime.Description = result.Description
+		anime.TotalEpisodes = result.EpisodesTotal
 
-	animeJSON, _ := json.Marshal(anime)
-	r.dbRedis.Set(ctx, cacheKey, animeJSON, 4*time.Hour)
+		genres := make([]string, len(result.Genres))
+		for i, g := range result.Genres {
+			genres[i] = g.Name
+		}
+		anime.Genres = genres
+
+		episodes := make([]model.PreviewEpisode, len(result.Episodes))
+		for i, e := range result.Episodes {
+			episodes[i] = model.PreviewEpisode{
+				ID:       e.ID,
+				IsDubbed: true,
+				IsSubbed: false,
+				Title:    e.Name,
+				Ordinal:  e.Ordinal,
+			}
+		}
+		anime.Episodes = episodes
 
-	return anime, nil
+		return anime, nil
+	})
 }
 
-func (r *AnimeRepo) GetAnilibriaEpisodeInfo(id string) (model.Episode, error) {
-	ctx := context.Background()
+func (r *AnimeRepo) GetAnilibriaEpisodeInfo(ctx context.Context, id string) (model.Episode, error) {
 	cacheKey := fmt.Sprintf("anime:anilibria:episode:id:%s", id)
 
-	cached, err := r.dbRedis.Get(ctx, cacheKey).Result()
-	if err == nil {
-		var episode model.Episode
-		if err := json.Unmarshal([]byte(cached), &episode); err == nil {
+	return cache.Fetch(ctx, r.cache, cacheKey, r.cache.TTL().Episode, func(ctx context.Context) (model.Episode, error) {
+		episode, exists, err := getEpisode(ctx, r.dbPostgres, id)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to get episode from db", "id", id, "error", err)
+		}
+		if exists {
 			return episode, nil
 		}
-	}
-	episode, exists, err := getEpisode(r.dbPostgres, id)
-	if err != nil {
-		fmt.Printf("Error getEpisode from db: %s", err)
-	}
-	if exists {
-		return episode, nil
-	}
-	url := fmt.Sprintf("https://aniliberty.top/api/v1/anime/releases/episodes/%s?include=id,name,ordinal,opening,ending,hls_480,hls_720,hls_1080", id)
-	var result model.AnilibriaEpisode
-	if err := doJSONRequest(url, &result); err != nil {
-		return model.Episode{}, err
-	}
-
-	episode = model.Episode{
-		ID:      result.ID,
-		Title:   result.Name,
-		Ordinal: result.Ordinal,
-		Opening: model.TimeSegment{
-			Start: result.Opening.Start,
-			End:   result.Opening.End,
-		},
-		Ending: model.TimeSegment{
-			Start: result.Ending.Start,
-			End:   result.Ending.End,
-		},
-	}
+		url := fmt.Sprintf("%s/api/v1/anime/releases/episodes/%s?include=id,name,ordinal,opening,ending,hls_480,hls_720,hls_1080", r.endpoints.AnilibriaURL, id)
+		var result model.AnilibriaEpisode
+		if err := doJSONRequest(ctx, url, &result); err != nil {
+			return model.Episode{}, err
+		}
 
-	var sources []model.Source
-	if result.Hls480 != "" {
-		sources = append(sources, model.Source{
-			Url:  result.Hls480,
-			Type: "hls480",
-		})
-	}
-	if result.Hls720 != "" {
-		sources = append(sources, model.Source{
-			Url:  result.Hls720,
-			Type: "hls720",
-		})
-	}
-	if result.Hls1080 != "" {
-		sources = append(sources, model.Source{
-			Url:  result.Hls1080,
-			Type: "hls1080",
-		})
-	}
-	episode.Sources = sources
+		episode = model.Episode{
+			ID:      result.ID,
+			Title:   result.Name,
+			Ordinal: result.Ordinal,
+			Opening: model.TimeSegment{
+				Start: result.Opening.Start,
+				End:   result.Opening.End,
+			},
+			Ending: model.TimeSegment{
+				Start: result.Ending.Start,
+				End:   result.Ending.End,
+			},
+		}
 
-	insertEpisode(r.dbPostgres, episode)
+		var sources []model.Source
+		if result.Hls480 != "" {
+			sources = append(sources, model.Source{
+				Url:  result.Hls480,
+				Type: "hls480",
+			})
+		}
+		if result.Hls720 != "" {
+			sources = append(sources, model.Source{
+				Url:  result.Hls720,
+				Type: "hls720",
+			})
+		}
+		if result.Hls1080 != "" {
+			sources = append(sources, model.Source{
+				Url:  result.Hls1080,
+				Type: "hls1080",
+			})
+		}
+		episode.Sources = sources
 
-	episodeJSON, _ := json.Marshal(episode)
-	r.dbRedis.Set(ctx, cacheKey, episodeJSON, 12*time.Hour)
+		insertEpisode(ctx, r.dbPostgres, episode)
 
-	return episode, nil
+		return episode, nil
+	})
 }
 
-func (r *AnimeRepo) GetConsumetEpisodeInfo(id, title string, ordinal int, dub string) (model.Episode, error) {
-	ctx := context.Background()
+func (r *AnimeRepo) GetConsumetEpisodeInfo(ctx context.Context, id, title string, ordinal int, dub string) (model.Episode, error) {
 	cacheKey := fmt.Sprintf("anime:consumet:episode:id:%s:dub:%s", id, dub)
 
-	cached, err := r.dbRedis.Get(ctx, cacheKey).Result()
-	if err == nil {
-		var episode model.Episode
-		if err := json.Unmarshal([]byte(cached), &episode); err == nil {
-			return episode, nil
+	return cache.Fetch(ctx, r.cache, cacheKey, r.cache.TTL().Episode, func(ctx context.Context) (model.Episode, error) {
+		var category string
+		if dub, _ := strconv.ParseBool(dub); dub {
+			category = "dub"
+		} else {
+			category = "sub"
 		}
-	}
-	var category string
-	if dub, _ := strconv.ParseBool(dub); dub {
-		category = "dub"
-	} else {
-		category = "sub"
-	}
 
-	url := fmt.Sprintf("%s/anime/hianime/watch/%s?category=%s", config.ConsumetUrl, id, category)
+		url := fmt.Sprintf("%s/anime/hianime/watch/%s?category=%s", r.endpoints.ConsumetURL, id, category)
 
-	var result model.ConsumetEpisode
-	if err := doJSONRequest(url, &result); err != nil {
-		return model.Episode{}, err
-	}
+		var result model.ConsumetEpisode
+		if err := doJSONRequest(ctx, url, &result); err != nil {
+			return model.Episode{}, err
+		}
 
-	finalTitle := title
-
-	finalOrdinal := ordinal
-
-	episode := model.Episode{
-		ID:      id,
-		Title:   finalTitle,
-		Ordinal: finalOrdinal,
-		Opening: model.TimeSegment{
-			Start: 0,
-			End:   0,
-		},
-		Ending: model.TimeSegment{
-			Start: 0,
-			End:   0,
-		},
-		Sources: []model.Source{
-			{
-				Url:  result.Iframe,
-				Type: "iframe",
-			},
-		},
-		Subtitles: []model.Subtitle{},
-	}
+		finalTitle := title
 
-	episodeJSON, _ := json.Marshal(episode)
-	r.dbRedis.Set(ctx, cacheKey, episodeJSON, 12*time.Hour)
+		finalOrdinal := ordinal
 
-	return episode, nil
+		episode := model.Episode{
+			ID:      id,
+			Title:   finalTitle,
+			Ordinal: finalOrdinal,
+			Opening: model.TimeSegment{
+				Start: 0,
+				End:   0,
+			},
+			Ending: model.TimeSegment{
+				Start: 0,
+				End:   0,
+			},
+			Sources: []model.Source{
+				{
+					Url:  result.Iframe,
+					Type: "iframe",
+				},
+			},
+			Subtitles: []model.Subtitle{},
+		}
+
+		return episode, nil
+	})
 }
 
-func (r *AnimeRepo) SearchConsumetAnime(query string, page int) (model.PaginatedSearchAnime, error) {
-	ctx := context.Background()
+func (r *AnimeRepo) SearchConsumetAnime(ctx context.Context, query string, page int) (model.PaginatedSearchAnime, error) {
 	cacheKey := fmt.Sprintf("anime:search:consumet:query:%s:page:%d", query, page)
 
-	cached, err := r.dbRedis.Get(ctx, cacheKey).Result()
-	if err == nil {
-		var anime model.PaginatedSearchAnime
-		if err := json.Unmarshal([]byte(cached), &anime); err == nil {
-			return anime, nil
+	return cache.Fetch(ctx, r.cache, cacheKey, r.cache.TTL().Search, func(ctx context.Context) (model.PaginatedSearchAnime, error) {
+		rawResult, err := fetchConsumet(ctx, r.endpoints.ConsumetURL, query, page)
+		if err != nil {
+			return model.PaginatedSearchAnime{}, err
 		}
-	}
-	rawResult, err := fetchConsumet(query, page)
-	if err != nil {
-		return model.PaginatedSearchAnime{}, err
-	}
 
-	result := model.PaginatedSearchAnime{
-		Data: make([]model.SearchAnime, 0),
-		Meta: rawResult.Meta,
-	}
-	for _, a := range rawResult.Data {
-		anime := model.SearchAnime{
-			ID:         a.ID,
-			Title:      a.Title,
-			Poster:     a.Poster,
-			Year:       a.Year,
-			Type:       a.Type,
-			ParserType: "Consumet",
-		}
-		result.Data = append(result.Data, anime)
-		if !checkExists(r.dbPostgres, anime.ID) {
-			insertSearchAnime(r.dbPostgres, anime)
+		result := model.PaginatedSearchAnime{
+			Data: make([]model.SearchAnime, 0),
+			Meta: rawResult.Meta,
+		}
+		for _, a := range rawResult.Data {
+			anime := model.SearchAnime{
+				ID:         a.ID,
+				Title:      a.Title,
+				Poster:     a.Poster,
+				Year:       a.Year,
+				Type:       a.Type,
+				ParserType: "Consumet",
+			}
+			result.Data = append(result.Data, anime)
+			if !checkExists(ctx, r.dbPostgres, anime.ID) {
+				insertSearchAnime(ctx, r.dbPostgres, anime)
+			}
 		}
-	}
-
-	logSearchClickhouse(r.dbClickhouse, query, "consumet", len(result.Data))
 
-	animeJSON, _ := json.Marshal(result)
-	r.dbRedis.Set(ctx, cacheKey, animeJSON, 12*time.Hour)
+		logSearch(ctx, r.analytics, query, "consumet", len(result.Data))
 
-	return result, nil
+		return result, nil
+	})
 }
 
-func (r *AnimeRepo) SearchAnilibriaAnime(query string, page int) (model.PaginatedSearchAnime, error) {
-	ctx := context.Background()
+func (r *AnimeRepo) SearchAnilibriaAnime(ctx context.Context, query string, page int) (model.PaginatedSearchAnime, error) {
 	cacheKey := fmt.Sprintf("anime:search:anilibria:query:%s:page:%d", query, page)
 
-	cached, err := r.dbRedis.Get(ctx, cacheKey).Result()
-	if err == nil {
-		var anime model.PaginatedSearchAnime
-		if err := json.Unmarshal([]byte(cached), &anime); err == nil {
-			return anime, nil
+	return cache.Fetch(ctx, r.cache, cacheKey, r.cache.TTL().Search, func(ctx context.Context) (model.PaginatedSearchAnime, error) {
+		result, err := fetchAnilibriaReleases(ctx, r.endpoints.AnilibriaURL, "app/search/releases", query, 0, page)
+		if err != nil {
+			return model.PaginatedSearchAnime{}, err
 		}
-	}
-	result, err := fetchAnilibriaReleases("app/search/releases", query, 0, page)
-	if err != nil {
-		return model.PaginatedSearchAnime{}, err
-	}
 
-	for _, anime := range result.Data {
-		if !checkExists(r.dbPostgres, anime.ID) {
-			insertSearchAnime(r.dbPostgres, anime)
+		for _, anime := range result.Data {
+			if !checkExists(ctx, r.dbPostgres, anime.ID) {
+				insertSearchAnime(ctx, r.dbPostgres, anime)
+			}
 		}
-	}
 
-	logSearchClickhouse(r.dbClickhouse, query, "anilibria", len(result.Data))
+		logSearch(ctx, r.analytics, query, "anilibria", len(result.Data))
 
-	animeJSON, _ := json.Marshal(result)
-	r.dbRedis.Set(ctx, cacheKey, animeJSON, 12*time.Hour)
-
-	return result, nil
+		return result, nil
+	})
 }
 
-func (r *AnimeRepo) SearchAnilibriaRecommendedAnime(limit int, page int) ([]model.SearchAnime, error) {
-	ctx := context.Background()
+func (r *AnimeRepo) SearchAnilibriaRecommendedAnime(ctx context.Context, limit int, page int) ([]model.SearchAnime, error) {
 	cacheKey := fmt.Sprintf("anime:search:anilibria:recommended:limit:%d:page:%d", limit, page)
 
-	cached, err := r.dbRedis.Get(ctx, cacheKey).Result()
-	if err == nil {
-		var anime []model.SearchAnime
-		if err := json.Unmarshal([]byte(cached), &anime); err == nil {
-			return anime, nil
+	return cache.Fetch(ctx, r.cache, cacheKey, r.cache.TTL().Search, func(ctx context.Context) ([]model.SearchAnime, error) {
+		result, err := fetchAnilibriaReleases(ctx, r.endpoints.AnilibriaURL, "anime/releases/recommended", "", limit, page)
+		if err != nil {
+			return nil, err
 		}
-	}
-	result, err := fetchAnilibriaReleases("anime/releases/recommended", "", limit, page)
-	if err != nil {
-		return nil, err
-	}
 
-	for _, anime := range result.Data {
-		if !checkExists(r.dbPostgres, anime.ID) {
-			insertSearchAnime(r.dbPostgres, anime)
+		for _, anime := range result.Data {
+			if !checkExists(ctx, r.dbPostgres, anime.ID) {
+				insertSearchAnime(ctx, r.dbPostgres, anime)
+			}
 		}
-	}
 
-	logSearchClickhouse(r.dbClickhouse, "recommended", "anilibria", len(result.Data))
+		logSearch(ctx, r.analytics, "recommended", "anilibria", len(result.Data))
 
-	animeJSON, _ := json.Marshal(result)
-	r.dbRedis.Set(ctx, cacheKey, animeJSON, 12*time.Hour)
-
-	return result.Data, nil
+		return result.Data, nil
+	})
 }
 
-func (r *AnimeRepo) SearchConsumetRecommendedAnime() ([]model.SearchAnime, error) {
-	ctx := context.Background()
-	cacheKey := "anime:search:consumet:recommended"
+func (r *AnimeRepo) SearchConsumetRecommendedAnime(ctx context.Context, page int) ([]model.SearchAnime, error) {
+	page = max(page, 1)
+	cacheKey := fmt.Sprintf("anime:search:consumet:recommended:page:%d", page)
 
-	cached, err := r.dbRedis.Get(ctx, cacheKey).Result()
-	if err == nil {
-		var anime []model.SearchAnime
-		if err := json.Unmarshal([]byte(cached), &anime); err == nil {
-			return anime, nil
+	return cache.Fetch(ctx, r.cache, cacheKey, r.cache.TTL().Search, func(ctx context.Context) ([]model.SearchAnime, error) {
+		releases, err := fetchConsumetReleases(ctx, r.endpoints.ConsumetURL, "most-popular", page)
+		if err != nil {
+			return nil, err
 		}
-	}
-	result, err := fetchConsumetReleases("most-popular")
-	if err != nil {
-		return nil, err
-	}
+		result := releases.Data
 
-	for _, anime := range result {
-		if !checkExists(r.dbPostgres, anime.ID) {
-			insertSearchAnime(r.dbPostgres, anime)
+		for _, anime := range result {
+			if !checkExists(ctx, r.dbPostgres, anime.ID) {
+				insertSearchAnime(ctx, r.dbPostgres, anime)
+			}
 		}
-	}
-
-	logSearchClickhouse(r.dbClickhouse, "recommended", "consumet", len(result))
 
-	animeJSON, _ := json.Marshal(result)
-	r.dbRedis.Set(ctx, cacheKey, animeJSON, 12*time.Hour)
+		logSearch(ctx, r.analytics, "recommended", "consumet", len(result))
 
-	return result, nil
+		return result, nil
+	})
 }
 
-func (r *AnimeRepo) SearchConsumetLatestReleases() ([]model.SearchAnime, error) {
-	ctx := context.Background()
-	cacheKey := "anime:search:consumet:latest"
+func (r *AnimeRepo) SearchConsumetLatestReleases(ctx context.Context, page int) ([]model.SearchAnime, error) {
+	page = max(page, 1)
+	cacheKey := fmt.Sprintf("anime:search:consumet:latest:page:%d", page)
 
-	cached, err := r.dbRedis.Get(ctx, cacheKey).Result()
-	if err == nil {
-		var anime []model.SearchAnime
-		if err := json.Unmarshal([]byte(cached), &anime); err == nil {
-			return anime, nil
+	return cache.Fetch(ctx, r.cache, cacheKey, r.cache.TTL().Search, func(ctx context.Context) ([]model.SearchAnime, error) {
+		releases, err := fetchConsumetReleases(ctx, r.endpoints.ConsumetURL, "top-airing", page)
+		if err != nil {
+			return nil, err
 		}
-	}
+		result := releases.Data
 
-	result, err := fetchConsumetReleases("top-airing")
-	if err != nil {
-		return nil, err
-	}
-
-	for _, anime := range result {
-		if !checkExists(r.dbPostgres, anime.ID) {
-			insertSearchAnime(r.dbPostgres, anime)
+		for _, anime := range result {
+			if !checkExists(ctx, r.dbPostgres, anime.ID) {
+				insertSearchAnime(ctx, r.dbPostgres, anime)
+			}
 		}
-	}
-
-	logSearchClickhouse(r.dbClickhouse, "latest", "consumet", len(result))
 
-	animeJSON, _ := json.Marshal(result)
-	r.dbRedis.Set(ctx, cacheKey, animeJSON, 12*time.Hour)
+		logSearch(ctx, r.analytics, "latest", "consumet", len(result))
 
-	return result, nil
+		return result, nil
+	})
 }
 
-func (r *AnimeRepo) SearchAnilibriaLatestReleases(limit int) ([]model.SearchAnime, error) {
-	ctx := context.Background()
-	cacheKey := "anime:search:anilibria:latest"
+func (r *AnimeRepo) SearchAnilibriaLatestReleases(ctx context.Context, limit, page int) ([]model.SearchAnime, error) {
+	cacheKey := fmt.Sprintf("anime:search:anilibria:latest:limit:%d:page:%d", limit, page)
 
-	cached, err := r.dbRedis.Get(ctx, cacheKey).Result()
-	if err == nil {
-		var anime []model.SearchAnime
-		if err := json.Unmarshal([]byte(cached), &anime); err == nil {
-			return anime, nil
+	return cache.Fetch(ctx, r.cache, cacheKey, r.cache.TTL().Search, func(ctx context.Context) ([]model.SearchAnime, error) {
+		result, err := fetchAnilibriaReleases(ctx, r.endpoints.AnilibriaURL, "anime/releases/latest", "", limit, page)
+		if err != nil {
+			return nil, err
 		}
-	}
-	result, err := fetchAnilibriaReleases("anime/releases/latest", "", limit, 0)
-	if err != nil {
-		return nil, err
-	}
 
-	for _, anime := range result.Data {
-		if !checkExists(r.dbPostgres, anime.ID) {
-			insertSearchAnime(r.dbPostgres, anime)
+		for _, anime := range result.Data {
+			if !checkExists(ctx, r.dbPostgres, anime.ID) {
+				insertSearchAnime(ctx, r.dbPostgres, anime)
+			}
 		}
-	}
 
-	logSearchClickhouse(r.dbClickhouse, "latest", "anilibria", len(result.Data))
+		logSearch(ctx, r.analytics, "latest", "anilibria", len(result.Data))
 
-	animeJSON, _ := json.Marshal(result)
-	r.dbRedis.Set(ctx, cacheKey, animeJSON, 12*time.Hour)
-	return result.Data, nil
+		return result.Data, nil
+	})
 }
 
-func (r *AnimeRepo) SearchAnilibriaRandomReleases(limit int, page int) (model.PaginatedSearchAnime, error) {
-	result, err := fetchAnilibriaReleases("anime/releases/random", "", limit, page)
+func (r *AnimeRepo) SearchAnilibriaRandomReleases(ctx context.Context, limit int, page int) (model.PaginatedSearchAnime, error) {
+	result, err := fetchAnilibriaReleases(ctx, r.endpoints.AnilibriaURL, "anime/releases/random", "", limit, page)
 	if err != nil {
 		return model.PaginatedSearchAnime{}, err
 	}
 
 	for _, anime := range result.Data {
-		if !checkExists(r.dbPostgres, anime.ID) {
-			insertSearchAnime(r.dbPostgres, anime)
+		if !checkExists(ctx, r.dbPostgres, anime.ID) {
+			insertSearchAnime(ctx, r.dbPostgres, anime)
 		}
 	}
 
-	logSearchClickhouse(r.dbClickhouse, "random", "anilibria", len(result.Data))
+	logSearch(ctx, r.analytics, "random", "anilibria", len(result.Data))
 	return result, nil
 }
 
-func (r *AnimeRepo) GetAnilibriaGenres() ([]model.Genre, error) {
-	ctx := context.Background()
+func (r *AnimeRepo) GetAnilibriaGenres(ctx context.Context) ([]model.Genre, error) {
 	cacheKey := "anime:anilibria:genres"
 
-	cached, err := r.dbRedis.Get(ctx, cacheKey).Result()
-	if err == nil {
-		var genres []model.Genre
-		if err := json.Unmarshal([]byte(cached), &genres); err == nil {
-			return genres, nil
-		}
-	}
-	baseURL := "https://aniliberty.top/api/v1/anime/genres?include=id,name,total_releases"
-
-	var result []model.Genre
-	if err := doJSONRequest(baseURL, &result); err != nil {
-		return nil, err
-	}
+	return cache.Fetch(ctx, r.cache, cacheKey, r.cache.TTL().Genres, func(ctx context.Context) ([]model.Genre, error) {
+		baseURL := fmt.Sprintf("%s/api/v1/anime/genres?include=id,name,total_releases", r.endpoints.AnilibriaURL)
 
-	genreJSON, _ := json.Marshal(result)
-	r.dbRedis.Set(ctx, cacheKey, genreJSON, 24*time.Hour)
+		var result []model.Genre
+		if err := doJSONRequest(ctx, baseURL, &result); err != nil {
+			return nil, err
+		}
 
-	return result, nil
+		return result, nil
+	})
 }
 
-func (r *AnimeRepo) GetConsumetGenres() ([]string, error) {
-	ctx := context.Background()
+func (r *AnimeRepo) GetConsumetGenres(ctx context.Context) ([]string, error) {
 	cacheKey := "anime:consumet:genres"
 
-	cached, err := r.dbRedis.Get(ctx, cacheKey).Result()
-	if err == nil {
-		var genres []string
-		if err := json.Unmarshal([]byte(cached), &genres); err == nil {
-			return genres, nil
-		}
-	}
-	baseURL := fmt.Sprintf("%s/anime/hianime/genre/list", config.ConsumetUrl)
+	return cache.Fetch(ctx, r.cache, cacheKey, r.cache.TTL().Genres, func(ctx context.Context) ([]string, error) {
+		baseURL := fmt.Sprintf("%s/anime/hianime/genre/list", r.endpoints.ConsumetURL)
 
-	var result []string
-	if err := doJSONRequest(baseURL, &result); err != nil {
-		return nil, err
-	}
-	genreJSON, _ := json.Marshal(result)
-	r.dbRedis.Set(ctx, cacheKey, genreJSON, 24*time.Hour)
+		var result []string
+		if err := doJSONRequest(ctx, baseURL, &result); err != nil {
+			return nil, err
+		}
 
-	return result, nil
+		return result, nil
+	})
 }
 
-func (r *AnimeRepo) SearchAnilibriaGenreReleases(genreID, limit int, page int) (model.PaginatedSearchAnime, error) {
-	result, err := fetchAnilibriaReleases(fmt.Sprintf("anime/releases/genre/%d/releases", genreID), "", limit, page)
+func (r *AnimeRepo) SearchAnilibriaGenreReleases(ctx context.Context, genreID, limit int, page int) (model.PaginatedSearchAnime, error) {
+	result, err := fetchAnilibriaReleases(ctx, r.endpoints.AnilibriaURL, fmt.Sprintf("anime/releases/genre/%d/releases", genreID), "", limit, page)
 	if err != nil {
 		return model.PaginatedSearchAnime{}, err
 	}
 
 	for _, anime := range result.Data {
-		if !checkExists(r.dbPostgres, anime.ID) {
-			insertSearchAnime(r.dbPostgres, anime)
+		if !checkExists(ctx, r.dbPostgres, anime.ID) {
+			insertSearchAnime(ctx, r.dbPostgres, anime)
 		}
 	}
 
-	logSearchClickhouse(r.dbClickhouse, "random", "anilibria", len(result.Data))
+	logSearch(ctx, r.analytics, "random", "anilibria", len(result.Data))
 	return result, nil
 }
 
-func (r *AnimeRepo) SearchConsumetGenreReleases(genre string) ([]model.SearchAnime, error) {
-	result, err := fetchConsumetReleases(fmt.Sprintf("genre/%s", genre))
+func (r *AnimeRepo) SearchConsumetGenreReleases(ctx context.Context, genre string, page int) (model.PaginatedSearchAnime, error) {
+	result, err := fetchConsumetReleases(ctx, r.endpoints.ConsumetURL, fmt.Sprintf("genre/%s", genre), page)
 	if err != nil {
-		return nil, err
+		return model.PaginatedSearchAnime{}, err
 	}
 
-	for _, anime := range result {
-		if !checkExists(r.dbPostgres, anime.ID) {
-			insertSearchAnime(r.dbPostgres, anime)
+	for _, anime := range result.Data {
+		if !checkExists(ctx, r.dbPostgres, anime.ID) {
+			insertSearchAnime(ctx, r.dbPostgres, anime)
 		}
 	}
 
-	logSearchClickhouse(r.dbClickhouse, fmt.Sprintf("genre-%s", genre), "consumet", len(result))
+	logSearch(ctx, r.analytics, fmt.Sprintf("genre-%s", genre), "consumet", len(result.Data))
 	return result, nil
 }
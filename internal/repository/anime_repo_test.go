@@ -0,0 +1,14 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSingleAudio(t *testing.T) {
+	r := &AnimeRepo{}
+
+	assert.True(t, r.SingleAudio("9000"))
+	assert.False(t, r.SingleAudio("one-piece-100"))
+}
@@ -3,69 +3,83 @@ package repository
 import (
 	"context"
 	"database/sql"
-	"log"
+	"log/slog"
 	"math"
 
-	clickhouse "github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/astanx/anime_api/internal/analytics"
 	"github.com/astanx/anime_api/internal/db"
 	"github.com/astanx/anime_api/internal/model"
 )
 
 type CollectionRepo struct {
-	dbPostgres   *sql.DB
-	dbClickhouse clickhouse.Conn
+	dbPostgres *sql.DB
+	analytics  analytics.Sink
 }
 
 func NewCollectionRepo(db *db.DB) *CollectionRepo {
 	return &CollectionRepo{
-		dbPostgres:   db.Postgres,
-		dbClickhouse: db.ClickHouse,
+		dbPostgres: db.Postgres,
+		analytics:  db.Analytics,
 	}
 }
 
-func (r *CollectionRepo) AddCollection(deviceID string, collection model.Collection) error {
-	var exists bool
-	err := r.dbPostgres.QueryRow(
-		`SELECT EXISTS(SELECT 1 FROM collections WHERE device_id=$1 AND anime_id=$2)`,
-		deviceID, collection.AnimeID,
-	).Scan(&exists)
+func (r *CollectionRepo) AddCollection(ctx context.Context, deviceID string, collection model.Collection) error {
+	_, err := r.dbPostgres.ExecContext(ctx,
+		`INSERT INTO collections (device_id, anime_id, type) VALUES ($1, $2, $3)
+		 ON CONFLICT (device_id, anime_id) DO UPDATE
+		 SET type = EXCLUDED.type, updated_at = now()`,
+		deviceID, collection.AnimeID, collection.Type,
+	)
 	if err != nil {
 		return err
 	}
 
-	if exists {
-		_, err = r.dbPostgres.Exec(
-			`UPDATE collections
-			 SET type=$1
-			 WHERE device_id=$2 AND anime_id=$3`,
-			collection.Type, deviceID, collection.AnimeID,
-		)
-	} else {
-		_, err = r.dbPostgres.Exec(
-			`INSERT INTO collections (device_id, anime_id, type) VALUES ($1, $2, $3)`,
-			deviceID, collection.AnimeID, collection.Type,
-		)
-	}
+	err = r.analytics.Record(ctx, analytics.CollectionEvent{
+		AnimeID: collection.AnimeID,
+		Type:    collection.Type,
+		Count:   1,
+	})
 	if err != nil {
-		return err
+		slog.ErrorContext(ctx, "analytics increment failed", "error", err)
 	}
 
-	err = r.dbClickhouse.Exec(
-		context.Background(),
-		`INSERT INTO collection_analytics (anime_id, type, count)
-		 VALUES (?, ?, 1)`,
-		collection.AnimeID, collection.Type,
+	return nil
+}
+
+// PromoteCollection moves animeID to collectionType as playback progresses.
+// Unlike AddCollection it never moves a title out of "watched", so a rewatch
+// keeps it there. It reports whether the collection changed.
+func (r *CollectionRepo) PromoteCollection(ctx context.Context, deviceID, animeID, collectionType string) (bool, error) {
+	res, err := r.dbPostgres.ExecContext(ctx,
+		`INSERT INTO collections (device_id, anime_id, type) VALUES ($1, $2, $3)
+		 ON CONFLICT (device_id, anime_id) DO UPDATE
+		 SET type = EXCLUDED.type, updated_at = now()
+		 WHERE collections.type <> EXCLUDED.type AND collections.type <> 'watched'`,
+		deviceID, animeID, collectionType,
 	)
 	if err != nil {
-		log.Println("ClickHouse increment failed:", err)
+		return false, err
+	}
+	changed, err := res.RowsAffected()
+	if err != nil || changed == 0 {
+		return false, err
 	}
 
-	return nil
+	err = r.analytics.Record(ctx, analytics.CollectionEvent{
+		AnimeID: animeID,
+		Type:    collectionType,
+		Count:   1,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "analytics increment failed", "error", err)
+	}
+
+	return true, nil
 }
 
-func (r *CollectionRepo) RemoveCollection(deviceID, animeID, collectionType string) error {
+func (r *CollectionRepo) RemoveCollection(ctx context.Context, deviceID, animeID, collectionType string) error {
 	var exists bool
-	err := r.dbPostgres.QueryRow(
+	err := r.dbPostgres.QueryRowContext(ctx,
 		`SELECT EXISTS(SELECT 1 FROM collections WHERE device_id=$1 AND anime_id=$2)`,
 		deviceID, animeID,
 	).Scan(&exists)
@@ -74,7 +88,7 @@ func (r *CollectionRepo) RemoveCollection(deviceID, animeID, collectionType stri
 	}
 
 	if exists {
-		_, err = r.dbPostgres.Exec(
+		_, err = r.dbPostgres.ExecContext(ctx,
 			"DELETE FROM collections WHERE device_id = $1 AND anime_id = $2",
 			deviceID, animeID,
 		)
@@ -83,21 +97,20 @@ func (r *CollectionRepo) RemoveCollection(deviceID, animeID, collectionType stri
 		}
 	}
 
-	err = r.dbClickhouse.Exec(
-		context.Background(),
-		`INSERT INTO collection_analytics (anime_id, type, count)
-		 VALUES (?, ?, -1)`,
-		animeID, collectionType,
-	)
+	err = r.analytics.Record(ctx, analytics.CollectionEvent{
+		AnimeID: animeID,
+		Type:    collectionType,
+		Count:   -1,
+	})
 	if err != nil {
-		log.Println("ClickHouse decrement failed:", err)
+		slog.ErrorContext(ctx, "analytics decrement failed", "error", err)
 	}
 
 	return nil
 }
 
-func (r *CollectionRepo) GetAllCollections(deviceID string) ([]model.Collection, error) {
-	rows, err := r.dbPostgres.Query(
+func (r *CollectionRepo) GetAllCollections(ctx context.Context, deviceID string) ([]model.Collection, error) {
+	rows, err := r.dbPostgres.QueryContext(ctx,
 		"SELECT anime_id, type FROM collections WHERE device_id = $1 ORDER BY id DESC",
 		deviceID,
 	)
@@ -122,18 +135,18 @@ func (r *CollectionRepo) GetAllCollections(deviceID string) ([]model.Collection,
 	return collections, nil
 }
 
-func (r *CollectionRepo) GetCollections(deviceID, T string, page, limit int) (model.PaginatedCollections, error) {
+func (r *CollectionRepo) GetCollections(ctx context.Context, deviceID, T string, page, limit int) (model.PaginatedCollections, error) {
 	offset := (page - 1) * limit
 
 	var total int
-	err := r.dbPostgres.QueryRow(
+	err := r.dbPostgres.QueryRowContext(ctx,
 		"SELECT COUNT(*) FROM collections WHERE device_id = $1",
 		deviceID,
 	).Scan(&total)
 	if err != nil {
 		return model.PaginatedCollections{}, err
 	}
-	rows, err := r.dbPostgres.Query(
+	rows, err := r.dbPostgres.QueryContext(ctx,
 		`SELECT anime_id, type
 		 FROM collections
 		 WHERE device_id = $1 AND type = $2
@@ -174,9 +187,9 @@ func (r *CollectionRepo) GetCollections(deviceID, T string, page, limit int) (mo
 	}, nil
 }
 
-func (r *CollectionRepo) GetCollectionForAnime(deviceID, animeID string) (model.Collection, error) {
+func (r *CollectionRepo) GetCollectionForAnime(ctx context.Context, deviceID, animeID string) (model.Collection, error) {
 	var exists bool
-	err := r.dbPostgres.QueryRow(
+	err := r.dbPostgres.QueryRowContext(ctx,
 		`SELECT EXISTS(SELECT 1 FROM collections WHERE device_id=$1 AND anime_id=$2)`,
 		deviceID, animeID,
 	).Scan(&exists)
@@ -188,7 +201,7 @@ func (r *CollectionRepo) GetCollectionForAnime(deviceID, animeID string) (model.
 		return model.Collection{}, nil
 	}
 	var collection model.Collection
-	err = r.dbPostgres.QueryRow(
+	err = r.dbPostgres.QueryRowContext(ctx,
 		`SELECT anime_id, type FROM collections WHERE device_id=$1 AND anime_id=$2`,
 		deviceID, animeID,
 	).Scan(&collection.AnimeID, &collection.Type)
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/astanx/anime_api/internal/model"
+)
+
+type ConsumetProvider struct {
+	repo *AnimeRepo
+}
+
+func NewConsumetProvider(repo *AnimeRepo) *ConsumetProvider {
+	return &ConsumetProvider{repo: repo}
+}
+
+func (p *ConsumetProvider) Name() string {
+	return "consumet"
+}
+
+func (p *ConsumetProvider) Search(ctx context.Context, query string, page int) (model.PaginatedSearchAnime, error) {
+	return p.repo.SearchConsumetAnime(ctx, query, page)
+}
+
+// Latest returns a page of currently airing titles. Consumet pages have a
+// fixed size, so its listings take a page but no limit.
+func (p *ConsumetProvider) Latest(ctx context.Context, limit, page int) ([]model.SearchAnime, error) {
+	if limit > 0 {
+		return nil, ErrLimitNotSupported
+	}
+	return p.repo.SearchConsumetLatestReleases(ctx, page)
+}
+
+func (p *ConsumetProvider) Recommended(ctx context.Context, limit, page int) ([]model.SearchAnime, error) {
+	if limit > 0 {
+		return nil, ErrLimitNotSupported
+	}
+	return p.repo.SearchConsumetRecommendedAnime(ctx, page)
+}
+
+// Genres returns genres by name only, which GenreReleases takes as they are.
+func (p *ConsumetProvider) Genres(ctx context.Context) ([]model.Genre, error) {
+	names, err := p.repo.GetConsumetGenres(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	genres := make([]model.Genre, len(names))
+	for i, name := range names {
+		genres[i] = model.Genre{Name: name}
+	}
+	return genres, nil
+}
+
+func (p *ConsumetProvider) GenreReleases(ctx context.Context, genre string, limit, page int) (model.PaginatedSearchAnime, error) {
+	if limit > 0 {
+		return model.PaginatedSearchAnime{}, ErrLimitNotSupported
+	}
+	return p.repo.SearchConsumetGenreReleases(ctx, genre, page)
+}
+
+func (p *ConsumetProvider) AnimeInfo(ctx context.Context, id string) (model.Anime, error) {
+	return p.repo.GetAnimeInfoByConsumetID(ctx, id)
+}
+
+func (p *ConsumetProvider) EpisodeInfo(ctx context.Context, id string, params EpisodeParams) (model.Episode, error) {
+	return p.repo.GetConsumetEpisodeInfo(ctx, id, params.Title, params.Ordinal, strconv.FormatBool(params.Dub))
+}
@@ -3,30 +3,47 @@ package repository
 import (
 	"context"
 	"database/sql"
-	"log"
+	"errors"
+	"log/slog"
+	"time"
 
-	clickhouse "github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/astanx/anime_api/internal/analytics"
 	"github.com/astanx/anime_api/internal/db"
 	"github.com/astanx/anime_api/internal/model"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
+var (
+	ErrPairingCodeInvalid = errors.New("pairing code is invalid or expired")
+	ErrPairingCodeTaken   = errors.New("pairing code already in use")
+	ErrPairSelf           = errors.New("a device can't pair with itself")
+	ErrPairAccount        = errors.New("device belongs to an account, unlink it first")
+	ErrDeviceNotFound     = errors.New("device not found")
+	ErrMergeSameLibrary   = errors.New("devices already share a library")
+	ErrMergeAccount       = errors.New("source device belongs to an account, log in to it instead")
+	ErrMergeShared        = errors.New("source device shares its library with other devices, unlink it first")
+)
+
+// deviceDataTables hold per-owner rows keyed by the device_id column.
+var deviceDataTables = []string{"timecodes", "history", "collections", "favourites"}
+
 type DeviceRepo struct {
-	dbPostgres   *sql.DB
-	dbClickhouse clickhouse.Conn
+	dbPostgres *sql.DB
+	analytics  analytics.Sink
 }
 
 func NewDeviceRepo(db *db.DB) *DeviceRepo {
 	return &DeviceRepo{
-		dbPostgres:   db.Postgres,
-		dbClickhouse: db.ClickHouse,
+		dbPostgres: db.Postgres,
+		analytics:  db.Analytics,
 	}
 }
 
-func (r *DeviceRepo) AddDeviceID(deviceID uuid.UUID, from string) (model.User, error) {
+func (r *DeviceRepo) AddDeviceID(ctx context.Context, deviceID uuid.UUID, from string) (model.User, error) {
 	var u model.User
 
-	err := r.dbPostgres.QueryRow(
+	err := r.dbPostgres.QueryRowContext(ctx,
 		"INSERT INTO devices (device_id, created_from) VALUES ($1, $2) RETURNING device_id",
 		deviceID, from,
 	).Scan(&u.ID)
@@ -34,14 +51,639 @@ func (r *DeviceRepo) AddDeviceID(deviceID uuid.UUID, from string) (model.User, e
 		return u, err
 	}
 
-	err = r.dbClickhouse.Exec(
-		context.Background(),
-		"INSERT INTO device_analytics (device_id, from, created_at) VALUES (?, ?, now())",
-		deviceID, from,
-	)
+	err = r.analytics.Record(ctx, analytics.DeviceEvent{
+		DeviceID:  deviceID.String(),
+		From:      from,
+		CreatedAt: time.Now(),
+	})
 	if err != nil {
-		log.Println("ClickHouse insert failed:", err)
+		slog.ErrorContext(ctx, "analytics insert failed", "error", err)
 	}
 
 	return u, nil
 }
+
+// Owner returns the account or library deviceID belongs to and whether it
+// was revoked. Unknown devices own their data themselves.
+func (r *DeviceRepo) Owner(ctx context.Context, deviceID string) (model.DeviceOwner, error) {
+	owner := model.DeviceOwner{DeviceID: deviceID}
+	err := r.dbPostgres.QueryRowContext(ctx,
+		"SELECT COALESCE(account_id, ''), COALESCE(library_id, ''), revoked_at IS NOT NULL, token_version FROM devices WHERE device_id = $1",
+		deviceID,
+	).Scan(&owner.AccountID, &owner.LibraryID, &owner.Revoked, &owner.TokenVersion)
+	if err == sql.ErrNoRows {
+		return owner, nil
+	}
+	owner.Known = err == nil
+	return owner, err
+}
+
+// NextTokenVersion bumps the token version of deviceID, which invalidates
+// its earlier tokens, and returns the new version.
+func (r *DeviceRepo) NextTokenVersion(ctx context.Context, deviceID string) (int, error) {
+	var version int
+	err := r.dbPostgres.QueryRowContext(ctx,
+		"UPDATE devices SET token_version = token_version + 1 WHERE device_id = $1 AND revoked_at IS NULL RETURNING token_version",
+		deviceID,
+	).Scan(&version)
+	if err == sql.ErrNoRows {
+		return 0, ErrDeviceNotFound
+	}
+	return version, err
+}
+
+// CreatePairingCode stores code for deviceID, replacing any earlier code of
+// the device and clearing expired ones.
+func (r *DeviceRepo) CreatePairingCode(ctx context.Context, deviceID, code string, expiresAt time.Time) error {
+	tx, err := r.dbPostgres.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO devices (device_id) VALUES ($1) ON CONFLICT (device_id) DO NOTHING",
+		deviceID,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM pairing_codes WHERE device_id = $1 OR expires_at <= now()", deviceID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO pairing_codes (code, device_id, expires_at) VALUES ($1, $2, $3)",
+		code, deviceID, expiresAt,
+	)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return ErrPairingCodeTaken
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RedeemPairingCode consumes code and links deviceID to the library of the
+// device that issued it. If that device had no account or library yet, a new
+// library is created and its data moves there. Data deviceID stored before
+// stays under its old owner. A deviceID linked to an account must be
+// unlinked first, so it doesn't leave the account unnoticed. The issuing
+// device is returned too.
+func (r *DeviceRepo) RedeemPairingCode(ctx context.Context, code, deviceID string) (model.DeviceOwner, string, error) {
+	tx, err := r.dbPostgres.BeginTx(ctx, nil)
+	if err != nil {
+		return model.DeviceOwner{}, "", err
+	}
+	defer tx.Rollback()
+
+	var issuerID string
+	err = tx.QueryRowContext(ctx,
+		"DELETE FROM pairing_codes WHERE code = $1 AND expires_at > now() RETURNING device_id",
+		code,
+	).Scan(&issuerID)
+	if err == sql.ErrNoRows {
+		return model.DeviceOwner{}, "", ErrPairingCodeInvalid
+	}
+	if err != nil {
+		return model.DeviceOwner{}, "", err
+	}
+	if issuerID == deviceID {
+		return model.DeviceOwner{}, "", ErrPairSelf
+	}
+
+	var linked bool
+	err = tx.QueryRowContext(ctx,
+		"SELECT account_id IS NOT NULL FROM devices WHERE device_id = $1 FOR UPDATE",
+		deviceID,
+	).Scan(&linked)
+	if err != nil && err != sql.ErrNoRows {
+		return model.DeviceOwner{}, "", err
+	}
+	if linked {
+		return model.DeviceOwner{}, "", ErrPairAccount
+	}
+
+	issuer, err := shareableOwner(ctx, tx, issuerID)
+	if err != nil {
+		return model.DeviceOwner{}, "", err
+	}
+
+	if err := setOwner(ctx, tx, deviceID, issuer); err != nil {
+		return model.DeviceOwner{}, "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return model.DeviceOwner{}, "", err
+	}
+	return model.DeviceOwner{DeviceID: deviceID, AccountID: issuer.AccountID, LibraryID: issuer.LibraryID, Known: true}, issuer.DeviceID, nil
+}
+
+// shareableOwner locks deviceID and returns its owner. If the device had no
+// account or library yet, a new library is created and its data moves there,
+// so other devices can join it.
+func shareableOwner(ctx context.Context, tx *sql.Tx, deviceID string) (model.DeviceOwner, error) {
+	owner := model.DeviceOwner{DeviceID: deviceID, Known: true}
+	err := tx.QueryRowContext(ctx,
+		"SELECT COALESCE(account_id, ''), COALESCE(library_id, '') FROM devices WHERE device_id = $1 FOR UPDATE",
+		deviceID,
+	).Scan(&owner.AccountID, &owner.LibraryID)
+	if err != nil {
+		return owner, err
+	}
+
+	if owner.AccountID == "" && owner.LibraryID == "" {
+		owner.LibraryID = uuid.NewString()
+		if err := moveOwnerData(ctx, tx, deviceID, owner.LibraryID); err != nil {
+			return owner, err
+		}
+		_, err := tx.ExecContext(ctx, "UPDATE devices SET library_id = $1 WHERE device_id = $2", owner.LibraryID, deviceID)
+		if err != nil {
+			return owner, err
+		}
+	}
+	return owner, nil
+}
+
+// setOwner makes deviceID read and write the account or library of owner.
+func setOwner(ctx context.Context, db execer, deviceID string, owner model.DeviceOwner) error {
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO devices (device_id, account_id, library_id) VALUES ($1, NULLIF($2, ''), NULLIF($3, ''))
+		 ON CONFLICT (device_id) DO UPDATE SET account_id = EXCLUDED.account_id, library_id = EXCLUDED.library_id`,
+		deviceID, owner.AccountID, owner.LibraryID,
+	)
+	return err
+}
+
+// ListDevices returns the devices sharing the library stored under ownerKey.
+func (r *DeviceRepo) ListDevices(ctx context.Context, ownerKey, currentDeviceID string) ([]model.Device, error) {
+	rows, err := r.dbPostgres.QueryContext(ctx,
+		`SELECT device_id, created_from, created_at FROM devices
+		 WHERE COALESCE(account_id, library_id, device_id) = $1 AND revoked_at IS NULL
+		 ORDER BY created_at`,
+		ownerKey,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	devices := []model.Device{}
+	for rows.Next() {
+		var d model.Device
+		if err := rows.Scan(&d.ID, &d.CreatedFrom, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		d.Current = d.ID == currentDeviceID
+		devices = append(devices, d)
+	}
+	return devices, rows.Err()
+}
+
+// UnlinkDevice detaches deviceID from the account or library stored under
+// ownerKey. The shared data stays with the library; the device starts over
+// with the data it had under its own ID.
+func (r *DeviceRepo) UnlinkDevice(ctx context.Context, ownerKey, deviceID string) error {
+	res, err := r.dbPostgres.ExecContext(ctx,
+		`UPDATE devices SET account_id = NULL, library_id = NULL
+		 WHERE device_id = $1 AND COALESCE(account_id, library_id) = $2`,
+		deviceID, ownerKey,
+	)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrDeviceNotFound
+	}
+	return nil
+}
+
+// RevokeDevice permanently invalidates the tokens of deviceID, which must be
+// in the library stored under ownerKey, and takes it out of that library.
+func (r *DeviceRepo) RevokeDevice(ctx context.Context, ownerKey, deviceID string) error {
+	tx, err := r.dbPostgres.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx,
+		`UPDATE devices SET revoked_at = now(), account_id = NULL, library_id = NULL
+		 WHERE device_id = $1 AND COALESCE(account_id, library_id, device_id) = $2 AND revoked_at IS NULL`,
+		deviceID, ownerKey,
+	)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrDeviceNotFound
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM pairing_codes WHERE device_id = $1", deviceID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// linkDevice makes accountID the owner of deviceID, taking it out of any
+// shared library.
+func linkDevice(ctx context.Context, db execer, deviceID, accountID string) error {
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO devices (device_id, account_id) VALUES ($1, $2)
+		 ON CONFLICT (device_id) DO UPDATE SET account_id = EXCLUDED.account_id, library_id = NULL`,
+		deviceID, accountID,
+	)
+	return err
+}
+
+func queryStrings(ctx context.Context, tx *sql.Tx, query string, args ...any) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []string
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, rows.Err()
+}
+
+// moveOwnerData reassigns every row stored under from to to.
+func moveOwnerData(ctx context.Context, db execer, from, to string) error {
+	for _, table := range deviceDataTables {
+		_, err := db.ExecContext(ctx, "UPDATE "+table+" SET device_id = $1 WHERE device_id = $2", to, from)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MergeDevice moves everything sourceID stores into the library the caller
+// uses in one transaction, and moves sourceID into that library too. The
+// caller is the device targetID, or the account targetAccountID when it
+// signed in without one. Where both have a row for the same episode or
+// anime, the furthest timecode, the furthest and latest history entry and
+// the most recently updated collection win; favourites are combined.
+// sourceID must not belong to an account or share its library with other
+// devices, since their data would go too.
+func (r *DeviceRepo) MergeDevice(ctx context.Context, sourceID, targetID, targetAccountID string) (model.MergeReport, error) {
+	var report model.MergeReport
+
+	tx, err := r.dbPostgres.BeginTx(ctx, nil)
+	if err != nil {
+		return report, err
+	}
+	defer tx.Rollback()
+
+	source := model.DeviceOwner{DeviceID: sourceID}
+	err = tx.QueryRowContext(ctx,
+		"SELECT COALESCE(account_id, ''), COALESCE(library_id, '') FROM devices WHERE device_id = $1 FOR UPDATE",
+		sourceID,
+	).Scan(&source.AccountID, &source.LibraryID)
+	if err == sql.ErrNoRows {
+		return report, ErrDeviceNotFound
+	}
+	if err != nil {
+		return report, err
+	}
+	if source.AccountID != "" {
+		return report, ErrMergeAccount
+	}
+	if source.LibraryID != "" {
+		var shared bool
+		err := tx.QueryRowContext(ctx,
+			"SELECT EXISTS (SELECT 1 FROM devices WHERE library_id = $1 AND device_id <> $2)",
+			source.LibraryID, sourceID,
+		).Scan(&shared)
+		if err != nil {
+			return report, err
+		}
+		if shared {
+			return report, ErrMergeShared
+		}
+	}
+
+	target := model.DeviceOwner{AccountID: targetAccountID}
+	if targetID != "" {
+		if target, err = shareableOwner(ctx, tx, targetID); err != nil {
+			return report, err
+		}
+	}
+	from, to := source.Key(), target.Key()
+	if from == to {
+		return report, ErrMergeSameLibrary
+	}
+
+	if report.Timecodes, err = mergeTimecodes(ctx, tx, from, to); err != nil {
+		return report, err
+	}
+	if report.History, err = mergeHistory(ctx, tx, from, to); err != nil {
+		return report, err
+	}
+	if report.Collections, err = mergeCollections(ctx, tx, from, to); err != nil {
+		return report, err
+	}
+	if report.Favourites, err = mergeFavourites(ctx, tx, from, to); err != nil {
+		return report, err
+	}
+
+	for _, table := range deviceDataTables {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE device_id = $1", from); err != nil {
+			return report, err
+		}
+	}
+
+	if err := setOwner(ctx, tx, sourceID, target); err != nil {
+		return report, err
+	}
+
+	return report, tx.Commit()
+}
+
+func mergeTimecodes(ctx context.Context, tx *sql.Tx, from, to string) (model.MergeStats, error) {
+	var stats model.MergeStats
+
+	target, err := queryTimecodes(ctx, tx, to)
+	if err != nil {
+		return stats, err
+	}
+	current := make(map[string]model.Timecode, len(target))
+	for _, t := range target {
+		if _, ok := current[t.EpisodeID]; !ok {
+			current[t.EpisodeID] = t
+		}
+	}
+
+	source, err := queryTimecodes(ctx, tx, from)
+	if err != nil {
+		return stats, err
+	}
+	for _, t := range source {
+		cur, ok := current[t.EpisodeID]
+		switch {
+		case !ok:
+			_, err = tx.ExecContext(ctx,
+				"INSERT INTO timecodes (time, episode_id, is_watched, device_id, anime_id) VALUES ($1, $2, $3, $4, $5)",
+				t.Time, t.EpisodeID, t.IsWatched, to, t.AnimeID,
+			)
+			stats.Added++
+		case t.Time > cur.Time || (t.IsWatched && !cur.IsWatched):
+			t.Time = max(t.Time, cur.Time)
+			t.IsWatched = t.IsWatched || cur.IsWatched
+			_, err = tx.ExecContext(ctx,
+				"UPDATE timecodes SET time = $1, is_watched = $2, updated_at = now() WHERE device_id = $3 AND episode_id = $4",
+				t.Time, t.IsWatched, to, t.EpisodeID,
+			)
+			stats.Updated++
+		default:
+			stats.Unchanged++
+			continue
+		}
+		if err != nil {
+			return stats, err
+		}
+		current[t.EpisodeID] = t
+	}
+
+	return stats, nil
+}
+
+func queryTimecodes(ctx context.Context, tx *sql.Tx, deviceID string) ([]model.Timecode, error) {
+	rows, err := tx.QueryContext(ctx,
+		"SELECT time, episode_id, is_watched, anime_id FROM timecodes WHERE device_id = $1 ORDER BY id FOR UPDATE",
+		deviceID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var timecodes []model.Timecode
+	for rows.Next() {
+		var t model.Timecode
+		if err := rows.Scan(&t.Time, &t.EpisodeID, &t.IsWatched, &t.AnimeID); err != nil {
+			return nil, err
+		}
+		timecodes = append(timecodes, t)
+	}
+	return timecodes, rows.Err()
+}
+
+func mergeHistory(ctx context.Context, tx *sql.Tx, from, to string) (model.MergeStats, error) {
+	var stats model.MergeStats
+
+	target, err := queryHistory(ctx, tx, to)
+	if err != nil {
+		return stats, err
+	}
+	current := make(map[string]model.History, len(target))
+	for _, h := range target {
+		if _, ok := current[h.AnimeID]; !ok {
+			current[h.AnimeID] = h
+		}
+	}
+
+	source, err := queryHistory(ctx, tx, from)
+	if err != nil {
+		return stats, err
+	}
+	for _, h := range source {
+		cur, ok := current[h.AnimeID]
+		if !ok {
+			_, err := tx.ExecContext(ctx,
+				"INSERT INTO history (device_id, anime_id, last_watched, is_watched, watched_at) VALUES ($1, $2, $3, $4, $5)",
+				to, h.AnimeID, h.LastWatchedEpisode, h.IsWatched, h.WatchedAt,
+			)
+			if err != nil {
+				return stats, err
+			}
+			stats.Added++
+			current[h.AnimeID] = h
+			continue
+		}
+
+		merged := cur
+		switch {
+		case h.LastWatchedEpisode > cur.LastWatchedEpisode:
+			merged.LastWatchedEpisode = h.LastWatchedEpisode
+			merged.IsWatched = h.IsWatched
+		case h.LastWatchedEpisode == cur.LastWatchedEpisode:
+			merged.IsWatched = h.IsWatched || cur.IsWatched
+		}
+		if h.WatchedAt.After(*cur.WatchedAt) {
+			merged.WatchedAt = h.WatchedAt
+		}
+		if merged.LastWatchedEpisode == cur.LastWatchedEpisode && merged.IsWatched == cur.IsWatched && merged.WatchedAt.Equal(*cur.WatchedAt) {
+			stats.Unchanged++
+			continue
+		}
+
+		_, err := tx.ExecContext(ctx,
+			"UPDATE history SET last_watched = $1, is_watched = $2, watched_at = $3, updated_at = now() WHERE device_id = $4 AND anime_id = $5",
+			merged.LastWatchedEpisode, merged.IsWatched, merged.WatchedAt, to, h.AnimeID,
+		)
+		if err != nil {
+			return stats, err
+		}
+		stats.Updated++
+		current[h.AnimeID] = merged
+	}
+
+	return stats, nil
+}
+
+func queryHistory(ctx context.Context, tx *sql.Tx, deviceID string) ([]model.History, error) {
+	rows, err := tx.QueryContext(ctx,
+		"SELECT anime_id, last_watched, is_watched, watched_at FROM history WHERE device_id = $1 ORDER BY id FOR UPDATE",
+		deviceID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []model.History
+	for rows.Next() {
+		var h model.History
+		var watchedAt time.Time
+		if err := rows.Scan(&h.AnimeID, &h.LastWatchedEpisode, &h.IsWatched, &watchedAt); err != nil {
+			return nil, err
+		}
+		h.WatchedAt = &watchedAt
+		history = append(history, h)
+	}
+	return history, rows.Err()
+}
+
+type collectionRow struct {
+	model.Collection
+	UpdatedAt time.Time
+}
+
+func mergeCollections(ctx context.Context, tx *sql.Tx, from, to string) (model.MergeStats, error) {
+	var stats model.MergeStats
+
+	target, err := queryCollections(ctx, tx, to)
+	if err != nil {
+		return stats, err
+	}
+	current := make(map[string]collectionRow, len(target))
+	for _, c := range target {
+		if _, ok := current[c.AnimeID]; !ok {
+			current[c.AnimeID] = c
+		}
+	}
+
+	source, err := queryCollections(ctx, tx, from)
+	if err != nil {
+		return stats, err
+	}
+	for _, c := range source {
+		cur, ok := current[c.AnimeID]
+		switch {
+		case !ok:
+			_, err = tx.ExecContext(ctx,
+				"INSERT INTO collections (device_id, anime_id, type, updated_at) VALUES ($1, $2, $3, $4)",
+				to, c.AnimeID, c.Type, c.UpdatedAt,
+			)
+			stats.Added++
+		case c.UpdatedAt.After(cur.UpdatedAt) && c.Type != cur.Type:
+			_, err = tx.ExecContext(ctx,
+				"UPDATE collections SET type = $1, updated_at = $2 WHERE device_id = $3 AND anime_id = $4",
+				c.Type, c.UpdatedAt, to, c.AnimeID,
+			)
+			stats.Updated++
+		default:
+			stats.Unchanged++
+			continue
+		}
+		if err != nil {
+			return stats, err
+		}
+		current[c.AnimeID] = c
+	}
+
+	return stats, nil
+}
+
+func queryCollections(ctx context.Context, tx *sql.Tx, deviceID string) ([]collectionRow, error) {
+	rows, err := tx.QueryContext(ctx,
+		"SELECT anime_id, type, updated_at FROM collections WHERE device_id = $1 ORDER BY id FOR UPDATE",
+		deviceID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var collections []collectionRow
+	for rows.Next() {
+		var c collectionRow
+		if err := rows.Scan(&c.AnimeID, &c.Type, &c.UpdatedAt); err != nil {
+			return nil, err
+		}
+		collections = append(collections, c)
+	}
+	return collections, rows.Err()
+}
+
+func mergeFavourites(ctx context.Context, tx *sql.Tx, from, to string) (model.MergeStats, error) {
+	var stats model.MergeStats
+
+	current := make(map[string]bool)
+	target, err := queryFavourites(ctx, tx, to)
+	if err != nil {
+		return stats, err
+	}
+	for _, animeID := range target {
+		current[animeID] = true
+	}
+
+	source, err := queryFavourites(ctx, tx, from)
+	if err != nil {
+		return stats, err
+	}
+	for _, animeID := range source {
+		if current[animeID] {
+			stats.Unchanged++
+			continue
+		}
+		_, err := tx.ExecContext(ctx, "INSERT INTO favourites (device_id, anime_id) VALUES ($1, $2)", to, animeID)
+		if err != nil {
+			return stats, err
+		}
+		stats.Added++
+		current[animeID] = true
+	}
+
+	return stats, nil
+}
+
+func queryFavourites(ctx context.Context, tx *sql.Tx, deviceID string) ([]string, error) {
+	return queryStrings(ctx, tx, "SELECT anime_id FROM favourites WHERE device_id = $1 ORDER BY id FOR UPDATE", deviceID)
+}
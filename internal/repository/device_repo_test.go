@@ -0,0 +1,334 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/astanx/anime_api/internal/db"
+	"github.com/astanx/anime_api/internal/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNextTokenVersion(t *testing.T) {
+	d := testDB(t)
+	repo := NewDeviceRepo(d)
+	ctx := context.Background()
+
+	id := uuid.New()
+	deviceID := id.String()
+	t.Cleanup(func() {
+		d.Postgres.ExecContext(context.Background(), "DELETE FROM devices WHERE device_id = $1", deviceID)
+	})
+	_, err := repo.AddDeviceID(ctx, id, "test")
+	require.NoError(t, err)
+
+	owner, err := repo.Owner(ctx, deviceID)
+	require.NoError(t, err)
+	assert.Equal(t, 0, owner.TokenVersion)
+
+	for want := 1; want <= 2; want++ {
+		version, err := repo.NextTokenVersion(ctx, deviceID)
+		require.NoError(t, err)
+		assert.Equal(t, want, version)
+	}
+	owner, err = repo.Owner(ctx, deviceID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, owner.TokenVersion)
+
+	require.NoError(t, repo.RevokeDevice(ctx, deviceID, deviceID))
+	_, err = repo.NextTokenVersion(ctx, deviceID)
+	assert.ErrorIs(t, err, ErrDeviceNotFound)
+
+	_, err = repo.NextTokenVersion(ctx, uuid.NewString())
+	assert.ErrorIs(t, err, ErrDeviceNotFound)
+}
+
+// testRegisteredDevice registers a device and removes it, along with the
+// library rows of whatever it owns by then, when the test ends.
+func testRegisteredDevice(t *testing.T, d *db.DB) string {
+	t.Helper()
+
+	deviceID := testDevice(t, d)
+	_, err := d.Postgres.Exec("INSERT INTO devices (device_id) VALUES ($1)", deviceID)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		ctx := context.Background()
+		owner, err := NewDeviceRepo(d).Owner(ctx, deviceID)
+		if err == nil {
+			for _, table := range deviceDataTables {
+				d.Postgres.ExecContext(ctx, "DELETE FROM "+table+" WHERE device_id = $1", owner.Key())
+			}
+		}
+		d.Postgres.ExecContext(ctx, "DELETE FROM devices WHERE device_id = $1", deviceID)
+	})
+	return deviceID
+}
+
+// ownerKey returns the key deviceID's library is stored under.
+func ownerKey(t *testing.T, d *db.DB, deviceID string) string {
+	t.Helper()
+
+	owner, err := NewDeviceRepo(d).Owner(context.Background(), deviceID)
+	require.NoError(t, err)
+	return owner.Key()
+}
+
+func TestMergeDeviceRules(t *testing.T) {
+	d := testDB(t)
+	repo := NewDeviceRepo(d)
+	ctx := context.Background()
+	earlier := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	later := earlier.Add(24 * time.Hour)
+
+	type timecodeRow struct {
+		time      int
+		isWatched bool
+	}
+	type historyRow struct {
+		lastWatched int
+		isWatched   bool
+		watchedAt   time.Time
+	}
+	type collectionRow struct {
+		kind      string
+		updatedAt time.Time
+	}
+
+	insertTimecode := func(t *testing.T, key string, row timecodeRow) {
+		_, err := d.Postgres.Exec(
+			"INSERT INTO timecodes (device_id, anime_id, episode_id, time, is_watched) VALUES ($1, 'anime', 'episode', $2, $3)",
+			key, row.time, row.isWatched,
+		)
+		require.NoError(t, err)
+	}
+	insertHistory := func(t *testing.T, key string, row historyRow) {
+		_, err := d.Postgres.Exec(
+			"INSERT INTO history (device_id, anime_id, last_watched, is_watched, watched_at) VALUES ($1, 'anime', $2, $3, $4)",
+			key, row.lastWatched, row.isWatched, row.watchedAt,
+		)
+		require.NoError(t, err)
+	}
+	insertCollection := func(t *testing.T, key string, row collectionRow) {
+		_, err := d.Postgres.Exec(
+			"INSERT INTO collections (device_id, anime_id, type, updated_at) VALUES ($1, 'anime', $2, $3)",
+			key, row.kind, row.updatedAt,
+		)
+		require.NoError(t, err)
+	}
+
+	t.Run("timecodes keep the highest time", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			source timecodeRow
+			target timecodeRow
+			want   timecodeRow
+			stats  model.MergeStats
+		}{
+			{"source further", timecodeRow{time: 300}, timecodeRow{time: 100}, timecodeRow{time: 300}, model.MergeStats{Updated: 1}},
+			{"target further", timecodeRow{time: 50}, timecodeRow{time: 200}, timecodeRow{time: 200}, model.MergeStats{Unchanged: 1}},
+			{"watched flag carries over", timecodeRow{time: 10, isWatched: true}, timecodeRow{time: 200}, timecodeRow{time: 200, isWatched: true}, model.MergeStats{Updated: 1}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				sourceID, targetID := testRegisteredDevice(t, d), testRegisteredDevice(t, d)
+				insertTimecode(t, sourceID, tt.source)
+				insertTimecode(t, targetID, tt.target)
+
+				report, err := repo.MergeDevice(ctx, sourceID, targetID, "")
+				require.NoError(t, err)
+				assert.Equal(t, tt.stats, report.Timecodes)
+
+				var got timecodeRow
+				err = d.Postgres.QueryRow(
+					"SELECT time, is_watched FROM timecodes WHERE device_id = $1 AND episode_id = 'episode'",
+					ownerKey(t, d, targetID),
+				).Scan(&got.time, &got.isWatched)
+				require.NoError(t, err)
+				assert.Equal(t, tt.want, got)
+			})
+		}
+	})
+
+	t.Run("history keeps the furthest episode and latest watched_at", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			source historyRow
+			target historyRow
+			want   historyRow
+			stats  model.MergeStats
+		}{
+			{
+				name:   "source further",
+				source: historyRow{lastWatched: 5, watchedAt: earlier},
+				target: historyRow{lastWatched: 3, watchedAt: earlier},
+				want:   historyRow{lastWatched: 5, watchedAt: earlier},
+				stats:  model.MergeStats{Updated: 1},
+			},
+			{
+				name:   "target further",
+				source: historyRow{lastWatched: 2, isWatched: true, watchedAt: earlier},
+				target: historyRow{lastWatched: 4, watchedAt: earlier},
+				want:   historyRow{lastWatched: 4, watchedAt: earlier},
+				stats:  model.MergeStats{Unchanged: 1},
+			},
+			{
+				name:   "latest watched_at wins",
+				source: historyRow{lastWatched: 2, watchedAt: later},
+				target: historyRow{lastWatched: 4, watchedAt: earlier},
+				want:   historyRow{lastWatched: 4, watchedAt: later},
+				stats:  model.MergeStats{Updated: 1},
+			},
+			{
+				name:   "same episode keeps the watched flag",
+				source: historyRow{lastWatched: 12, isWatched: true, watchedAt: earlier},
+				target: historyRow{lastWatched: 12, watchedAt: later},
+				want:   historyRow{lastWatched: 12, isWatched: true, watchedAt: later},
+				stats:  model.MergeStats{Updated: 1},
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				sourceID, targetID := testRegisteredDevice(t, d), testRegisteredDevice(t, d)
+				insertHistory(t, sourceID, tt.source)
+				insertHistory(t, targetID, tt.target)
+
+				report, err := repo.MergeDevice(ctx, sourceID, targetID, "")
+				require.NoError(t, err)
+				assert.Equal(t, tt.stats, report.History)
+
+				var got historyRow
+				err = d.Postgres.QueryRow(
+					"SELECT last_watched, is_watched, watched_at FROM history WHERE device_id = $1 AND anime_id = 'anime'",
+					ownerKey(t, d, targetID),
+				).Scan(&got.lastWatched, &got.isWatched, &got.watchedAt)
+				require.NoError(t, err)
+				assert.Equal(t, tt.want.lastWatched, got.lastWatched)
+				assert.Equal(t, tt.want.isWatched, got.isWatched)
+				assert.True(t, tt.want.watchedAt.Equal(got.watchedAt), "watched_at %s", got.watchedAt)
+			})
+		}
+	})
+
+	t.Run("collections keep the newest type", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			source collectionRow
+			target collectionRow
+			want   string
+			stats  model.MergeStats
+		}{
+			{"source newer", collectionRow{"watched", later}, collectionRow{"watching", earlier}, "watched", model.MergeStats{Updated: 1}},
+			{"target newer", collectionRow{"abandoned", earlier}, collectionRow{"watching", later}, "watching", model.MergeStats{Unchanged: 1}},
+			{"same type", collectionRow{"planned", later}, collectionRow{"planned", earlier}, "planned", model.MergeStats{Unchanged: 1}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				sourceID, targetID := testRegisteredDevice(t, d), testRegisteredDevice(t, d)
+				insertCollection(t, sourceID, tt.source)
+				insertCollection(t, targetID, tt.target)
+
+				report, err := repo.MergeDevice(ctx, sourceID, targetID, "")
+				require.NoError(t, err)
+				assert.Equal(t, tt.stats, report.Collections)
+
+				var got string
+				err = d.Postgres.QueryRow(
+					"SELECT type FROM collections WHERE device_id = $1 AND anime_id = 'anime'",
+					ownerKey(t, d, targetID),
+				).Scan(&got)
+				require.NoError(t, err)
+				assert.Equal(t, tt.want, got)
+			})
+		}
+	})
+
+	t.Run("rows only the source has are added", func(t *testing.T) {
+		sourceID, targetID := testRegisteredDevice(t, d), testRegisteredDevice(t, d)
+		insertTimecode(t, sourceID, timecodeRow{time: 42})
+		insertHistory(t, sourceID, historyRow{lastWatched: 1, watchedAt: earlier})
+		insertCollection(t, sourceID, collectionRow{"planned", earlier})
+		_, err := d.Postgres.Exec("INSERT INTO favourites (device_id, anime_id) VALUES ($1, 'anime')", sourceID)
+		require.NoError(t, err)
+
+		report, err := repo.MergeDevice(ctx, sourceID, targetID, "")
+		require.NoError(t, err)
+		added := model.MergeStats{Added: 1}
+		assert.Equal(t, model.MergeReport{Timecodes: added, History: added, Collections: added, Favourites: added}, report)
+
+		key := ownerKey(t, d, targetID)
+		assert.Equal(t, 1, countRows(t, d, "timecodes", "episode_id", key, "episode"))
+		assert.Equal(t, 1, countRows(t, d, "history", "anime_id", key, "anime"))
+		assert.Equal(t, 1, countRows(t, d, "collections", "anime_id", key, "anime"))
+		assert.Equal(t, 1, countRows(t, d, "favourites", "anime_id", key, "anime"))
+	})
+}
+
+func TestMergeDeviceMovesSource(t *testing.T) {
+	d := testDB(t)
+	repo := NewDeviceRepo(d)
+	ctx := context.Background()
+
+	sourceID, targetID := testRegisteredDevice(t, d), testRegisteredDevice(t, d)
+	_, err := d.Postgres.Exec("INSERT INTO favourites (device_id, anime_id) VALUES ($1, 'anime')", sourceID)
+	require.NoError(t, err)
+
+	_, err = repo.MergeDevice(ctx, sourceID, targetID, "")
+	require.NoError(t, err)
+
+	key := ownerKey(t, d, targetID)
+	assert.Equal(t, key, ownerKey(t, d, sourceID))
+	assert.Equal(t, 0, countRows(t, d, "favourites", "anime_id", sourceID, "anime"))
+	assert.Equal(t, 1, countRows(t, d, "favourites", "anime_id", key, "anime"))
+}
+
+func TestMergeDeviceRefuses(t *testing.T) {
+	d := testDB(t)
+	repo := NewDeviceRepo(d)
+	ctx := context.Background()
+
+	t.Run("unknown source", func(t *testing.T) {
+		targetID := testRegisteredDevice(t, d)
+		_, err := repo.MergeDevice(ctx, uuid.NewString(), targetID, "")
+		assert.ErrorIs(t, err, ErrDeviceNotFound)
+	})
+
+	t.Run("source linked to an account", func(t *testing.T) {
+		sourceID, targetID := testRegisteredDevice(t, d), testRegisteredDevice(t, d)
+		accountID := uuid.NewString()
+		_, err := d.Postgres.Exec(
+			"INSERT INTO accounts (id, email, password_hash) VALUES ($1, $2, 'hash')",
+			accountID, accountID+"@example.com",
+		)
+		require.NoError(t, err)
+		t.Cleanup(func() { d.Postgres.Exec("DELETE FROM accounts WHERE id = $1", accountID) })
+		_, err = d.Postgres.Exec("UPDATE devices SET account_id = $1 WHERE device_id = $2", accountID, sourceID)
+		require.NoError(t, err)
+
+		_, err = repo.MergeDevice(ctx, sourceID, targetID, "")
+		assert.ErrorIs(t, err, ErrMergeAccount)
+	})
+
+	t.Run("source library shared with another device", func(t *testing.T) {
+		sourceID, otherID, targetID := testRegisteredDevice(t, d), testRegisteredDevice(t, d), testRegisteredDevice(t, d)
+		libraryID := uuid.NewString()
+		_, err := d.Postgres.Exec("UPDATE devices SET library_id = $1 WHERE device_id IN ($2, $3)", libraryID, sourceID, otherID)
+		require.NoError(t, err)
+
+		_, err = repo.MergeDevice(ctx, sourceID, targetID, "")
+		assert.ErrorIs(t, err, ErrMergeShared)
+		assert.Equal(t, libraryID, ownerKey(t, d, sourceID))
+	})
+
+	t.Run("source library shared with the target", func(t *testing.T) {
+		sourceID, targetID := testRegisteredDevice(t, d), testRegisteredDevice(t, d)
+		libraryID := uuid.NewString()
+		_, err := d.Postgres.Exec("UPDATE devices SET library_id = $1 WHERE device_id IN ($2, $3)", libraryID, sourceID, targetID)
+		require.NoError(t, err)
+
+		_, err = repo.MergeDevice(ctx, sourceID, targetID, "")
+		assert.ErrorIs(t, err, ErrMergeShared)
+	})
+}
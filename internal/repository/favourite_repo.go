@@ -3,65 +3,52 @@ package repository
 import (
 	"context"
 	"database/sql"
-	"log"
+	"log/slog"
 	"math"
 
-	clickhouse "github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/astanx/anime_api/internal/analytics"
 	"github.com/astanx/anime_api/internal/db"
 	"github.com/astanx/anime_api/internal/model"
 )
 
 type FavouriteRepo struct {
-	dbPostgres   *sql.DB
-	dbClickhouse clickhouse.Conn
+	dbPostgres *sql.DB
+	analytics  analytics.Sink
 }
 
 func NewFavouriteRepo(db *db.DB) *FavouriteRepo {
 	return &FavouriteRepo{
-		dbPostgres:   db.Postgres,
-		dbClickhouse: db.ClickHouse,
+		dbPostgres: db.Postgres,
+		analytics:  db.Analytics,
 	}
 }
 
-func (r *FavouriteRepo) AddFavourite(deviceID string, favourite model.Favourite) error {
-	var exists bool
-	err := r.dbPostgres.QueryRow(
-		`SELECT EXISTS(SELECT 1 FROM favourites WHERE device_id=$1 AND anime_id=$2)`,
+func (r *FavouriteRepo) AddFavourite(ctx context.Context, deviceID string, favourite model.Favourite) error {
+	res, err := r.dbPostgres.ExecContext(ctx,
+		`INSERT INTO favourites (device_id, anime_id) VALUES ($1, $2)
+		 ON CONFLICT (device_id, anime_id) DO NOTHING`,
 		deviceID, favourite.AnimeID,
-	).Scan(&exists)
+	)
 	if err != nil {
 		return err
 	}
-
-	if exists {
-		return nil
-	} else {
-		_, err = r.dbPostgres.Exec(
-			`INSERT INTO favourites (device_id, anime_id) VALUES ($1, $2)`,
-			deviceID, favourite.AnimeID,
-		)
-	}
-	if err != nil {
+	if added, err := res.RowsAffected(); err != nil || added == 0 {
 		return err
 	}
 
-	err = r.dbClickhouse.Exec(
-		context.Background(),
-		`
-		INSERT INTO favourite_analytics (anime_id, favourites)
-		ALUES (?, 1)
-		`,
-		favourite.AnimeID,
-	)
+	err = r.analytics.Record(ctx, analytics.FavouriteEvent{
+		AnimeID:    favourite.AnimeID,
+		Favourites: 1,
+	})
 	if err != nil {
-		log.Println("ClickHouse increment failed:", err)
+		slog.ErrorContext(ctx, "analytics increment failed", "error", err)
 	}
 
 	return nil
 }
 
-func (r *FavouriteRepo) RemoveFavourite(deviceID string, favourite model.Favourite) error {
-	_, err := r.dbPostgres.Exec(
+func (r *FavouriteRepo) RemoveFavourite(ctx context.Context, deviceID string, favourite model.Favourite) error {
+	_, err := r.dbPostgres.ExecContext(ctx,
 		"DELETE FROM favourites WHERE device_id = $1 AND anime_id = $2",
 		deviceID, favourite.AnimeID,
 	)
@@ -69,23 +56,19 @@ func (r *FavouriteRepo) RemoveFavourite(deviceID string, favourite model.Favouri
 		return err
 	}
 
-	err = r.dbClickhouse.Exec(
-		context.Background(),
-		`
-		INSERT INTO favourite_analytics (anime_id, favourites)
-		VALUES (?, -1)
-		`,
-		favourite.AnimeID,
-	)
+	err = r.analytics.Record(ctx, analytics.FavouriteEvent{
+		AnimeID:    favourite.AnimeID,
+		Favourites: -1,
+	})
 	if err != nil {
-		log.Println("ClickHouse decrement failed:", err)
+		slog.ErrorContext(ctx, "analytics decrement failed", "error", err)
 	}
 
 	return nil
 }
 
-func (r *FavouriteRepo) GetAllFavourites(deviceID string) ([]model.Favourite, error) {
-	rows, err := r.dbPostgres.Query(
+func (r *FavouriteRepo) GetAllFavourites(ctx context.Context, deviceID string) ([]model.Favourite, error) {
+	rows, err := r.dbPostgres.QueryContext(ctx,
 		"SELECT device_id, anime_id FROM favourites WHERE device_id = $1 ORDER BY id DESC",
 		deviceID,
 	)
@@ -110,11 +93,11 @@ func (r *FavouriteRepo) GetAllFavourites(deviceID string) ([]model.Favourite, er
 	return favourites, nil
 }
 
-func (r *FavouriteRepo) GetFavourites(deviceID string, page, limit int) (model.PaginatedFavourites, error) {
+func (r *FavouriteRepo) GetFavourites(ctx context.Context, deviceID string, page, limit int) (model.PaginatedFavourites, error) {
 	offset := (page - 1) * limit
 
 	var total int
-	err := r.dbPostgres.QueryRow(
+	err := r.dbPostgres.QueryRowContext(ctx,
 		"SELECT COUNT(*) FROM favourites WHERE device_id = $1",
 		deviceID,
 	).Scan(&total)
@@ -122,7 +105,7 @@ func (r *FavouriteRepo) GetFavourites(deviceID string, page, limit int) (model.P
 		return model.PaginatedFavourites{}, err
 	}
 
-	rows, err := r.dbPostgres.Query(
+	rows, err := r.dbPostgres.QueryContext(ctx,
 		"SELECT anime_id FROM favourites WHERE device_id = $1 ORDER BY id DESC LIMIT $2 OFFSET $3",
 		deviceID, limit, offset,
 	)
@@ -159,9 +142,9 @@ func (r *FavouriteRepo) GetFavourites(deviceID string, page, limit int) (model.P
 	}, nil
 }
 
-func (r *FavouriteRepo) GetFavouriteForAnime(deviceID, animeID string) (model.Favourite, error) {
+func (r *FavouriteRepo) GetFavouriteForAnime(ctx context.Context, deviceID, animeID string) (model.Favourite, error) {
 	var exists bool
-	err := r.dbPostgres.QueryRow(
+	err := r.dbPostgres.QueryRowContext(ctx,
 		`SELECT EXISTS(SELECT 1 FROM favourites WHERE device_id=$1 AND anime_id=$2)`,
 		deviceID, animeID,
 	).Scan(&exists)
@@ -173,7 +156,7 @@ func (r *FavouriteRepo) GetFavouriteForAnime(deviceID, animeID string) (model.Fa
 		return model.Favourite{}, nil
 	}
 	var favourite model.Favourite
-	err = r.dbPostgres.QueryRow(
+	err = r.dbPostgres.QueryRowContext(ctx,
 		`SELECT anime_id FROM favourites WHERE device_id=$1 AND anime_id=$2`,
 		deviceID, animeID,
 	).Scan(&favourite.AnimeID)
@@ -1,8 +1,9 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
+	"errors"
 	"math"
 
 	"github.com/astanx/anime_api/internal/db"
@@ -19,35 +20,86 @@ func NewHistoryRepo(db *db.DB) *HistoryRepo {
 	}
 }
 
-func (r *HistoryRepo) AddHistory(deviceID string, history model.History) error {
-	var exists bool
-	err := r.db.QueryRow(
-		`SELECT EXISTS(SELECT 1 FROM history WHERE device_id=$1 AND anime_id=$2)`,
-		deviceID, history.AnimeID,
-	).Scan(&exists)
+// AddHistory records that deviceID watched up to history.LastWatchedEpisode.
+// last_watched only moves forward unless history.Reset is set.
+func (r *HistoryRepo) AddHistory(ctx context.Context, deviceID string, history model.History) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO history (device_id, anime_id, last_watched, is_watched, watched_at)
+		 VALUES ($1, $2, $3, $4, now())
+		 ON CONFLICT (device_id, anime_id) DO UPDATE
+		 SET `+historyForward+`, watched_at = now(), updated_at = now()`,
+		deviceID, history.AnimeID, history.LastWatchedEpisode, history.IsWatched, history.Reset,
+	)
+	return err
+}
+
+// historyForward merges EXCLUDED into the stored history row in the same way
+// MergeDevice does, unless the boolean $5 asks for a reset.
+const historyForward = `last_watched = CASE
+		   WHEN $5::boolean THEN EXCLUDED.last_watched
+		   ELSE GREATEST(history.last_watched, EXCLUDED.last_watched)
+		 END,
+		 is_watched = CASE
+		   WHEN $5::boolean OR EXCLUDED.last_watched > history.last_watched THEN EXCLUDED.is_watched
+		   WHEN EXCLUDED.last_watched = history.last_watched THEN history.is_watched OR EXCLUDED.is_watched
+		   ELSE history.is_watched
+		 END`
+
+// ApplyHistory writes history entries recorded offline in one transaction.
+// An entry is only merged into the stored one if it was recorded at or after
+// the stored updated_at; the result reports which ones were written. Entries
+// without watched_at are taken to be watched when they were recorded.
+func (r *HistoryRepo) ApplyHistory(ctx context.Context, deviceID string, history []model.SyncHistory) ([]bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer tx.Rollback()
 
-	if exists {
-		_, err = r.db.Exec(
-			`UPDATE history
-             SET last_watched=$1, is_watched=$2, watched_at=now()
-             WHERE device_id=$3 AND anime_id=$4`,
-			history.LastWatchedEpisode, history.IsWatched, deviceID, history.AnimeID,
-		)
-	} else {
-		_, err = r.db.Exec(
-			`INSERT INTO history (device_id, anime_id, last_watched, is_watched, watched_at) VALUES ($1, $2, $3, $4, now())`,
-			deviceID, history.AnimeID, history.LastWatchedEpisode, history.IsWatched,
-		)
+	applied := make([]bool, len(history))
+	for i, h := range history {
+		watchedAt := h.UpdatedAt
+		if h.WatchedAt != nil {
+			watchedAt = *h.WatchedAt
+		}
+
+		err := tx.QueryRowContext(ctx,
+			`INSERT INTO history (device_id, anime_id, last_watched, is_watched, watched_at, updated_at)
+			 VALUES ($1, $2, $3, $4, $6, $7)
+			 ON CONFLICT (device_id, anime_id) DO UPDATE
+			 SET `+historyForward+`, watched_at = EXCLUDED.watched_at, updated_at = EXCLUDED.updated_at
+			 WHERE history.updated_at <= EXCLUDED.updated_at
+			 RETURNING true`,
+			deviceID, h.AnimeID, h.LastWatchedEpisode, h.IsWatched, h.Reset, watchedAt, h.UpdatedAt,
+		).Scan(&applied[i])
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
 	}
 
-	return err
+	return applied, tx.Commit()
+}
+
+func (r *HistoryRepo) GetHistoryForAnime(ctx context.Context, deviceID, animeID string) (*model.History, error) {
+	row := r.db.QueryRowContext(ctx,
+		"SELECT anime_id, last_watched, is_watched, watched_at FROM history WHERE device_id = $1 AND anime_id = $2",
+		deviceID, animeID,
+	)
+
+	var h model.History
+	err := row.Scan(&h.AnimeID, &h.LastWatchedEpisode, &h.IsWatched, &h.WatchedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &h, nil
 }
 
-func (r *HistoryRepo) GetAllHistory(deviceID string) ([]model.History, error) {
-	rows, err := r.db.Query(
+func (r *HistoryRepo) GetAllHistory(ctx context.Context, deviceID string) ([]model.History, error) {
+	rows, err := r.db.QueryContext(ctx,
 		"SELECT anime_id, last_watched, is_watched, watched_at FROM history WHERE device_id = $1 ORDER BY watched_at DESC",
 		deviceID,
 	)
@@ -72,11 +124,11 @@ func (r *HistoryRepo) GetAllHistory(deviceID string) ([]model.History, error) {
 	return historyList, nil
 }
 
-func (r *HistoryRepo) GetHistory(deviceID string, page, limit int) (model.PaginatedHistory, error) {
+func (r *HistoryRepo) GetHistory(ctx context.Context, deviceID string, page, limit int) (model.PaginatedHistory, error) {
 	offset := (page - 1) * limit
 
 	var total int
-	err := r.db.QueryRow(
+	err := r.db.QueryRowContext(ctx,
 		"SELECT COUNT(*) FROM history WHERE device_id = $1",
 		deviceID,
 	).Scan(&total)
@@ -85,9 +137,8 @@ func (r *HistoryRepo) GetHistory(deviceID string, page, limit int) (model.Pagina
 	}
 
 	query := "SELECT anime_id, last_watched, is_watched, watched_at FROM history WHERE device_id = $1 ORDER BY watched_at DESC LIMIT $2 OFFSET $3"
-	rows, err := r.db.Query(query, deviceID, limit, offset)
+	rows, err := r.db.QueryContext(ctx, query, deviceID, limit, offset)
 	if err != nil {
-		fmt.Printf("Query error: %v\n", err)
 		return model.PaginatedHistory{}, err
 	}
 	defer rows.Close()
@@ -0,0 +1,342 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/astanx/anime_api/internal/db"
+	"github.com/astanx/anime_api/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const concurrentWriters = 16
+
+// concurrently runs fn from concurrentWriters goroutines at once and fails
+// the test if any call errors.
+func concurrently(t *testing.T, fn func(i int) error) {
+	t.Helper()
+
+	start := make(chan struct{})
+	errs := make([]error, concurrentWriters)
+	var wg sync.WaitGroup
+	for i := range concurrentWriters {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			errs[i] = fn(i)
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+}
+
+// countRows returns how many rows deviceID has in table with column = value.
+func countRows(t *testing.T, d *db.DB, table, column, deviceID, value string) int {
+	t.Helper()
+
+	var n int
+	err := d.Postgres.QueryRow(
+		"SELECT count(*) FROM "+table+" WHERE device_id = $1 AND "+column+" = $2",
+		deviceID, value,
+	).Scan(&n)
+	require.NoError(t, err)
+	return n
+}
+
+func TestLibraryUniqueIndexes(t *testing.T) {
+	d := testDB(t)
+
+	for _, index := range []string{
+		"timecodes_device_episode_key",
+		"history_device_anime_key",
+		"collections_device_anime_key",
+		"favourites_device_anime_key",
+	} {
+		var unique bool
+		err := d.Postgres.QueryRow(
+			`SELECT i.indisunique FROM pg_index i JOIN pg_class c ON c.oid = i.indexrelid WHERE c.relname = $1`,
+			index,
+		).Scan(&unique)
+		require.NoError(t, err, index)
+		assert.True(t, unique, index)
+	}
+}
+
+func TestAddTimecodeConcurrent(t *testing.T) {
+	d := testDB(t)
+	deviceID := testDevice(t, d)
+	repo := NewTimecodeRepo(d)
+	ctx := context.Background()
+
+	concurrently(t, func(i int) error {
+		return repo.AddTimecode(ctx, deviceID, model.Timecode{
+			AnimeID:   "anime",
+			EpisodeID: "episode",
+			Time:      i,
+		})
+	})
+
+	assert.Equal(t, 1, countRows(t, d, "timecodes", "episode_id", deviceID, "episode"))
+}
+
+func TestAddHistoryConcurrent(t *testing.T) {
+	d := testDB(t)
+	deviceID := testDevice(t, d)
+	repo := NewHistoryRepo(d)
+	ctx := context.Background()
+
+	concurrently(t, func(i int) error {
+		return repo.AddHistory(ctx, deviceID, model.History{
+			AnimeID:            "anime",
+			LastWatchedEpisode: i + 1,
+		})
+	})
+
+	assert.Equal(t, 1, countRows(t, d, "history", "anime_id", deviceID, "anime"))
+
+	h, err := repo.GetHistoryForAnime(ctx, deviceID, "anime")
+	require.NoError(t, err)
+	require.NotNil(t, h)
+	assert.Equal(t, concurrentWriters, h.LastWatchedEpisode)
+}
+
+func TestAddCollectionConcurrent(t *testing.T) {
+	d := testDB(t)
+	deviceID := testDevice(t, d)
+	repo := NewCollectionRepo(d)
+	ctx := context.Background()
+
+	types := []string{"planned", "watching", "watched", "abandoned"}
+	concurrently(t, func(i int) error {
+		return repo.AddCollection(ctx, deviceID, model.Collection{
+			AnimeID: "anime",
+			Type:    types[i%len(types)],
+		})
+	})
+
+	assert.Equal(t, 1, countRows(t, d, "collections", "anime_id", deviceID, "anime"))
+}
+
+func TestAddFavouriteConcurrent(t *testing.T) {
+	d := testDB(t)
+	deviceID := testDevice(t, d)
+	repo := NewFavouriteRepo(d)
+	ctx := context.Background()
+
+	concurrently(t, func(int) error {
+		return repo.AddFavourite(ctx, deviceID, model.Favourite{AnimeID: "anime"})
+	})
+
+	assert.Equal(t, 1, countRows(t, d, "favourites", "anime_id", deviceID, "anime"))
+}
+
+func TestAddHistoryForwardOnly(t *testing.T) {
+	d := testDB(t)
+	repo := NewHistoryRepo(d)
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		writes      []model.History
+		lastWatched int
+		isWatched   bool
+	}{
+		{
+			name: "moves forward",
+			writes: []model.History{
+				{LastWatchedEpisode: 3},
+				{LastWatchedEpisode: 5},
+			},
+			lastWatched: 5,
+		},
+		{
+			name: "ignores older episode",
+			writes: []model.History{
+				{LastWatchedEpisode: 5},
+				{LastWatchedEpisode: 2},
+			},
+			lastWatched: 5,
+		},
+		{
+			name: "older episode keeps watched flag",
+			writes: []model.History{
+				{LastWatchedEpisode: 12, IsWatched: true},
+				{LastWatchedEpisode: 4},
+			},
+			lastWatched: 12,
+			isWatched:   true,
+		},
+		{
+			name: "same episode marks watched",
+			writes: []model.History{
+				{LastWatchedEpisode: 12},
+				{LastWatchedEpisode: 12, IsWatched: true},
+			},
+			lastWatched: 12,
+			isWatched:   true,
+		},
+		{
+			name: "reset moves back",
+			writes: []model.History{
+				{LastWatchedEpisode: 12, IsWatched: true},
+				{LastWatchedEpisode: 1, Reset: true},
+			},
+			lastWatched: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deviceID := testDevice(t, d)
+			for _, h := range tt.writes {
+				h.AnimeID = "anime"
+				require.NoError(t, repo.AddHistory(ctx, deviceID, h))
+			}
+
+			h, err := repo.GetHistoryForAnime(ctx, deviceID, "anime")
+			require.NoError(t, err)
+			require.NotNil(t, h)
+			assert.Equal(t, tt.lastWatched, h.LastWatchedEpisode)
+			assert.Equal(t, tt.isWatched, h.IsWatched)
+		})
+	}
+}
+
+func TestApplyTimecodesStale(t *testing.T) {
+	d := testDB(t)
+	repo := NewTimecodeRepo(d)
+	ctx := context.Background()
+	earlier := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	later := earlier.Add(time.Hour)
+
+	tests := []struct {
+		name    string
+		writes  []model.SyncTimecode
+		applied []bool
+		time    int
+	}{
+		{
+			name: "newer replaces older",
+			writes: []model.SyncTimecode{
+				{Timecode: model.Timecode{Time: 100}, UpdatedAt: earlier},
+				{Timecode: model.Timecode{Time: 50}, UpdatedAt: later},
+			},
+			applied: []bool{true, true},
+			time:    50,
+		},
+		{
+			name: "older is stale",
+			writes: []model.SyncTimecode{
+				{Timecode: model.Timecode{Time: 100}, UpdatedAt: later},
+				{Timecode: model.Timecode{Time: 900, IsWatched: true}, UpdatedAt: earlier},
+			},
+			applied: []bool{true, false},
+			time:    100,
+		},
+		{
+			name: "same time is applied",
+			writes: []model.SyncTimecode{
+				{Timecode: model.Timecode{Time: 100}, UpdatedAt: later},
+				{Timecode: model.Timecode{Time: 200}, UpdatedAt: later},
+			},
+			applied: []bool{true, true},
+			time:    200,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deviceID := testDevice(t, d)
+			var applied []bool
+			for _, tc := range tt.writes {
+				tc.AnimeID, tc.EpisodeID = "anime", "episode"
+				got, err := repo.ApplyTimecodes(ctx, deviceID, []model.SyncTimecode{tc})
+				require.NoError(t, err)
+				applied = append(applied, got...)
+			}
+			assert.Equal(t, tt.applied, applied)
+
+			timecodes, err := repo.GetTimecodesForAnime(ctx, deviceID, "anime")
+			require.NoError(t, err)
+			require.Len(t, timecodes, 1)
+			assert.Equal(t, tt.time, timecodes[0].Time)
+			assert.False(t, timecodes[0].IsWatched)
+		})
+	}
+}
+
+func TestApplyHistoryStale(t *testing.T) {
+	d := testDB(t)
+	repo := NewHistoryRepo(d)
+	ctx := context.Background()
+	earlier := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	later := earlier.Add(time.Hour)
+
+	tests := []struct {
+		name        string
+		writes      []model.SyncHistory
+		applied     []bool
+		lastWatched int
+		watchedAt   time.Time
+	}{
+		{
+			name: "newer moves forward",
+			writes: []model.SyncHistory{
+				{History: model.History{LastWatchedEpisode: 3}, UpdatedAt: earlier},
+				{History: model.History{LastWatchedEpisode: 5}, UpdatedAt: later},
+			},
+			applied:     []bool{true, true},
+			lastWatched: 5,
+			watchedAt:   later,
+		},
+		{
+			name: "older is stale",
+			writes: []model.SyncHistory{
+				{History: model.History{LastWatchedEpisode: 3}, UpdatedAt: later},
+				{History: model.History{LastWatchedEpisode: 8}, UpdatedAt: earlier},
+			},
+			applied:     []bool{true, false},
+			lastWatched: 3,
+			watchedAt:   later,
+		},
+		{
+			name: "older reset is stale",
+			writes: []model.SyncHistory{
+				{History: model.History{LastWatchedEpisode: 12}, UpdatedAt: later},
+				{History: model.History{LastWatchedEpisode: 1, Reset: true}, UpdatedAt: earlier},
+			},
+			applied:     []bool{true, false},
+			lastWatched: 12,
+			watchedAt:   later,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deviceID := testDevice(t, d)
+			var applied []bool
+			for _, h := range tt.writes {
+				h.AnimeID = "anime"
+				got, err := repo.ApplyHistory(ctx, deviceID, []model.SyncHistory{h})
+				require.NoError(t, err)
+				applied = append(applied, got...)
+			}
+			assert.Equal(t, tt.applied, applied)
+
+			h, err := repo.GetHistoryForAnime(ctx, deviceID, "anime")
+			require.NoError(t, err)
+			require.NotNil(t, h)
+			assert.Equal(t, tt.lastWatched, h.LastWatchedEpisode)
+			require.NotNil(t, h.WatchedAt)
+			assert.True(t, tt.watchedAt.Equal(*h.WatchedAt), "watched_at %s", h.WatchedAt)
+		})
+	}
+}
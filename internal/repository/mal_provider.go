@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/astanx/anime_api/internal/model"
+)
+
+type MALProvider struct {
+	repo *TorrentRepo
+}
+
+func NewMALProvider(repo *TorrentRepo) *MALProvider {
+	return &MALProvider{repo: repo}
+}
+
+func (p *MALProvider) Name() string {
+	return "mal"
+}
+
+func (p *MALProvider) Search(ctx context.Context, query string, page int) (model.PaginatedSearchAnime, error) {
+	return p.repo.SearchMALAnime(ctx, query, page)
+}
+
+func (p *MALProvider) Latest(ctx context.Context, limit, page int) ([]model.SearchAnime, error) {
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+	return p.repo.SearchMALLatestReleases(ctx, limit, page)
+}
+
+func (p *MALProvider) Recommended(ctx context.Context, limit, page int) ([]model.SearchAnime, error) {
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+	return p.repo.SearchMALRecommendedAnime(ctx, limit, page)
+}
+
+func (p *MALProvider) Genres(ctx context.Context) ([]model.Genre, error) {
+	return p.repo.GetMALGenres(ctx)
+}
+
+func (p *MALProvider) GenreReleases(ctx context.Context, genre string, limit, page int) (model.PaginatedSearchAnime, error) {
+	genreID, err := strconv.Atoi(genre)
+	if err != nil {
+		return model.PaginatedSearchAnime{}, fmt.Errorf("mal genre must be an integer id: %w", err)
+	}
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+	return p.repo.SearchMALGenreReleases(ctx, genreID, limit, page)
+}
+
+func (p *MALProvider) AnimeInfo(ctx context.Context, id string) (model.Anime, error) {
+	return p.repo.SearchMALById(ctx, id)
+}
+
+// EpisodeInfo accepts either the "<animeID>/<episode>" IDs produced by
+// SearchMALByEpisodeId or a bare episode number together with params.AnimeID.
+func (p *MALProvider) EpisodeInfo(ctx context.Context, id string, params EpisodeParams) (model.Episode, error) {
+	animeID, episodeID, found := strings.Cut(id, "/")
+	if !found {
+		animeID, episodeID = params.AnimeID, id
+	}
+	if animeID == "" {
+		return model.Episode{}, fmt.Errorf("mal episode %s requires an anime id", id)
+	}
+	return p.repo.SearchMALByEpisodeId(ctx, animeID, episodeID)
+}
@@ -1,9 +1,11 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"encoding/xml"
 	"fmt"
+	"log/slog"
 	"strconv"
 	"time"
 
@@ -21,9 +23,10 @@ type MALRepo struct {
 	historyRepo    HistoryRepo
 	timecodeRepo   TimecodeRepo
 	animeRepo      AnimeRepo
+	mappingRepo    MappingRepo
 }
 
-func NewMALRepo(db *db.DB, collectionRepo CollectionRepo, historyRepo HistoryRepo, timecodeRepo TimecodeRepo, animeRepo AnimeRepo) *MALRepo {
+func NewMALRepo(db *db.DB, collectionRepo CollectionRepo, historyRepo HistoryRepo, timecodeRepo TimecodeRepo, animeRepo AnimeRepo, mappingRepo MappingRepo) *MALRepo {
 	return &MALRepo{
 		dbPostgres:     db.Postgres,
 		dbClickhouse:   db.ClickHouse,
@@ -32,6 +35,7 @@ func NewMALRepo(db *db.DB, collectionRepo CollectionRepo, historyRepo HistoryRep
 		historyRepo:    historyRepo,
 		timecodeRepo:   timecodeRepo,
 		animeRepo:      animeRepo,
+		mappingRepo:    mappingRepo,
 	}
 }
 
@@ -65,7 +69,7 @@ func convertMalToStatus(mal string) (string, error) {
 	}
 }
 
-func (r *MALRepo) ImportMALList(deviceID, malList string) (int, error) {
+func (r *MALRepo) ImportMALList(ctx context.Context, deviceID, malList string) (int, error) {
 
 	var mal model.MALList
 	if err := xml.Unmarshal([]byte(malList), &mal); err != nil {
@@ -75,22 +79,25 @@ func (r *MALRepo) ImportMALList(deviceID, malList string) (int, error) {
 	var count int
 
 	for _, anime := range mal.Animes {
-		res, err := r.animeRepo.SearchConsumetAnime(anime.SeriesTitle, 1)
+		if err := ctx.Err(); err != nil {
+			return count, err
+		}
 
+		candidates, err := r.consumetCandidates(ctx, anime)
 		if err != nil {
 			continue
 		}
 
 		now := time.Now()
 
-		for _, a := range res.Data {
-			idAnime, err := r.animeRepo.GetAnimeInfoByConsumetID(a.ID)
+		for _, a := range candidates {
+			idAnime, err := r.animeRepo.GetAnimeInfoByConsumetID(ctx, a.ID)
 
 			if err != nil {
 				continue
 			}
 
-			fmt.Println("Parsed anime malID ", idAnime.MalID, "Trying to find ", anime.SeriesAnimeDBID)
+			slog.DebugContext(ctx, "matching MAL list entry", "mal_id", idAnime.MalID, "want_mal_id", anime.SeriesAnimeDBID)
 
 			if idAnime.MalID == anime.SeriesAnimeDBID {
 				status, err := convertMalToStatus(anime.MyStatus)
@@ -103,7 +110,7 @@ func (r *MALRepo) ImportMALList(deviceID, malList string) (int, error) {
 					AnimeID: a.ID,
 				}
 
-				r.collectionRepo.AddCollection(deviceID, collection)
+				r.collectionRepo.AddCollection(ctx, deviceID, collection)
 				count++
 
 				if anime.MyWatchedEpisodes > idAnime.TotalEpisodes || anime.MyWatchedEpisodes == 0 {
@@ -116,7 +123,7 @@ func (r *MALRepo) ImportMALList(deviceID, malList string) (int, error) {
 					IsWatched:          anime.MyWatchedEpisodes == idAnime.TotalEpisodes,
 					WatchedAt:          &now,
 				}
-				r.historyRepo.AddHistory(deviceID, history)
+				r.historyRepo.AddHistory(ctx, deviceID, history)
 
 				for number := 0; number < anime.MyWatchedEpisodes; number++ {
 					episode := idAnime.Episodes[number]
@@ -128,7 +135,7 @@ func (r *MALRepo) ImportMALList(deviceID, malList string) (int, error) {
 						AnimeID:   idAnime.ID,
 					}
 
-					r.timecodeRepo.AddTimecode(deviceID, timecode)
+					r.timecodeRepo.AddTimecode(ctx, deviceID, timecode)
 				}
 				break
 			}
@@ -138,8 +145,28 @@ func (r *MALRepo) ImportMALList(deviceID, malList string) (int, error) {
 	return count, nil
 }
 
-func (r *MALRepo) ExportMALList(deviceID string) (string, error) {
-	rows, err := r.dbPostgres.Query(
+// consumetCandidates returns the Consumet entries that may match a MAL list
+// entry: the mapped entry when the MAL ID is already known, otherwise the
+// results of a title search.
+func (r *MALRepo) consumetCandidates(ctx context.Context, anime model.MALListAnime) ([]model.SearchAnime, error) {
+	mappings, err := r.mappingRepo.GetMappings(ctx, "mal", strconv.Itoa(anime.SeriesAnimeDBID))
+	if err == nil {
+		for _, m := range mappings {
+			if m.ConsumetID != "" {
+				return []model.SearchAnime{{ID: m.ConsumetID, MalID: m.MalID}}, nil
+			}
+		}
+	}
+
+	res, err := r.animeRepo.SearchConsumetAnime(ctx, anime.SeriesTitle, 1)
+	if err != nil {
+		return nil, err
+	}
+	return res.Data, nil
+}
+
+func (r *MALRepo) ExportMALList(ctx context.Context, deviceID string) (string, error) {
+	rows, err := r.dbPostgres.QueryContext(ctx,
 		`SELECT c.anime_id, c.type, h.last_watched FROM collections as c 
 		LEFT JOIN history as h 
 		ON h.device_id = c.device_id AND h.anime_id = c.anime_id
@@ -166,7 +193,7 @@ func (r *MALRepo) ExportMALList(deviceID string) (string, error) {
 			continue
 		}
 
-		idAnime, err := r.animeRepo.GetAnimeInfoByConsumetID(animeID)
+		idAnime, err := r.animeRepo.GetAnimeInfoByConsumetID(ctx, animeID)
 
 		if err != nil {
 			continue
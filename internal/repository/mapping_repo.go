@@ -0,0 +1,279 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+
+	"github.com/astanx/anime_api/internal/db"
+	"github.com/astanx/anime_api/internal/model"
+)
+
+type MappingRepo struct {
+	dbPostgres *sql.DB
+}
+
+func NewMappingRepo(db *db.DB) *MappingRepo {
+	return &MappingRepo{
+		dbPostgres: db.Postgres,
+	}
+}
+
+func mappingColumn(provider string) (string, error) {
+	switch provider {
+	case "mal":
+		return "mal_id", nil
+	case "anilibria":
+		return "anilibria_id", nil
+	case "consumet":
+		return "consumet_id", nil
+	default:
+		return "", ErrUnknownProvider
+	}
+}
+
+func (r *MappingRepo) SaveMapping(ctx context.Context, mapping model.AnimeMapping) error {
+	return saveMapping(ctx, r.dbPostgres, mapping)
+}
+
+// GetMappings resolves an ID to every mapping row it appears in. When
+// provider is empty the ID is matched against all provider columns, which
+// may return more than one row since MAL and Anilibria both use numbers.
+func (r *MappingRepo) GetMappings(ctx context.Context, provider, id string) ([]model.AnimeMapping, error) {
+	var rows *sql.Rows
+	var err error
+
+	if provider != "" {
+		column, err := mappingColumn(provider)
+		if err != nil {
+			return nil, err
+		}
+		rows, err = r.dbPostgres.QueryContext(ctx,
+			fmt.Sprintf("SELECT mal_id, anilibria_id, consumet_id FROM anime_mappings WHERE %s = $1", column),
+			id,
+		)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		var malID sql.NullInt64
+		if n, err := strconv.Atoi(id); err == nil {
+			malID = sql.NullInt64{Int64: int64(n), Valid: true}
+		}
+		rows, err = r.dbPostgres.QueryContext(ctx,
+			`SELECT mal_id, anilibria_id, consumet_id FROM anime_mappings
+			 WHERE mal_id = $1 OR anilibria_id = $2 OR consumet_id = $2`,
+			malID, id,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+	defer rows.Close()
+
+	mappings := make([]model.AnimeMapping, 0)
+	for rows.Next() {
+		m, err := scanMapping(rows)
+		if err != nil {
+			return nil, err
+		}
+		mappings = append(mappings, m)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return mappings, nil
+}
+
+// MalIDs looks up the MAL IDs known for a batch of provider IDs.
+func (r *MappingRepo) MalIDs(ctx context.Context, provider string, ids []string) (map[string]int, error) {
+	result := make(map[string]int)
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	column, err := mappingColumn(provider)
+	if err != nil {
+		return nil, err
+	}
+	if column == "mal_id" {
+		for _, id := range ids {
+			if n, err := strconv.Atoi(id); err == nil {
+				result[id] = n
+			}
+		}
+		return result, nil
+	}
+
+	rows, err := r.dbPostgres.QueryContext(ctx,
+		fmt.Sprintf("SELECT %s, mal_id FROM anime_mappings WHERE %s = ANY($1) AND mal_id IS NOT NULL", column, column),
+		ids,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		var malID int
+		if err := rows.Scan(&id, &malID); err != nil {
+			return nil, err
+		}
+		result[id] = malID
+	}
+
+	return result, rows.Err()
+}
+
+// SeedFromFile bulk-loads mappings from a JSON array of AnimeMapping objects
+// and returns how many were stored.
+func (r *MappingRepo) SeedFromFile(ctx context.Context, path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var mappings []model.AnimeMapping
+	if err := json.Unmarshal(data, &mappings); err != nil {
+		return 0, fmt.Errorf("failed to parse mapping file: %w", err)
+	}
+
+	var count int
+	for _, m := range mappings {
+		if err := saveMapping(ctx, r.dbPostgres, m); err != nil {
+			slog.ErrorContext(ctx, "failed to seed mapping", "mapping", m, "error", err)
+			continue
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+type mappingScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanMapping(row mappingScanner) (model.AnimeMapping, error) {
+	var malID sql.NullInt64
+	var anilibriaID, consumetID sql.NullString
+	if err := row.Scan(&malID, &anilibriaID, &consumetID); err != nil {
+		return model.AnimeMapping{}, err
+	}
+	return model.AnimeMapping{
+		MalID:       int(malID.Int64),
+		AnilibriaID: anilibriaID.String,
+		ConsumetID:  consumetID.String,
+	}, nil
+}
+
+// saveMapping merges the given IDs into the mapping table. Rows that already
+// share one of the IDs are folded into a single row; existing non-empty
+// values win over new ones. A mapping with fewer than two IDs links nothing
+// and is ignored.
+func saveMapping(ctx context.Context, db *sql.DB, mapping model.AnimeMapping) error {
+	malID := sql.NullInt64{Int64: int64(mapping.MalID), Valid: mapping.MalID != 0}
+	anilibriaID := sql.NullString{String: mapping.AnilibriaID, Valid: mapping.AnilibriaID != ""}
+	consumetID := sql.NullString{String: mapping.ConsumetID, Valid: mapping.ConsumetID != ""}
+
+	known := 0
+	for _, valid := range []bool{malID.Valid, anilibriaID.Valid, consumetID.Valid} {
+		if valid {
+			known++
+		}
+	}
+	if known < 2 {
+		return nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT id, mal_id, anilibria_id, consumet_id FROM anime_mappings
+		 WHERE mal_id = $1 OR anilibria_id = $2 OR consumet_id = $3
+		 ORDER BY id FOR UPDATE`,
+		malID, anilibriaID, consumetID,
+	)
+	if err != nil {
+		return err
+	}
+
+	var ids []int64
+	merged := model.AnimeMapping{}
+	for rows.Next() {
+		var id int64
+		var m model.AnimeMapping
+		var rowMal sql.NullInt64
+		var rowAnilibria, rowConsumet sql.NullString
+		if err := rows.Scan(&id, &rowMal, &rowAnilibria, &rowConsumet); err != nil {
+			rows.Close()
+			return err
+		}
+		m.MalID, m.AnilibriaID, m.ConsumetID = int(rowMal.Int64), rowAnilibria.String, rowConsumet.String
+		merged = mergeMapping(merged, m)
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	merged = mergeMapping(merged, mapping)
+	malID = sql.NullInt64{Int64: int64(merged.MalID), Valid: merged.MalID != 0}
+	anilibriaID = sql.NullString{String: merged.AnilibriaID, Valid: merged.AnilibriaID != ""}
+	consumetID = sql.NullString{String: merged.ConsumetID, Valid: merged.ConsumetID != ""}
+
+	if len(ids) == 0 {
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO anime_mappings (mal_id, anilibria_id, consumet_id, updated_at)
+			 VALUES ($1, $2, $3, now()) ON CONFLICT DO NOTHING`,
+			malID, anilibriaID, consumetID,
+		)
+		if err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	if len(ids) > 1 {
+		if _, err = tx.ExecContext(ctx, "DELETE FROM anime_mappings WHERE id = ANY($1)", ids[1:]); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE anime_mappings
+		 SET mal_id=$1, anilibria_id=$2, consumet_id=$3, updated_at=now()
+		 WHERE id=$4`,
+		malID, anilibriaID, consumetID, ids[0],
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func mergeMapping(base, m model.AnimeMapping) model.AnimeMapping {
+	if base.MalID == 0 {
+		base.MalID = m.MalID
+	}
+	if base.AnilibriaID == "" {
+		base.AnilibriaID = m.AnilibriaID
+	}
+	if base.ConsumetID == "" {
+		base.ConsumetID = m.ConsumetID
+	}
+	return base
+}
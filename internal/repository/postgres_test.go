@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/astanx/anime_api/internal/analytics"
+	"github.com/astanx/anime_api/internal/db"
+	"github.com/google/uuid"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/stretchr/testify/require"
+)
+
+// testDB connects to the Postgres named by TEST_POSTGRES_DSN and migrates it
+// up, skipping the test when the variable is unset. Tests share the database
+// and keep apart by writing under their own device ID.
+func testDB(t *testing.T) *db.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN is not set")
+	}
+
+	pg, err := sql.Open("pgx", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { pg.Close() })
+	require.NoError(t, pg.Ping())
+	require.NoError(t, db.MigratePostgres(pg, db.MigrateUp, 0))
+
+	return &db.DB{Postgres: pg, Analytics: analytics.NewNoopSink()}
+}
+
+// testDevice returns a fresh device ID whose library rows are removed when
+// the test ends.
+func testDevice(t *testing.T, d *db.DB) string {
+	t.Helper()
+
+	deviceID := uuid.NewString()
+	t.Cleanup(func() {
+		for _, table := range []string{"timecodes", "history", "collections", "favourites"} {
+			d.Postgres.ExecContext(context.Background(), "DELETE FROM "+table+" WHERE device_id = $1", deviceID)
+		}
+	})
+	return deviceID
+}
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/astanx/anime_api/internal/db"
+	"github.com/astanx/anime_api/internal/model"
+)
+
+// ProgressRepo answers questions about a device's playback progress that span
+// timecodes, history, collections and the stored episodes.
+type ProgressRepo struct {
+	dbPostgres *sql.DB
+}
+
+func NewProgressRepo(db *db.DB) *ProgressRepo {
+	return &ProgressRepo{
+		dbPostgres: db.Postgres,
+	}
+}
+
+// StoredEpisode returns the ordinal and opening/ending marks of an episode
+// whose info has been fetched before. ok is false if it hasn't.
+func (r *ProgressRepo) StoredEpisode(ctx context.Context, episodeID string) (model.Episode, bool, error) {
+	var (
+		episode                                          model.Episode
+		openingStart, openingEnd, endingStart, endingEnd sql.NullInt64
+	)
+	err := r.dbPostgres.QueryRowContext(ctx,
+		`SELECT id, ordinal, title, opening_start, opening_end, ending_start, ending_end
+		 FROM episodes WHERE id = $1`,
+		episodeID,
+	).Scan(&episode.ID, &episode.Ordinal, &episode.Title, &openingStart, &openingEnd, &endingStart, &endingEnd)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Episode{}, false, nil
+	}
+	if err != nil {
+		return model.Episode{}, false, err
+	}
+
+	episode.Opening = model.TimeSegment{Start: int(openingStart.Int64), End: int(openingEnd.Int64)}
+	episode.Ending = model.TimeSegment{Start: int(endingStart.Int64), End: int(endingEnd.Int64)}
+	return episode, true, nil
+}
+
+// InProgress returns the titles deviceID has started but not finished, most
+// recently watched first: those with an unfinished history entry or a
+// partly played episode, unless their collection is watched or abandoned.
+// EpisodeID and Time are set when a partly played episode is newer than the
+// last finished one, and the caller works out the next episode otherwise.
+func (r *ProgressRepo) InProgress(ctx context.Context, deviceID string, limit int) ([]model.ContinueWatching, error) {
+	rows, err := r.dbPostgres.QueryContext(ctx,
+		`WITH partial AS (
+		   SELECT DISTINCT ON (anime_id) anime_id, episode_id, time, updated_at
+		   FROM timecodes
+		   WHERE device_id = $1 AND NOT is_watched AND time > 0
+		   ORDER BY anime_id, updated_at DESC
+		 ), titles AS (
+		   SELECT anime_id FROM history WHERE device_id = $1 AND NOT is_watched
+		   UNION
+		   SELECT anime_id FROM partial
+		 )
+		 SELECT t.anime_id,
+		        COALESCE(h.last_watched, 0),
+		        CASE WHEN p.updated_at >= COALESCE(h.watched_at, '-infinity') THEN p.episode_id ELSE '' END,
+		        CASE WHEN p.updated_at >= COALESCE(h.watched_at, '-infinity') THEN p.time ELSE 0 END,
+		        GREATEST(h.watched_at, p.updated_at) AS active_at
+		 FROM titles t
+		 LEFT JOIN history h ON h.device_id = $1 AND h.anime_id = t.anime_id
+		 LEFT JOIN partial p ON p.anime_id = t.anime_id
+		 LEFT JOIN collections c ON c.device_id = $1 AND c.anime_id = t.anime_id
+		 WHERE NOT COALESCE(h.is_watched, false)
+		   AND COALESCE(c.type, '') NOT IN ('watched', 'abandoned')
+		 ORDER BY active_at DESC
+		 LIMIT $2`,
+		deviceID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	titles := make([]model.ContinueWatching, 0)
+	for rows.Next() {
+		var t model.ContinueWatching
+		if err := rows.Scan(&t.AnimeID, &t.LastWatched, &t.EpisodeID, &t.Time, &t.WatchedAt); err != nil {
+			return nil, err
+		}
+		titles = append(titles, t)
+	}
+	return titles, rows.Err()
+}
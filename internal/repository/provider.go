@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/astanx/anime_api/internal/model"
+)
+
+var (
+	ErrUnknownProvider   = errors.New("unknown provider")
+	ErrLimitNotSupported = errors.New("provider has a fixed page size, limit is not supported")
+)
+
+// defaultPageSize is used by providers that take a page size when the caller
+// gives none.
+const defaultPageSize = 14
+
+type EpisodeParams struct {
+	AnimeID string
+	Title   string
+	Ordinal int
+	Dub     bool
+}
+
+// Provider is an upstream anime source. Every provider registered in a
+// ProviderRegistry is mounted under /anime/<name>/ by the router.
+//
+// Listing methods treat limit <= 0 as the provider's default page size and
+// return ErrLimitNotSupported for limits the provider can't honour.
+type Provider interface {
+	Name() string
+	Search(ctx context.Context, query string, page int) (model.PaginatedSearchAnime, error)
+	Latest(ctx context.Context, limit, page int) ([]model.SearchAnime, error)
+	Recommended(ctx context.Context, limit, page int) ([]model.SearchAnime, error)
+	Genres(ctx context.Context) ([]model.Genre, error)
+	GenreReleases(ctx context.Context, genre string, limit, page int) (model.PaginatedSearchAnime, error)
+	AnimeInfo(ctx context.Context, id string) (model.Anime, error)
+	EpisodeInfo(ctx context.Context, id string, params EpisodeParams) (model.Episode, error)
+}
+
+type ProviderRegistry struct {
+	providers map[string]Provider
+	names     []string
+}
+
+func NewProviderRegistry(providers ...Provider) *ProviderRegistry {
+	r := &ProviderRegistry{
+		providers: make(map[string]Provider),
+	}
+	for _, p := range providers {
+		r.Register(p)
+	}
+	return r
+}
+
+func (r *ProviderRegistry) Register(p Provider) {
+	if _, exists := r.providers[p.Name()]; !exists {
+		r.names = append(r.names, p.Name())
+	}
+	r.providers[p.Name()] = p
+}
+
+func (r *ProviderRegistry) Get(name string) (Provider, error) {
+	p, ok := r.providers[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	return p, nil
+}
+
+// Names returns provider names in registration order.
+func (r *ProviderRegistry) Names() []string {
+	names := make([]string, len(r.names))
+	copy(names, r.names)
+	return names
+}
+
+func (r *ProviderRegistry) All() []Provider {
+	providers := make([]Provider, 0, len(r.names))
+	for _, name := range r.names {
+		providers = append(providers, r.providers[name])
+	}
+	return providers
+}
@@ -0,0 +1,219 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+
+	"github.com/astanx/anime_api/internal/db"
+	"github.com/astanx/anime_api/internal/model"
+)
+
+type SyncRepo struct {
+	dbPostgres *sql.DB
+}
+
+func NewSyncRepo(db *db.DB) *SyncRepo {
+	return &SyncRepo{
+		dbPostgres: db.Postgres,
+	}
+}
+
+// Changes returns the rows stored under deviceID that were written or deleted
+// by transactions at or after since, and the position to pass next time.
+// Rows are tagged with their writer's transaction id by the 0007_sync
+// triggers; the next position is the oldest transaction still running when
+// the rows were read, so writes that commit later are picked up next time
+// and some rows may be returned twice. since 0 returns the whole library
+// without deletions.
+func (r *SyncRepo) Changes(ctx context.Context, deviceID string, since uint64) (model.SyncChanges, uint64, error) {
+	var changes model.SyncChanges
+
+	tx, err := r.dbPostgres.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return changes, 0, err
+	}
+	defer tx.Rollback()
+
+	var next string
+	err = tx.QueryRowContext(ctx, "SELECT pg_snapshot_xmin(pg_current_snapshot())::text").Scan(&next)
+	if err != nil {
+		return changes, 0, err
+	}
+	cursor, err := strconv.ParseUint(next, 10, 64)
+	if err != nil {
+		return changes, 0, err
+	}
+
+	from := strconv.FormatUint(since, 10)
+	if changes.Timecodes, err = syncTimecodes(ctx, tx, deviceID, from); err != nil {
+		return changes, 0, err
+	}
+	if changes.History, err = syncHistory(ctx, tx, deviceID, from); err != nil {
+		return changes, 0, err
+	}
+	if changes.Collections, err = syncCollections(ctx, tx, deviceID, from); err != nil {
+		return changes, 0, err
+	}
+	if changes.Favourites, err = syncFavourites(ctx, tx, deviceID, from); err != nil {
+		return changes, 0, err
+	}
+	changes.Deleted = newSyncDeleted()
+	if since > 0 {
+		if changes.Deleted, err = syncTombstones(ctx, tx, deviceID, from); err != nil {
+			return changes, 0, err
+		}
+	}
+
+	return changes, cursor, tx.Commit()
+}
+
+func syncTimecodes(ctx context.Context, tx *sql.Tx, deviceID, since string) ([]model.SyncTimecode, error) {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT time, episode_id, is_watched, anime_id, updated_at
+		 FROM timecodes
+		 WHERE device_id = $1 AND sync_xid >= $2::text::xid8
+		 ORDER BY id`,
+		deviceID, since,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	timecodes := make([]model.SyncTimecode, 0)
+	for rows.Next() {
+		var t model.SyncTimecode
+		if err := rows.Scan(&t.Time, &t.EpisodeID, &t.IsWatched, &t.AnimeID, &t.UpdatedAt); err != nil {
+			return nil, err
+		}
+		timecodes = append(timecodes, t)
+	}
+	return timecodes, rows.Err()
+}
+
+func syncHistory(ctx context.Context, tx *sql.Tx, deviceID, since string) ([]model.SyncHistory, error) {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT anime_id, last_watched, is_watched, watched_at, updated_at
+		 FROM history
+		 WHERE device_id = $1 AND sync_xid >= $2::text::xid8
+		 ORDER BY id`,
+		deviceID, since,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := make([]model.SyncHistory, 0)
+	for rows.Next() {
+		var h model.SyncHistory
+		if err := rows.Scan(&h.AnimeID, &h.LastWatchedEpisode, &h.IsWatched, &h.WatchedAt, &h.UpdatedAt); err != nil {
+			return nil, err
+		}
+		history = append(history, h)
+	}
+	return history, rows.Err()
+}
+
+func syncCollections(ctx context.Context, tx *sql.Tx, deviceID, since string) ([]model.SyncCollection, error) {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT anime_id, type, updated_at
+		 FROM collections
+		 WHERE device_id = $1 AND sync_xid >= $2::text::xid8
+		 ORDER BY id`,
+		deviceID, since,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	collections := make([]model.SyncCollection, 0)
+	for rows.Next() {
+		var c model.SyncCollection
+		if err := rows.Scan(&c.AnimeID, &c.Type, &c.UpdatedAt); err != nil {
+			return nil, err
+		}
+		collections = append(collections, c)
+	}
+	return collections, rows.Err()
+}
+
+func syncFavourites(ctx context.Context, tx *sql.Tx, deviceID, since string) ([]model.SyncFavourite, error) {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT anime_id, updated_at
+		 FROM favourites
+		 WHERE device_id = $1 AND sync_xid >= $2::text::xid8
+		 ORDER BY id`,
+		deviceID, since,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	favourites := make([]model.SyncFavourite, 0)
+	for rows.Next() {
+		var f model.SyncFavourite
+		if err := rows.Scan(&f.AnimeID, &f.UpdatedAt); err != nil {
+			return nil, err
+		}
+		favourites = append(favourites, f)
+	}
+	return favourites, rows.Err()
+}
+
+func newSyncDeleted() model.SyncDeleted {
+	return model.SyncDeleted{
+		Timecodes:   make([]model.SyncTombstone, 0),
+		History:     make([]model.SyncTombstone, 0),
+		Collections: make([]model.SyncTombstone, 0),
+		Favourites:  make([]model.SyncTombstone, 0),
+	}
+}
+
+// syncTombstones skips rows that were deleted and then added again, since
+// the new row is returned as a change.
+func syncTombstones(ctx context.Context, tx *sql.Tx, deviceID, since string) (model.SyncDeleted, error) {
+	deleted := newSyncDeleted()
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT t.entity, t.anime_id, t.episode_id, max(t.deleted_at)
+		 FROM sync_tombstones t
+		 WHERE t.device_id = $1 AND t.sync_xid >= $2::text::xid8
+		   AND NOT CASE t.entity
+		     WHEN 'timecode' THEN EXISTS (SELECT 1 FROM timecodes x WHERE x.device_id = t.device_id AND x.episode_id = t.episode_id)
+		     WHEN 'history' THEN EXISTS (SELECT 1 FROM history x WHERE x.device_id = t.device_id AND x.anime_id = t.anime_id)
+		     WHEN 'collection' THEN EXISTS (SELECT 1 FROM collections x WHERE x.device_id = t.device_id AND x.anime_id = t.anime_id)
+		     WHEN 'favourite' THEN EXISTS (SELECT 1 FROM favourites x WHERE x.device_id = t.device_id AND x.anime_id = t.anime_id)
+		     ELSE false
+		   END
+		 GROUP BY t.entity, t.anime_id, t.episode_id
+		 ORDER BY max(t.deleted_at)`,
+		deviceID, since,
+	)
+	if err != nil {
+		return deleted, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entity string
+		var t model.SyncTombstone
+		if err := rows.Scan(&entity, &t.AnimeID, &t.EpisodeID, &t.DeletedAt); err != nil {
+			return deleted, err
+		}
+		switch entity {
+		case "timecode":
+			deleted.Timecodes = append(deleted.Timecodes, t)
+		case "history":
+			deleted.History = append(deleted.History, t)
+		case "collection":
+			deleted.Collections = append(deleted.Collections, t)
+		case "favourite":
+			deleted.Favourites = append(deleted.Favourites, t)
+		}
+	}
+	return deleted, rows.Err()
+}
@@ -1,7 +1,9 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 
 	clickhouse "github.com/ClickHouse/clickhouse-go/v2"
 	"github.com/astanx/anime_api/internal/db"
@@ -20,8 +22,8 @@ func NewTimecodeRepo(db *db.DB) *TimecodeRepo {
 	}
 }
 
-func (r *TimecodeRepo) GetAllTimecodes(deviceID string) ([]model.Timecode, error) {
-	rows, err := r.dbPostgres.Query(
+func (r *TimecodeRepo) GetAllTimecodes(ctx context.Context, deviceID string) ([]model.Timecode, error) {
+	rows, err := r.dbPostgres.QueryContext(ctx,
 		"SELECT time, episode_id, is_watched, anime_id FROM timecodes WHERE device_id = $1",
 		deviceID,
 	)
@@ -46,8 +48,8 @@ func (r *TimecodeRepo) GetAllTimecodes(deviceID string) ([]model.Timecode, error
 	return timecodes, nil
 }
 
-func (r *TimecodeRepo) GetTimecode(deviceID string, episodeID string) (*model.Timecode, error) {
-	row := r.dbPostgres.QueryRow(
+func (r *TimecodeRepo) GetTimecode(ctx context.Context, deviceID string, episodeID string) (*model.Timecode, error) {
+	row := r.dbPostgres.QueryRowContext(ctx,
 		"SELECT time, episode_id, is_watched, anime_id FROM timecodes WHERE device_id = $1 AND episode_id = $2 LIMIT 1",
 		deviceID, episodeID,
 	)
@@ -64,35 +66,48 @@ func (r *TimecodeRepo) GetTimecode(deviceID string, episodeID string) (*model.Ti
 	return &t, nil
 }
 
-func (r *TimecodeRepo) AddTimecode(deviceID string, timecode model.Timecode) error {
-	var exists bool
-	err := r.dbPostgres.QueryRow(
-		`SELECT EXISTS(SELECT 1 FROM timecodes WHERE device_id=$1 AND episode_id=$2)`,
-		deviceID, timecode.EpisodeID,
-	).Scan(&exists)
+func (r *TimecodeRepo) AddTimecode(ctx context.Context, deviceID string, timecode model.Timecode) error {
+	_, err := r.dbPostgres.ExecContext(ctx,
+		`INSERT INTO timecodes (time, episode_id, is_watched, device_id, anime_id)
+		 VALUES ($1, $2, $3, $4, $5)
+		 ON CONFLICT (device_id, episode_id) DO UPDATE
+		 SET time = EXCLUDED.time, is_watched = EXCLUDED.is_watched, updated_at = now()`,
+		timecode.Time, timecode.EpisodeID, timecode.IsWatched, deviceID, timecode.AnimeID,
+	)
+	return err
+}
+
+// ApplyTimecodes writes timecodes recorded offline in one transaction. A
+// timecode only replaces the stored one if it was recorded at or after the
+// stored updated_at; the result reports which ones were written.
+func (r *TimecodeRepo) ApplyTimecodes(ctx context.Context, deviceID string, timecodes []model.SyncTimecode) ([]bool, error) {
+	tx, err := r.dbPostgres.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	if exists {
-		_, err = r.dbPostgres.Exec(
-			`UPDATE timecodes
-             SET time=$1, is_watched=$2
-             WHERE device_id=$3 AND episode_id=$4`,
-			timecode.Time, timecode.IsWatched, deviceID, timecode.EpisodeID,
-		)
-	} else {
-		_, err = r.dbPostgres.Exec(
-			`INSERT INTO timecodes (time, episode_id, is_watched, device_id, anime_id)
-             VALUES ($1, $2, $3, $4, $5)`,
-			timecode.Time, timecode.EpisodeID, timecode.IsWatched, deviceID, timecode.AnimeID,
-		)
+	defer tx.Rollback()
+
+	applied := make([]bool, len(timecodes))
+	for i, t := range timecodes {
+		err := tx.QueryRowContext(ctx,
+			`INSERT INTO timecodes (time, episode_id, is_watched, device_id, anime_id, updated_at)
+			 VALUES ($1, $2, $3, $4, $5, $6)
+			 ON CONFLICT (device_id, episode_id) DO UPDATE
+			 SET time = EXCLUDED.time, is_watched = EXCLUDED.is_watched, updated_at = EXCLUDED.updated_at
+			 WHERE timecodes.updated_at <= EXCLUDED.updated_at
+			 RETURNING true`,
+			t.Time, t.EpisodeID, t.IsWatched, deviceID, t.AnimeID, t.UpdatedAt,
+		).Scan(&applied[i])
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
 	}
-	return err
+
+	return applied, tx.Commit()
 }
 
-func (r *TimecodeRepo) GetTimecodesForAnime(deviceID string, animeID string) ([]model.Timecode, error) {
-	rows, err := r.dbPostgres.Query(
+func (r *TimecodeRepo) GetTimecodesForAnime(ctx context.Context, deviceID string, animeID string) ([]model.Timecode, error) {
+	rows, err := r.dbPostgres.QueryContext(ctx,
 		"SELECT time, episode_id, is_watched, anime_id FROM timecodes WHERE device_id = $1 AND anime_id = $2",
 		deviceID, animeID,
 	)
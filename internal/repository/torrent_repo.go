@@ -3,20 +3,19 @@ package repository
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
 	"fmt"
+	"net/url"
 	"path"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
-	"time"
 
-	clickhouse "github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/astanx/anime_api/internal/analytics"
+	"github.com/astanx/anime_api/internal/cache"
 	"github.com/astanx/anime_api/internal/config"
 	"github.com/astanx/anime_api/internal/db"
 	"github.com/astanx/anime_api/internal/model"
-	"github.com/redis/go-redis/v9"
 )
 
 var bracketRegex = regexp.MustCompile(`\[[^\]]*\]`)
@@ -147,19 +146,21 @@ func episodeMatch(title string, episode int) (bool, bool) {
 
 type TorrentRepo struct {
 	dbPostgres     *sql.DB
-	dbClickhouse   clickhouse.Conn
-	dbRedis        *redis.Client
+	analytics      analytics.Sink
+	cache          *cache.Cache
+	endpoints      config.UpstreamConfig
 	collectionRepo CollectionRepo
 	historyRepo    HistoryRepo
 	timecodeRepo   TimecodeRepo
 	animeRepo      AnimeRepo
 }
 
-func NewTorrentRepo(db *db.DB, collectionRepo CollectionRepo, historyRepo HistoryRepo, timecodeRepo TimecodeRepo, animeRepo AnimeRepo) *TorrentRepo {
+func NewTorrentRepo(db *db.DB, cache *cache.Cache, endpoints config.UpstreamConfig, collectionRepo CollectionRepo, historyRepo HistoryRepo, timecodeRepo TimecodeRepo, animeRepo AnimeRepo) *TorrentRepo {
 	return &TorrentRepo{
 		dbPostgres:     db.Postgres,
-		dbClickhouse:   db.ClickHouse,
-		dbRedis:        db.Redis,
+		analytics:      db.Analytics,
+		cache:          cache,
+		endpoints:      endpoints,
 		collectionRepo: collectionRepo,
 		historyRepo:    historyRepo,
 		timecodeRepo:   timecodeRepo,
@@ -167,11 +168,11 @@ func NewTorrentRepo(db *db.DB, collectionRepo CollectionRepo, historyRepo Histor
 	}
 }
 
-func (r *TorrentRepo) SearchMALAnime(query string, page int) (model.PaginatedSearchAnime, error) {
-	url := fmt.Sprintf("https://api.jikan.moe/v4/anime?q=%s&limit=20&page=%d", query, page)
+func (r *TorrentRepo) SearchMALAnime(ctx context.Context, query string, page int) (model.PaginatedSearchAnime, error) {
+	searchURL := fmt.Sprintf("%s/anime?q=%s&limit=20&page=%d", r.endpoints.JikanURL, url.QueryEscape(query), page)
 
 	var res model.PaginatedMALSearchAnime
-	if err := doJSONRequest(url, &res); err != nil {
+	if err := doJSONRequest(ctx, searchURL, &res); err != nil {
 		return model.PaginatedSearchAnime{}, err
 	}
 
@@ -179,6 +180,7 @@ func (r *TorrentRepo) SearchMALAnime(query string, page int) (model.PaginatedSea
 	for _, item := range res.Data {
 		data = append(data, model.SearchAnime{
 			ID:         fmt.Sprint(item.ID),
+			MalID:      item.ID,
 			Title:      item.Title,
 			Poster:     item.Images.Webp.ImageURL,
 			Year:       item.Year,
@@ -196,173 +198,134 @@ func (r *TorrentRepo) SearchMALAnime(query string, page int) (model.PaginatedSea
 	}, nil
 }
 
-func (r *TorrentRepo) SearchMALRecommendedAnime(limit int, page int) ([]model.SearchAnime, error) {
-	ctx := context.Background()
+func (r *TorrentRepo) SearchMALRecommendedAnime(ctx context.Context, limit int, page int) ([]model.SearchAnime, error) {
 	cacheKey := fmt.Sprintf("anime:search:mal:recommended:limit:%d:page:%d", limit, page)
 
-	cached, err := r.dbRedis.Get(ctx, cacheKey).Result()
-	if err == nil {
-		var anime []model.SearchAnime
-		if err := json.Unmarshal([]byte(cached), &anime); err == nil {
-			return anime, nil
+	return cache.Fetch(ctx, r.cache, cacheKey, r.cache.TTL().Search, func(ctx context.Context) ([]model.SearchAnime, error) {
+		url := fmt.Sprintf("%s/top/anime?limit=%d&page=%d", r.endpoints.JikanURL, limit, page)
+		var res model.PaginatedMALSearchAnime
+		if err := doJSONRequest(ctx, url, &res); err != nil {
+			return []model.SearchAnime{}, err
 		}
-	}
-
-	url := fmt.Sprintf("https://api.jikan.moe/v4/top/anime?limit=%d&page=%d", limit, page)
-	fmt.Print(url)
-	var res model.PaginatedMALSearchAnime
-	if err := doJSONRequest(url, &res); err != nil {
-		return []model.SearchAnime{}, err
-	}
-
-	var data []model.SearchAnime
-	for _, item := range res.Data {
-		data = append(data, model.SearchAnime{
-			ID:         fmt.Sprint(item.ID),
-			Title:      item.Title,
-			Poster:     item.Images.Webp.ImageURL,
-			Year:       item.Year,
-			Type:       item.Type,
-			ParserType: "MAL",
-		})
-	}
 
-	logSearchClickhouse(r.dbClickhouse, "recommended", "mal", len(data))
+		var data []model.SearchAnime
+		for _, item := range res.Data {
+			data = append(data, model.SearchAnime{
+				ID:         fmt.Sprint(item.ID),
+				MalID:      item.ID,
+				Title:      item.Title,
+				Poster:     item.Images.Webp.ImageURL,
+				Year:       item.Year,
+				Type:       item.Type,
+				ParserType: "MAL",
+			})
+		}
 
-	animeJSON, _ := json.Marshal(data)
-	r.dbRedis.Set(ctx, cacheKey, animeJSON, 12*time.Hour)
+		logSearch(ctx, r.analytics, "recommended", "mal", len(data))
 
-	return data, nil
+		return data, nil
+	})
 }
 
-func (r *TorrentRepo) SearchMALLatestReleases(limit int, page int) ([]model.SearchAnime, error) {
-	ctx := context.Background()
+func (r *TorrentRepo) SearchMALLatestReleases(ctx context.Context, limit int, page int) ([]model.SearchAnime, error) {
 	cacheKey := fmt.Sprintf("anime:search:mal:latest:limit:%d:page:%d", limit, page)
 
-	cached, err := r.dbRedis.Get(ctx, cacheKey).Result()
-	if err == nil {
-		var anime []model.SearchAnime
-		if err := json.Unmarshal([]byte(cached), &anime); err == nil {
-			return anime, nil
-		}
-	}
-
-	url := fmt.Sprintf("https://api.jikan.moe/v4/watch/episodes?limit=%d&page=%d", limit, page)
-
-	var res model.PaginatedMALLatestAnime
-	if err := doJSONRequest(url, &res); err != nil {
-		return []model.SearchAnime{}, err
-	}
+	return cache.Fetch(ctx, r.cache, cacheKey, r.cache.TTL().Search, func(ctx context.Context) ([]model.SearchAnime, error) {
+		url := fmt.Sprintf("%s/watch/episodes?limit=%d&page=%d", r.endpoints.JikanURL, limit, page)
 
-	var data []model.SearchAnime
-	for _, item := range res.Data {
-		data = append(data, model.SearchAnime{
-			ID:         fmt.Sprint(item.Entry.ID),
-			Title:      item.Entry.Title,
-			Poster:     item.Entry.Images.Webp.ImageURL,
-			Year:       0,
-			Type:       "",
-			ParserType: "MAL",
-		})
-	}
+		var res model.PaginatedMALLatestAnime
+		if err := doJSONRequest(ctx, url, &res); err != nil {
+			return []model.SearchAnime{}, err
+		}
 
-	logSearchClickhouse(r.dbClickhouse, "latest", "mal", len(data))
+		var data []model.SearchAnime
+		for _, item := range res.Data {
+			data = append(data, model.SearchAnime{
+				ID:         fmt.Sprint(item.Entry.ID),
+				MalID:      item.Entry.ID,
+				Title:      item.Entry.Title,
+				Poster:     item.Entry.Images.Webp.ImageURL,
+				Year:       0,
+				Type:       "",
+				ParserType: "MAL",
+			})
+		}
 
-	animeJSON, _ := json.Marshal(data)
-	r.dbRedis.Set(ctx, cacheKey, animeJSON, 12*time.Hour)
+		logSearch(ctx, r.analytics, "latest", "mal", len(data))
 
-	return data, nil
+		return data, nil
+	})
 }
 
-func (r *TorrentRepo) SearchMALById(id string) (model.Anime, error) {
-	ctx := context.Background()
+func (r *TorrentRepo) SearchMALById(ctx context.Context, id string) (model.Anime, error) {
 	cacheKey := fmt.Sprintf("anime:search:mal_id:%s", id)
 
-	cached, err := r.dbRedis.Get(ctx, cacheKey).Result()
-	if err == nil {
-		var anime model.Anime
-		if err := json.Unmarshal([]byte(cached), &anime); err == nil {
-			return anime, nil
-		}
-	}
-
-	url := fmt.Sprintf("https://api.jikan.moe/v4/anime/%s/full", id)
-
-	var res model.MALAnime
-	if err := doJSONRequest(url, &res); err != nil {
-		return model.Anime{}, err
-	}
-
-	var genres []string
-	for _, g := range res.Genres {
-		genres = append(genres, g.Name)
-	}
-
-	result := model.Anime{
-		ID:            fmt.Sprint(res.ID),
-		MalID:         res.ID,
-		Title:         res.Title,
-		Poster:        res.Images.Webp.ImageURL,
-		Description:   res.Description,
-		Genres:        genres,
-		Status:        res.Status,
-		Year:          res.Year,
-		Type:          res.Type,
-		TotalEpisodes: res.TotalEpisodes,
-		Episodes:      make([]model.PreviewEpisode, 0),
-	}
-
-	hasNext := true
-	page := 1
+	return cache.Fetch(ctx, r.cache, cacheKey, r.cache.TTL().Anime, func(ctx context.Context) (model.Anime, error) {
+		url := fmt.Sprintf("%s/anime/%s/full", r.endpoints.JikanURL, id)
 
-	for {
-		if !hasNext {
-			break
+		var res model.MALAnime
+		if err := doJSONRequest(ctx, url, &res); err != nil {
+			return model.Anime{}, err
 		}
-		url := fmt.Sprintf("https://api.jikan.moe/v4/anime/%s/episodes?page=%d", id, page)
 
-		var episodesRes model.PaginatedMALPreviewEpisodes
-		if err := doJSONRequest(url, &episodesRes); err != nil {
-			return model.Anime{}, err
+		var genres []string
+		for _, g := range res.Genres {
+			genres = append(genres, g.Name)
 		}
 
-		for _, e := range episodesRes.Data {
-			last := path.Base(e.Url)
-			ordinal, _ := strconv.Atoi(last)
-			result.Episodes = append(result.Episodes, model.PreviewEpisode{
-				ID:       fmt.Sprint(e.ID),
-				Title:    e.Title,
-				Ordinal:  ordinal,
-				IsSubbed: true,
-			})
+		result := model.Anime{
+			ID:            fmt.Sprint(res.ID),
+			MalID:         res.ID,
+			Title:         res.Title,
+			Poster:        res.Images.Webp.ImageURL,
+			Description:   res.Description,
+			Genres:        genres,
+			Status:        res.Status,
+			Year:          res.Year,
+			Type:          res.Type,
+			TotalEpisodes: res.TotalEpisodes,
+			Episodes:      make([]model.PreviewEpisode, 0),
 		}
 
-		hasNext = episodesRes.Pagination.HasNextPage
-		page++
-	}
+		hasNext := true
+		page := 1
 
-	animeJSON, _ := json.Marshal(result)
-	r.dbRedis.Set(ctx, cacheKey, animeJSON, 12*time.Hour)
+		for {
+			if !hasNext {
+				break
+			}
+			url := fmt.Sprintf("%s/anime/%s/episodes?page=%d", r.endpoints.JikanURL, id, page)
 
-	return result, nil
-}
+			var episodesRes model.PaginatedMALPreviewEpisodes
+			if err := doJSONRequest(ctx, url, &episodesRes); err != nil {
+				return model.Anime{}, err
+			}
 
-func (r *TorrentRepo) SearchMALByEpisodeId(id, episodeId string) (model.Episode, error) {
-	ctx := context.Background()
-	cacheKey := fmt.Sprintf("anime:mal:episode:mal_id:%s:episode_id:%s", id, episodeId)
+			for _, e := range episodesRes.Data {
+				last := path.Base(e.Url)
+				ordinal, _ := strconv.Atoi(last)
+				result.Episodes = append(result.Episodes, model.PreviewEpisode{
+					ID:       fmt.Sprint(e.ID),
+					Title:    e.Title,
+					Ordinal:  ordinal,
+					IsSubbed: true,
+				})
+			}
 
-	cached, err := r.dbRedis.Get(ctx, cacheKey).Result()
-	if err == nil {
-		var episode model.Episode
-		if err := json.Unmarshal([]byte(cached), &episode); err == nil {
-			return episode, nil
+			hasNext = episodesRes.Pagination.HasNextPage
+			page++
 		}
-	}
 
-	url := fmt.Sprintf("https://api.jikan.moe/v4/anime/%s/episodes/%s", id, episodeId)
+		return result, nil
+	})
+}
+
+func (r *TorrentRepo) SearchMALByEpisodeId(ctx context.Context, id, episodeId string) (model.Episode, error) {
+
+	episodeURL := fmt.Sprintf("%s/anime/%s/episodes/%s", r.endpoints.JikanURL, id, episodeId)
 
 	var res model.MalPreviewEpisode
-	if err := doJSONRequest(url, &res); err != nil {
+	if err := doJSONRequest(ctx, episodeURL, &res); err != nil {
 		return model.Episode{}, err
 	}
 
@@ -398,10 +361,10 @@ func (r *TorrentRepo) SearchMALByEpisodeId(id, episodeId string) (model.Episode,
 	var prowres []model.ProwlarrAnime
 	for _, q := range queries {
 		searchURL := fmt.Sprintf("%s/search?apikey=%s&query=%s&categories=%s&type=search",
-			config.PROWLARR_URL, config.PROWLARR_APIKEY, q, config.CATEGORY)
+			r.endpoints.ProwlarrURL, r.endpoints.ProwlarrAPIKey, url.QueryEscape(q), r.endpoints.ProwlarrCategories)
 
 		var temp []model.ProwlarrAnime
-		if err := doJSONRequest(searchURL, &temp); err == nil {
+		if err := doJSONRequest(ctx, searchURL, &temp); err == nil {
 			prowres = append(prowres, temp...)
 		}
 	}
@@ -466,7 +429,7 @@ func (r *TorrentRepo) SearchMALByEpisodeId(id, episodeId string) (model.Episode,
 		})
 
 		// // Add torrent
-		// addURL := fmt.Sprintf("%s/torrents", config.TORR_URL)
+		// addURL := fmt.Sprintf("%s/torrents", r.endpoints.TorrServerURL)
 		// payload := fmt.Sprintf(`{"action":"add","link":"%s","save_to_db":true}`, p.MagnetURL)
 		// http.Post(addURL, "application/json", strings.NewReader(payload))
 
@@ -474,7 +437,7 @@ func (r *TorrentRepo) SearchMALByEpisodeId(id, episodeId string) (model.Episode,
 		// // Many single-episode torrents have index=1 (or only one file).
 		// // For batches, we still often use index=1, but at least the torrent is now more likely correct.
 		// // Future improvement: parse file list from torrent client API if available.
-		// videoURL := fmt.Sprintf("%s/stream?link=%s&index=1&play", config.TORR_URL, p.Hash)
+		// videoURL := fmt.Sprintf("%s/stream?link=%s&index=1&play", r.endpoints.TorrServerURL, p.Hash)
 
 		// result.Sources = append(result.Sources, model.Source{
 		// 	Url:  videoURL,
@@ -484,8 +447,60 @@ func (r *TorrentRepo) SearchMALByEpisodeId(id, episodeId string) (model.Episode,
 		// break // take the best one for now
 	}
 
-	// animeJSON, _ := json.Marshal(result)
-	// r.dbRedis.Set(ctx, cacheKey, animeJSON, 1*time.Hour)
-
 	return result, nil
 }
+
+func (r *TorrentRepo) GetMALGenres(ctx context.Context) ([]model.Genre, error) {
+	cacheKey := "anime:mal:genres"
+
+	return cache.Fetch(ctx, r.cache, cacheKey, r.cache.TTL().Genres, func(ctx context.Context) ([]model.Genre, error) {
+		var res model.MALGenres
+		if err := doJSONRequest(ctx, r.endpoints.JikanURL+"/genres/anime", &res); err != nil {
+			return nil, err
+		}
+
+		genres := make([]model.Genre, 0, len(res.Data))
+		for _, g := range res.Data {
+			genres = append(genres, model.Genre{
+				ID:            g.ID,
+				Name:          g.Name,
+				TotalReleases: g.Count,
+			})
+		}
+
+		return genres, nil
+	})
+}
+
+func (r *TorrentRepo) SearchMALGenreReleases(ctx context.Context, genreID, limit, page int) (model.PaginatedSearchAnime, error) {
+	url := fmt.Sprintf("%s/anime?genres=%d&limit=%d&page=%d", r.endpoints.JikanURL, genreID, limit, page)
+
+	var res model.PaginatedMALSearchAnime
+	if err := doJSONRequest(ctx, url, &res); err != nil {
+		return model.PaginatedSearchAnime{}, err
+	}
+
+	data := make([]model.SearchAnime, 0, len(res.Data))
+	for _, item := range res.Data {
+		data = append(data, model.SearchAnime{
+			ID:         fmt.Sprint(item.ID),
+			MalID:      item.ID,
+			Title:      item.Title,
+			Poster:     item.Images.Webp.ImageURL,
+			Year:       item.Year,
+			Type:       item.Type,
+			ParserType: "MAL",
+		})
+	}
+
+	logSearch(ctx, r.analytics, fmt.Sprintf("genre-%d", genreID), "mal", len(data))
+
+	return model.PaginatedSearchAnime{
+		Data: data,
+		Meta: model.ShortPaginationMeta{
+			CurrentPage: res.Pagination.CurrentPage,
+			HasNextPage: res.Pagination.HasNextPage,
+			TotalPages:  res.Pagination.LastVisiblePage,
+		},
+	}, nil
+}
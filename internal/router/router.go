@@ -1,22 +1,45 @@
 package router
 
 import (
+	"github.com/astanx/anime_api/internal/cache"
+	"github.com/astanx/anime_api/internal/config"
 	"github.com/astanx/anime_api/internal/db"
 	"github.com/astanx/anime_api/internal/handler"
 	"github.com/astanx/anime_api/internal/middleware"
+	"github.com/astanx/anime_api/internal/ratelimit"
 	"github.com/astanx/anime_api/internal/repository"
 	"github.com/astanx/anime_api/internal/service"
+	"github.com/astanx/anime_api/internal/upstream"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-func NewRouter(databases *db.DB) *gin.Engine {
+func NewRouter(databases *db.DB, cfg *config.Config, responseCache *cache.Cache) *gin.Engine {
 	r := gin.New()
 
-	r.Use(gin.Recovery())
+	r.Use(middleware.Tracing())
+	r.Use(middleware.RequestID())
 	r.Use(middleware.Logging())
+	r.Use(middleware.Metrics())
+	r.Use(middleware.Recovery())
+
+	healthHandler := handler.NewHealthHandler(databases, responseCache)
+	r.GET("/healthz", healthHandler.Healthz)
+	r.GET("/readyz", healthHandler.Readyz)
+	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
+	// Rate limits were validated when the config was loaded.
+	rates := func(spec string) []ratelimit.Rate {
+		parsed, _ := ratelimit.ParseRates(spec)
+		return parsed
+	}
+	limiter := ratelimit.New(databases.Redis)
+	authLimit := middleware.RateLimit(limiter, "auth", rates(cfg.RateLimits.Auth))
+	animeLimit := middleware.RateLimit(limiter, "anime", rates(cfg.RateLimits.Anime))
 
 	v1 := r.Group("/api/v1")
+	v1.Use(middleware.Timeout(cfg.RequestTimeouts.Default))
 	{
 		v1.GET("/", func(c *gin.Context) {
 			c.JSON(200, gin.H{
@@ -24,20 +47,55 @@ func NewRouter(databases *db.DB) *gin.Engine {
 			})
 		})
 		deviceRepo := repository.NewDeviceRepo(databases)
-		deviceService := service.NewDeviceService(deviceRepo)
+		deviceService := service.NewDeviceService(deviceRepo, responseCache, cfg)
 		deviceHandler := handler.NewDeviceHandler(deviceService)
 
+		upstreamHandler := handler.NewUpstreamHandler(upstream.Default())
+		v1.GET("/upstream/status", upstreamHandler.Status)
+
+		accountRepo := repository.NewAccountRepo(databases)
+		accountService := service.NewAccountService(accountRepo, deviceService, cfg.AuthSecret, cfg.AuthTokenTTL)
+		accountHandler := handler.NewAccountHandler(accountService)
+
 		users := v1.Group("/users")
+		users.Use(authLimit)
 		{
 			users.GET("/device", deviceHandler.AddDeviceID)
+			users.POST("/register", accountHandler.Register)
+			users.POST("/login", accountHandler.Login)
 		}
 
 		authV1 := v1.Group("/")
-		authV1.Use(middleware.DeviceMiddleware())
+		authV1.Use(middleware.DeviceMiddleware(deviceService, accountService))
+		authV1.Use(middleware.RateLimit(limiter, "default", rates(cfg.RateLimits.Default)))
 		{
-			// Timecode routes
+			authV1.GET("/users/me", accountHandler.Me)
+			authV1.POST("/users/device/merge", deviceHandler.MergeDevice)
+			authV1.POST("/users/device/refresh", deviceHandler.RefreshToken)
+			authV1.POST("/users/device/revoke", deviceHandler.RevokeDevice)
+
+			devices := authV1.Group("/users/devices")
+			{
+				devices.GET("", deviceHandler.ListDevices)
+				devices.DELETE("/:id", deviceHandler.UnlinkDevice)
+				devices.POST("/pair", deviceHandler.CreatePairingCode)
+				devices.POST("/pair/redeem", authLimit, deviceHandler.RedeemPairingCode)
+			}
+
+			// Saving a timecode also updates history and collections
 			timecodeRepo := repository.NewTimecodeRepo(databases)
-			timecodeService := service.NewTimecodeService(timecodeRepo)
+			historyRepo := repository.NewHistoryRepo(databases)
+			collectionRepo := repository.NewCollectionRepo(databases)
+			animeRepo := repository.NewAnimeRepo(databases, responseCache, cfg.Upstream)
+
+			progressRepo := repository.NewProgressRepo(databases)
+			progressService := service.NewProgressService(progressRepo, timecodeRepo, historyRepo, collectionRepo, animeRepo)
+			progressHandler := handler.NewProgressHandler(progressService)
+
+			authV1.GET("/continue-watching", animeLimit, middleware.Timeout(cfg.RequestTimeouts.Upstream), progressHandler.ContinueWatching)
+
+			// Timecode routes
+			timecodeService := service.NewTimecodeService(timecodeRepo, progressService)
 			timecodeHandler := handler.NewTimecodeHandler(timecodeService)
 
 			timecodes := authV1.Group("/timecode")
@@ -46,16 +104,17 @@ func NewRouter(databases *db.DB) *gin.Engine {
 				timecodes.GET("/anime", timecodeHandler.GetTimecodesForAnime)
 				timecodes.GET("/all", timecodeHandler.GetAllTimecodes)
 				timecodes.POST("", timecodeHandler.AddOrUpdateTimecode)
+				timecodes.POST("/batch", timecodeHandler.AddTimecodeBatch)
 			}
 
 			// History routes
-			historyRepo := repository.NewHistoryRepo(databases)
 			historyService := service.NewHistoryService(historyRepo)
 			historyHandler := handler.NewHistoryHandler(historyService)
 
 			history := authV1.Group("/history")
 			{
 				history.POST("", historyHandler.AddHistory)
+				history.POST("/batch", historyHandler.AddHistoryBatch)
 				history.GET("", historyHandler.GetHistory)
 				history.GET("/all", historyHandler.GetAllHistory)
 			}
@@ -75,7 +134,6 @@ func NewRouter(databases *db.DB) *gin.Engine {
 			}
 
 			// Collection routes
-			collectionRepo := repository.NewCollectionRepo(databases)
 			collectionService := service.NewCollectionService(collectionRepo)
 			collectionHandler := handler.NewCollectionHandler(collectionService)
 
@@ -88,60 +146,76 @@ func NewRouter(databases *db.DB) *gin.Engine {
 				collection.GET("/anime", collectionHandler.GetCollectionForAnime)
 			}
 
+			// Sync routes
+			syncRepo := repository.NewSyncRepo(databases)
+			syncService := service.NewSyncService(syncRepo)
+			syncHandler := handler.NewSyncHandler(syncService)
+
+			authV1.GET("/sync", syncHandler.Sync)
+
 			// Anime routes
-			animeRepo := repository.NewAnimeRepo(databases)
 			animeService := service.NewAnimeService(animeRepo)
 			animeHandler := handler.NewAnimeHandler(animeService)
 
+			torrentRepo := repository.NewTorrentRepo(databases, responseCache, cfg.Upstream, *collectionRepo, *historyRepo, *timecodeRepo, *animeRepo)
+			mappingRepo := repository.NewMappingRepo(databases)
+
+			providers := repository.NewProviderRegistry(
+				repository.NewConsumetProvider(animeRepo),
+				repository.NewAnilibriaProvider(animeRepo),
+				repository.NewMALProvider(torrentRepo),
+			)
+			providerService := service.NewProviderService(providers, mappingRepo)
+			providerHandler := handler.NewProviderHandler(providerService)
+
+			mappingService := service.NewMappingService(mappingRepo)
+			mappingHandler := handler.NewMappingHandler(mappingService)
+
 			anime := authV1.Group("/anime")
+			anime.Use(animeLimit, middleware.Timeout(cfg.RequestTimeouts.Upstream))
 			{
-				// Consumet routes
-				consumet := anime.Group("/consumet")
-				{
-					consumet.GET("/", animeHandler.SearchConsumetAnime)
-					consumet.GET("/genres", animeHandler.GetConsumetGenres)
-					consumet.GET("/latest", animeHandler.SearchConsumetLatestReleases)
-					consumet.GET("/recommended", animeHandler.SearchConsumetRecommendedAnime)
-					consumet.GET("/genre/releases", animeHandler.SearchConsumetGenreReleases)
-					consumet.GET("/:id", animeHandler.GetAnimeInfoByConsumetID)
-					consumet.GET("/episode/:id", animeHandler.GetConsumetEpisodeInfo)
-				}
-
-				// Anilibria routes
-				anilibria := anime.Group("/anilibria")
-				{
-					anilibria.GET("/", animeHandler.SearchAnilibriaAnime)
-					anilibria.GET("/genres", animeHandler.GetAnilibriaGenres)
-					anilibria.GET("/latest", animeHandler.SearchAnilibriaLatestReleases)
-					anilibria.GET("/random", animeHandler.SearchAnilibriaRandomReleases)
-					anilibria.GET("/recommended", animeHandler.SearchAnilibriaRecommendedAnime)
-					anilibria.GET("/genre/releases", animeHandler.SearchAnilibriaGenreReleases)
-					anilibria.GET("/:id", animeHandler.GetAnimeInfoByAnilibriaID)
-					anilibria.GET("/episode/:id", animeHandler.GetAnilibriaEpisodeInfo)
+				// Provider routes, mounted once per registered provider
+				for _, name := range providers.Names() {
+					provider := anime.Group("/" + name)
+					provider.Use(middleware.Provider(name))
+					{
+						provider.GET("/", providerHandler.Search)
+						provider.GET("/genres", providerHandler.Genres)
+						provider.GET("/latest", providerHandler.Latest)
+						provider.GET("/recommended", providerHandler.Recommended)
+						provider.GET("/genre/releases", providerHandler.GenreReleases)
+						provider.GET("/:id", providerHandler.AnimeInfo)
+						provider.GET("/episode/:id", providerHandler.EpisodeInfo)
+					}
 				}
 
+				anime.GET("/search", providerHandler.SearchAll)
+				anime.GET("/mapping/:id", mappingHandler.GetMapping)
+				anime.GET("/anilibria/random", animeHandler.SearchAnilibriaRandomReleases)
 				anime.GET("/search/:id", animeHandler.SearchAnimeByID)
 				anime.GET("/:id", animeHandler.GetAnimeInfoByID)
+				anime.GET("/:id/next", progressHandler.NextEpisode)
 				anime.GET("/episode/:id", animeHandler.GetEpisodeInfoByID)
 			}
 
 			// MAL routes
-			malRepo := repository.NewMALRepo(databases, *collectionRepo, *historyRepo, *timecodeRepo, *animeRepo)
+			malRepo := repository.NewMALRepo(databases, *collectionRepo, *historyRepo, *timecodeRepo, *animeRepo, *mappingRepo)
 			malService := service.NewMALService(malRepo)
 			malHandler := handler.NewMALHandler(malService)
 
 			mal := authV1.Group("/mal")
+			mal.Use(middleware.Timeout(cfg.RequestTimeouts.Import))
 			{
 				mal.GET("/export", malHandler.ExportMALList)
 				mal.POST("/import", malHandler.ImportMALList)
 			}
 
 			// Torrent routes
-			torrentRepo := repository.NewTorrentRepo(databases, *collectionRepo, *historyRepo, *timecodeRepo, *animeRepo)
 			torrentService := service.NewTorrentService(torrentRepo)
 			torrentHandler := handler.NewTorrentHandler(torrentService)
 
 			torrent := authV1.Group("/torrent")
+			torrent.Use(animeLimit, middleware.Timeout(cfg.RequestTimeouts.Upstream))
 			{
 				torrent.GET("/mal/search", torrentHandler.SearchMALAnime)
 				torrent.GET("/mal/recommended", torrentHandler.SearchMALRecommendedAnime)
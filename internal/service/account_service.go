@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/astanx/anime_api/internal/model"
+	"github.com/astanx/anime_api/internal/repository"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	minPasswordLength = 8
+	// maxPasswordLength is the most bcrypt will hash.
+	maxPasswordLength = 72
+)
+
+var (
+	ErrInvalidEmail       = errors.New("invalid email")
+	ErrWeakPassword       = fmt.Errorf("password must be at least %d characters and at most %d bytes", minPasswordLength, maxPasswordLength)
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	ErrInvalidToken       = errors.New("invalid token")
+)
+
+type AccountService struct {
+	repo     *repository.AccountRepo
+	devices  *DeviceService
+	secret   []byte
+	tokenTTL time.Duration
+}
+
+func NewAccountService(repo *repository.AccountRepo, devices *DeviceService, secret string, tokenTTL time.Duration) *AccountService {
+	return &AccountService{repo: repo, devices: devices, secret: []byte(secret), tokenTTL: tokenTTL}
+}
+
+// Register creates an account and, if deviceToken is set, moves that device
+// and its data to it.
+func (s *AccountService) Register(ctx context.Context, creds model.Credentials, deviceToken string) (model.AuthToken, error) {
+	email, err := normalizeEmail(creds.Email)
+	if err != nil {
+		return model.AuthToken{}, err
+	}
+	if len(creds.Password) < minPasswordLength || len(creds.Password) > maxPasswordLength {
+		return model.AuthToken{}, ErrWeakPassword
+	}
+	deviceID, err := s.deviceID(ctx, deviceToken)
+	if err != nil {
+		return model.AuthToken{}, err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(creds.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return model.AuthToken{}, err
+	}
+
+	account, linked, err := s.repo.CreateAccount(ctx, uuid.NewString(), email, string(hash), deviceID)
+	if err != nil {
+		return model.AuthToken{}, err
+	}
+	s.devices.Forget(ctx, linked...)
+	return s.issueToken(account)
+}
+
+// Login checks the credentials and, if deviceToken is set, links that device
+// to the account. Data the device collected before stays with the device.
+func (s *AccountService) Login(ctx context.Context, creds model.Credentials, deviceToken string) (model.AuthToken, error) {
+	email, err := normalizeEmail(creds.Email)
+	if err != nil {
+		return model.AuthToken{}, ErrInvalidCredentials
+	}
+
+	account, hash, err := s.repo.GetAccountByEmail(ctx, email)
+	if errors.Is(err, repository.ErrAccountNotFound) {
+		return model.AuthToken{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return model.AuthToken{}, err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(creds.Password)); err != nil {
+		return model.AuthToken{}, ErrInvalidCredentials
+	}
+
+	deviceID, err := s.deviceID(ctx, deviceToken)
+	if err != nil {
+		return model.AuthToken{}, err
+	}
+	if deviceID != "" {
+		if err := s.repo.LinkDevice(ctx, deviceID, account.ID); err != nil {
+			return model.AuthToken{}, err
+		}
+		s.devices.Forget(ctx, deviceID)
+	}
+	return s.issueToken(account)
+}
+
+func (s *AccountService) GetAccount(ctx context.Context, id string) (model.Account, error) {
+	return s.repo.GetAccount(ctx, id)
+}
+
+// ResolveToken validates an account token and returns its account ID.
+func (s *AccountService) ResolveToken(token string) (string, error) {
+	accountID, _, err := parseToken(s.secret, token, accountAudience)
+	return accountID, err
+}
+
+func (s *AccountService) issueToken(account model.Account) (model.AuthToken, error) {
+	token, expiresAt, err := signToken(s.secret, account.ID, accountAudience, 0, s.tokenTTL)
+	if err != nil {
+		return model.AuthToken{}, err
+	}
+	return model.AuthToken{Token: token, ExpiresAt: expiresAt, Account: account}, nil
+}
+
+// deviceID verifies an optional device token sent along with credentials.
+func (s *AccountService) deviceID(ctx context.Context, deviceToken string) (string, error) {
+	if deviceToken == "" {
+		return "", nil
+	}
+	owner, err := s.devices.ResolveDeviceToken(ctx, deviceToken)
+	if err != nil {
+		return "", err
+	}
+	return owner.DeviceID, nil
+}
+
+func normalizeEmail(email string) (string, error) {
+	addr, err := mail.ParseAddress(strings.TrimSpace(email))
+	if err != nil || addr.Name != "" {
+		return "", ErrInvalidEmail
+	}
+	return strings.ToLower(addr.Address), nil
+}
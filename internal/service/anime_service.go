@@ -1,6 +1,8 @@
 package service
 
 import (
+	"context"
+
 	"github.com/astanx/anime_api/internal/model"
 	"github.com/astanx/anime_api/internal/repository"
 )
@@ -14,69 +16,32 @@ func NewAnimeService(repo *repository.AnimeRepo) *AnimeService {
 }
 
 // Search
-func (s *AnimeService) SearchConsumetAnime(query string, page int) (model.PaginatedSearchAnime, error) {
-	return s.repo.SearchConsumetAnime(query, page)
-}
-
-func (s *AnimeService) SearchAnilibriaAnime(query string, page int) (model.PaginatedSearchAnime, error) {
-	return s.repo.SearchAnilibriaAnime(query, page)
-}
-
-func (s *AnimeService) SearchConsumetRecommendedAnime() ([]model.SearchAnime, error) {
-	return s.repo.SearchConsumetRecommendedAnime()
-}
-
-func (s *AnimeService) SearchAnilibriaRecommendedAnime(limit int, page int) ([]model.SearchAnime, error) {
-	return s.repo.SearchAnilibriaRecommendedAnime(limit, page)
-}
-
-func (s *AnimeService) SearchConsumetLatestReleases() ([]model.SearchAnime, error) {
-	return s.repo.SearchConsumetLatestReleases()
-}
-
-func (s *AnimeService) SearchAnilibriaLatestReleases(limit int) ([]model.SearchAnime, error) {
-	return s.repo.SearchAnilibriaLatestReleases(limit)
+func (s *AnimeService) SearchAnilibriaRandomReleases(ctx context.Context, limit int, page int) (model.PaginatedSearchAnime, error) {
+	return s.repo.SearchAnilibriaRandomReleases(ctx, limit, page)
 }
 
-func (s *AnimeService) SearchAnilibriaRandomReleases(limit int, page int) (model.PaginatedSearchAnime, error) {
-	return s.repo.SearchAnilibriaRandomReleases(limit, page)
-}
-
-func (s *AnimeService) SearchConsumetGenreReleases(genre string) ([]model.SearchAnime, error) {
-	return s.repo.SearchConsumetGenreReleases(genre)
-}
-
-func (s *AnimeService) SearchAnilibriaGenreReleases(genreID, limit int, page int) (model.PaginatedSearchAnime, error) {
-	return s.repo.SearchAnilibriaGenreReleases(genreID, limit, page)
-}
-
-// Get genres
-func (s *AnimeService) GetConsumetGenres() ([]string, error) {
-	return s.repo.GetConsumetGenres()
-}
-
-func (s *AnimeService) GetAnilibriaGenres() ([]model.Genre, error) {
-	return s.repo.GetAnilibriaGenres()
+// Get anime info
+func (s *AnimeService) SearchAnimeByID(ctx context.Context, id string) (model.SearchAnime, error) {
+	return s.repo.SearchAnimeByID(ctx, id)
 }
 
-// Get anime info
-func (s *AnimeService) SearchAnimeByID(id string) (model.SearchAnime, error) {
-	return s.repo.SearchAnimeByID(id)
+func (s *AnimeService) GetAnimeInfoByID(ctx context.Context, id string) (model.Anime, error) {
+	return s.repo.GetAnimeInfoByID(ctx, id)
 }
 
-func (s *AnimeService) GetAnimeInfoByConsumetID(id string) (model.Anime, error) {
-	return s.repo.GetAnimeInfoByConsumetID(id)
+func (s *AnimeService) GetAnimeInfoByConsumetID(ctx context.Context, id string) (model.Anime, error) {
+	return s.repo.GetAnimeInfoByConsumetID(ctx, id)
 }
 
-func (s *AnimeService) GetAnimeInfoByAnilibriaID(id string) (model.Anime, error) {
-	return s.repo.GetAnimeInfoByAnilibriaID(id)
+func (s *AnimeService) GetAnimeInfoByAnilibriaID(ctx context.Context, id string) (model.Anime, error) {
+	return s.repo.GetAnimeInfoByAnilibriaID(ctx, id)
 }
 
 // Get episode info
-func (s *AnimeService) GetAnilibriaEpisodeInfo(id string) (model.Episode, error) {
-	return s.repo.GetAnilibriaEpisodeInfo(id)
+func (s *AnimeService) GetAnilibriaEpisodeInfo(ctx context.Context, id string) (model.Episode, error) {
+	return s.repo.GetAnilibriaEpisodeInfo(ctx, id)
 }
 
-func (s *AnimeService) GetConsumetEpisodeInfo(id string, title string, ordinal int, dub string) (model.Episode, error) {
-	return s.repo.GetConsumetEpisodeInfo(id, title, ordinal, dub)
+func (s *AnimeService) GetConsumetEpisodeInfo(ctx context.Context, id string, title string, ordinal int, dub string) (model.Episode, error) {
+	return s.repo.GetConsumetEpisodeInfo(ctx, id, title, ordinal, dub)
 }
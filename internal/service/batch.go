@@ -0,0 +1,58 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/astanx/anime_api/internal/model"
+)
+
+var errMissingUpdatedAt = errors.New("updated_at is required")
+
+// applyBatch runs validate on every item, passes the valid ones to apply and
+// maps its answers back to the position of each item in the batch.
+func applyBatch[T any](items []T, validate func(*T) error, apply func([]T) ([]bool, error)) ([]model.BatchResult, error) {
+	results := make([]model.BatchResult, len(items))
+	valid := make([]T, 0, len(items))
+	positions := make([]int, 0, len(items))
+	for i := range items {
+		results[i].Index = i
+		if err := validate(&items[i]); err != nil {
+			results[i].Status = model.BatchInvalid
+			results[i].Error = err.Error()
+			continue
+		}
+		valid = append(valid, items[i])
+		positions = append(positions, i)
+	}
+
+	if len(valid) == 0 {
+		return results, nil
+	}
+	applied, err := apply(valid)
+	if err != nil {
+		return nil, err
+	}
+	for j, ok := range applied {
+		results[positions[j]].Status = model.BatchStale
+		if ok {
+			results[positions[j]].Status = model.BatchApplied
+		}
+	}
+
+	return results, nil
+}
+
+// clientTime checks a timestamp sent by a client. Timestamps from the future
+// are pulled back to now so a device with a fast clock can't shadow later
+// writes; Postgres stores microseconds, so the rest is dropped.
+func clientTime(t *time.Time) error {
+	if t.IsZero() {
+		return errMissingUpdatedAt
+	}
+	if now := time.Now(); t.After(now) {
+		*t = now
+	}
+	*t = t.Truncate(time.Microsecond)
+	return nil
+}
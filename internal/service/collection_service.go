@@ -1,6 +1,8 @@
 package service
 
 import (
+	"context"
+
 	"github.com/astanx/anime_api/internal/model"
 	"github.com/astanx/anime_api/internal/repository"
 )
@@ -13,22 +15,22 @@ func NewCollectionService(repo *repository.CollectionRepo) *CollectionService {
 	return &CollectionService{repo: repo}
 }
 
-func (s *CollectionService) AddCollection(deviceID string, collection model.Collection) error {
-	return s.repo.AddCollection(deviceID, collection)
+func (s *CollectionService) AddCollection(ctx context.Context, deviceID string, collection model.Collection) error {
+	return s.repo.AddCollection(ctx, deviceID, collection)
 }
 
-func (s *CollectionService) RemoveCollection(deviceID, animeID, collectionType string) error {
-	return s.repo.RemoveCollection(deviceID, animeID, collectionType)
+func (s *CollectionService) RemoveCollection(ctx context.Context, deviceID, animeID, collectionType string) error {
+	return s.repo.RemoveCollection(ctx, deviceID, animeID, collectionType)
 }
 
-func (s *CollectionService) GetAllCollections(deviceID string) ([]model.Collection, error) {
-	return s.repo.GetAllCollections(deviceID)
+func (s *CollectionService) GetAllCollections(ctx context.Context, deviceID string) ([]model.Collection, error) {
+	return s.repo.GetAllCollections(ctx, deviceID)
 }
 
-func (s *CollectionService) GetCollections(deviceID, T string, page, limit int) (model.PaginatedCollections, error) {
-	return s.repo.GetCollections(deviceID, T, page, limit)
+func (s *CollectionService) GetCollections(ctx context.Context, deviceID, T string, page, limit int) (model.PaginatedCollections, error) {
+	return s.repo.GetCollections(ctx, deviceID, T, page, limit)
 }
 
-func (s *CollectionService) GetCollectionForAnime(deviceID, animeID string) (model.Collection, error) {
-	return s.repo.GetCollectionForAnime(deviceID, animeID)
+func (s *CollectionService) GetCollectionForAnime(ctx context.Context, deviceID, animeID string) (model.Collection, error) {
+	return s.repo.GetCollectionForAnime(ctx, deviceID, animeID)
 }
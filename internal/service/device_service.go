@@ -1,19 +1,237 @@
 package service
 
 import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/astanx/anime_api/internal/cache"
+	"github.com/astanx/anime_api/internal/config"
 	"github.com/astanx/anime_api/internal/model"
 	"github.com/astanx/anime_api/internal/repository"
 	"github.com/google/uuid"
 )
 
+// pairingAlphabet leaves out characters that are easy to confuse on a TV
+// screen (0/O, 1/I).
+const (
+	pairingAlphabet   = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	pairingCodeLength = 6
+	pairingAttempts   = 5
+)
+
+// deviceOwnerTTL bounds how long a device lookup is cached. Changes made on
+// this instance invalidate it right away; other instances without Redis may
+// see them this much later.
+const deviceOwnerTTL = time.Minute
+
+var ErrDeviceRevoked = errors.New("device has been revoked")
+
 type DeviceService struct {
-	repo *repository.DeviceRepo
+	repo           *repository.DeviceRepo
+	cache          *cache.Cache
+	secret         []byte
+	tokenTTL       time.Duration
+	pairingTTL     time.Duration
+	allowLegacyIDs bool
+}
+
+func NewDeviceService(repo *repository.DeviceRepo, ownerCache *cache.Cache, cfg *config.Config) *DeviceService {
+	return &DeviceService{
+		repo:           repo,
+		cache:          ownerCache,
+		secret:         []byte(cfg.AuthSecret),
+		tokenTTL:       cfg.DeviceTokenTTL,
+		pairingTTL:     cfg.PairingCodeTTL,
+		allowLegacyIDs: cfg.AllowLegacyDeviceIDs,
+	}
+}
+
+// AddDeviceID registers a new device and returns its first token.
+func (s *DeviceService) AddDeviceID(ctx context.Context, deviceID uuid.UUID, from string) (model.DeviceToken, error) {
+	if _, err := s.repo.AddDeviceID(ctx, deviceID, from); err != nil {
+		return model.DeviceToken{}, err
+	}
+	return s.issueToken(deviceID.String(), 0)
+}
+
+// RefreshToken signs a new token for deviceID and invalidates the tokens
+// issued to it before.
+func (s *DeviceService) RefreshToken(ctx context.Context, deviceID string) (model.DeviceToken, error) {
+	version, err := s.repo.NextTokenVersion(ctx, deviceID)
+	if err != nil {
+		return model.DeviceToken{}, err
+	}
+	s.Forget(ctx, deviceID)
+	return s.issueToken(deviceID, version)
+}
+
+// issueToken signs a token for deviceID that is valid until it expires, the
+// device is revoked or the token is refreshed past version.
+func (s *DeviceService) issueToken(deviceID string, version int) (model.DeviceToken, error) {
+	token, expiresAt, err := signToken(s.secret, deviceID, deviceAudience, version, s.tokenTTL)
+	if err != nil {
+		return model.DeviceToken{}, err
+	}
+	return model.DeviceToken{ID: deviceID, Token: token, ExpiresAt: expiresAt}, nil
+}
+
+// ResolveDeviceToken verifies a device token and returns whose library the
+// device uses. Tokens older than the last refresh are rejected. Bare device
+// IDs are accepted only for known devices and only while legacy IDs are
+// allowed.
+func (s *DeviceService) ResolveDeviceToken(ctx context.Context, token string) (model.DeviceOwner, error) {
+	legacy := false
+	deviceID, version, err := parseToken(s.secret, token, deviceAudience)
+	if err != nil {
+		if !s.allowLegacyIDs || uuid.Validate(token) != nil {
+			return model.DeviceOwner{}, err
+		}
+		deviceID, legacy = token, true
+	}
+
+	lookup := func() (model.DeviceOwner, error) {
+		return cache.Remember(ctx, s.cache, deviceCacheKey(deviceID), deviceOwnerTTL, func(ctx context.Context) (model.DeviceOwner, error) {
+			return s.repo.Owner(ctx, deviceID)
+		})
+	}
+	owner, err := lookup()
+	if err == nil && !legacy && version > owner.TokenVersion {
+		// The token was refreshed after the lookup was cached, possibly on
+		// another instance.
+		s.Forget(ctx, deviceID)
+		owner, err = lookup()
+	}
+	if err != nil {
+		return model.DeviceOwner{}, err
+	}
+	if owner.Revoked {
+		return model.DeviceOwner{}, ErrDeviceRevoked
+	}
+	if !owner.Known || (!legacy && version != owner.TokenVersion) {
+		return model.DeviceOwner{}, ErrInvalidToken
+	}
+	return owner, nil
+}
+
+// Forget drops the cached lookup of a device whose owner changed. The change
+// is already committed, so the client going away must not skip this.
+func (s *DeviceService) Forget(ctx context.Context, deviceIDs ...string) {
+	ctx = context.WithoutCancel(ctx)
+	for _, id := range deviceIDs {
+		s.cache.Invalidate(ctx, deviceCacheKey(id))
+	}
+}
+
+// RevokeDevice permanently rejects the tokens of deviceID, which must share
+// the library stored under ownerKey.
+func (s *DeviceService) RevokeDevice(ctx context.Context, ownerKey, deviceID string) error {
+	if err := s.repo.RevokeDevice(ctx, ownerKey, deviceID); err != nil {
+		return err
+	}
+	s.Forget(ctx, deviceID)
+	return nil
+}
+
+// CreatePairingCode issues a single-use code another device can redeem to
+// share deviceID's library.
+func (s *DeviceService) CreatePairingCode(ctx context.Context, deviceID string) (model.PairingCode, error) {
+	expiresAt := time.Now().Add(s.pairingTTL)
+	for range pairingAttempts {
+		code, err := randomCode()
+		if err != nil {
+			return model.PairingCode{}, err
+		}
+		err = s.repo.CreatePairingCode(ctx, deviceID, code, expiresAt)
+		if errors.Is(err, repository.ErrPairingCodeTaken) {
+			continue
+		}
+		if err != nil {
+			return model.PairingCode{}, err
+		}
+		return model.PairingCode{Code: code, ExpiresAt: expiresAt}, nil
+	}
+	return model.PairingCode{}, repository.ErrPairingCodeTaken
+}
+
+func (s *DeviceService) RedeemPairingCode(ctx context.Context, code, deviceID string) (model.DeviceOwner, error) {
+	owner, issuerID, err := s.repo.RedeemPairingCode(ctx, normalizeCode(code), deviceID)
+	if err != nil {
+		return model.DeviceOwner{}, err
+	}
+	s.Forget(ctx, deviceID, issuerID)
+	return owner, nil
+}
+
+func (s *DeviceService) ListDevices(ctx context.Context, ownerKey, currentDeviceID string) ([]model.Device, error) {
+	return s.repo.ListDevices(ctx, ownerKey, currentDeviceID)
+}
+
+func (s *DeviceService) UnlinkDevice(ctx context.Context, ownerKey, deviceID string) error {
+	if err := s.repo.UnlinkDevice(ctx, ownerKey, deviceID); err != nil {
+		return err
+	}
+	s.Forget(ctx, deviceID)
+	return nil
+}
+
+// MergeDevice moves the library of the device holding sourceToken into the
+// one stored under targetKey, which belongs to the device targetID or, when
+// that is empty, to the account targetKey, and moves the device there too.
+// Libraries owned by an account can't be merged away, since a device token
+// alone must not expose account data, and neither can libraries other
+// devices still use.
+func (s *DeviceService) MergeDevice(ctx context.Context, sourceToken, targetKey, targetID string) (model.MergeReport, error) {
+	source, err := s.ResolveDeviceToken(ctx, sourceToken)
+	if err != nil {
+		return model.MergeReport{}, err
+	}
+	if source.AccountID != "" {
+		return model.MergeReport{}, repository.ErrMergeAccount
+	}
+	if source.Key() == targetKey {
+		return model.MergeReport{}, repository.ErrMergeSameLibrary
+	}
+
+	targetAccountID := ""
+	if targetID == "" {
+		targetAccountID = targetKey
+	}
+	report, err := s.repo.MergeDevice(ctx, source.DeviceID, targetID, targetAccountID)
+	if err != nil {
+		return model.MergeReport{}, err
+	}
+	s.Forget(ctx, source.DeviceID)
+	if targetID != "" {
+		s.Forget(ctx, targetID)
+	}
+	return report, nil
+}
+
+func deviceCacheKey(deviceID string) string {
+	return "device:" + deviceID
 }
 
-func NewDeviceService(repo *repository.DeviceRepo) *DeviceService {
-	return &DeviceService{repo: repo}
+func randomCode() (string, error) {
+	b := make([]byte, pairingCodeLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		// len(pairingAlphabet) divides 256, so this is unbiased.
+		b[i] = pairingAlphabet[int(b[i])%len(pairingAlphabet)]
+	}
+	return string(b), nil
 }
 
-func (s *DeviceService) AddDeviceID(deviceID uuid.UUID, from string) (model.User, error) {
-	return s.repo.AddDeviceID(deviceID, from)
+// normalizeCode accepts codes typed in lower case or with separators.
+func normalizeCode(code string) string {
+	return strings.ToUpper(strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return r
+	}, code))
 }
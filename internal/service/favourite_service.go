@@ -1,6 +1,8 @@
 package service
 
 import (
+	"context"
+
 	"github.com/astanx/anime_api/internal/model"
 	"github.com/astanx/anime_api/internal/repository"
 )
@@ -13,22 +15,22 @@ func NewFavouriteService(repo *repository.FavouriteRepo) *FavouriteService {
 	return &FavouriteService{repo: repo}
 }
 
-func (s *FavouriteService) AddFavourite(deviceID string, favourite model.Favourite) error {
-	return s.repo.AddFavourite(deviceID, favourite)
+func (s *FavouriteService) AddFavourite(ctx context.Context, deviceID string, favourite model.Favourite) error {
+	return s.repo.AddFavourite(ctx, deviceID, favourite)
 }
 
-func (s *FavouriteService) RemoveFavourite(deviceID string, favourite model.Favourite) error {
-	return s.repo.RemoveFavourite(deviceID, favourite)
+func (s *FavouriteService) RemoveFavourite(ctx context.Context, deviceID string, favourite model.Favourite) error {
+	return s.repo.RemoveFavourite(ctx, deviceID, favourite)
 }
 
-func (s *FavouriteService) GetAllFavourites(deviceID string) ([]model.Favourite, error) {
-	return s.repo.GetAllFavourites(deviceID)
+func (s *FavouriteService) GetAllFavourites(ctx context.Context, deviceID string) ([]model.Favourite, error) {
+	return s.repo.GetAllFavourites(ctx, deviceID)
 }
 
-func (s *FavouriteService) GetFavourites(deviceID string, page, limit int) (model.PaginatedFavourites, error) {
-	return s.repo.GetFavourites(deviceID, page, limit)
+func (s *FavouriteService) GetFavourites(ctx context.Context, deviceID string, page, limit int) (model.PaginatedFavourites, error) {
+	return s.repo.GetFavourites(ctx, deviceID, page, limit)
 }
 
-func (s *FavouriteService) GetFavouriteForAnime(deviceID, animeID string) (model.Favourite, error) {
-	return s.repo.GetFavouriteForAnime(deviceID, animeID)
+func (s *FavouriteService) GetFavouriteForAnime(ctx context.Context, deviceID, animeID string) (model.Favourite, error) {
+	return s.repo.GetFavouriteForAnime(ctx, deviceID, animeID)
 }
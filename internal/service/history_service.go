@@ -1,6 +1,9 @@
 package service
 
 import (
+	"context"
+	"errors"
+
 	"github.com/astanx/anime_api/internal/model"
 	"github.com/astanx/anime_api/internal/repository"
 )
@@ -13,14 +16,28 @@ func NewHistoryService(repo *repository.HistoryRepo) *HistoryService {
 	return &HistoryService{repo: repo}
 }
 
-func (s *HistoryService) AddHistory(deviceID string, history model.History) error {
-	return s.repo.AddHistory(deviceID, history)
+func (s *HistoryService) AddHistory(ctx context.Context, deviceID string, history model.History) error {
+	return s.repo.AddHistory(ctx, deviceID, history)
+}
+
+// ApplyHistory writes a batch of history entries recorded offline. Newer
+// stored entries win over older ones from the batch.
+func (s *HistoryService) ApplyHistory(ctx context.Context, deviceID string, history []model.SyncHistory) ([]model.BatchResult, error) {
+	validate := func(h *model.SyncHistory) error {
+		if h.AnimeID == "" {
+			return errors.New("anime_id is required")
+		}
+		return clientTime(&h.UpdatedAt)
+	}
+	return applyBatch(history, validate, func(valid []model.SyncHistory) ([]bool, error) {
+		return s.repo.ApplyHistory(ctx, deviceID, valid)
+	})
 }
 
-func (s *HistoryService) GetAllHistory(deviceID string) ([]model.History, error) {
-	return s.repo.GetAllHistory(deviceID)
+func (s *HistoryService) GetAllHistory(ctx context.Context, deviceID string) ([]model.History, error) {
+	return s.repo.GetAllHistory(ctx, deviceID)
 }
 
-func (s *HistoryService) GetHistory(deviceID string, page, limit int) (model.PaginatedHistory, error) {
-	return s.repo.GetHistory(deviceID, page, limit)
+func (s *HistoryService) GetHistory(ctx context.Context, deviceID string, page, limit int) (model.PaginatedHistory, error) {
+	return s.repo.GetHistory(ctx, deviceID, page, limit)
 }
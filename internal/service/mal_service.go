@@ -1,6 +1,8 @@
 package service
 
 import (
+	"context"
+
 	"github.com/astanx/anime_api/internal/repository"
 )
 
@@ -12,10 +14,10 @@ func NewMALService(repo *repository.MALRepo) *MALService {
 	return &MALService{repo: repo}
 }
 
-func (s *MALService) ExportMALList(deviceID string) (string, error) {
-	return s.repo.ExportMALList(deviceID)
+func (s *MALService) ExportMALList(ctx context.Context, deviceID string) (string, error) {
+	return s.repo.ExportMALList(ctx, deviceID)
 }
 
-func (s *MALService) ImportMALList(deviceID, malList string) (int, error) {
-	return s.repo.ImportMALList(deviceID, malList)
+func (s *MALService) ImportMALList(ctx context.Context, deviceID, malList string) (int, error) {
+	return s.repo.ImportMALList(ctx, deviceID, malList)
 }
@@ -0,0 +1,20 @@
+package service
+
+import (
+	"context"
+
+	"github.com/astanx/anime_api/internal/model"
+	"github.com/astanx/anime_api/internal/repository"
+)
+
+type MappingService struct {
+	repo *repository.MappingRepo
+}
+
+func NewMappingService(repo *repository.MappingRepo) *MappingService {
+	return &MappingService{repo: repo}
+}
+
+func (s *MappingService) GetMappings(ctx context.Context, provider, id string) ([]model.AnimeMapping, error) {
+	return s.repo.GetMappings(ctx, provider, id)
+}
@@ -0,0 +1,314 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/astanx/anime_api/internal/model"
+	"github.com/astanx/anime_api/internal/repository"
+)
+
+// recordLookupTimeout bounds the anime lookup when a timecode is saved, so a
+// cold provider doesn't hold up the player. If it runs out, a finale only
+// moves the title to "watching" until it is saved again.
+const recordLookupTimeout = 3 * time.Second
+
+// ErrAnimeInfo is returned when NextEpisode can't load the anime from its
+// provider.
+var ErrAnimeInfo = errors.New("failed to get anime info")
+
+// ProgressService keeps history and collections in step with the timecodes a
+// device saves, and works out what to play next.
+type ProgressService struct {
+	progress    *repository.ProgressRepo
+	timecodes   *repository.TimecodeRepo
+	history     *repository.HistoryRepo
+	collections *repository.CollectionRepo
+	anime       *repository.AnimeRepo
+}
+
+func NewProgressService(progress *repository.ProgressRepo, timecodes *repository.TimecodeRepo, history *repository.HistoryRepo, collections *repository.CollectionRepo, anime *repository.AnimeRepo) *ProgressService {
+	return &ProgressService{
+		progress:    progress,
+		timecodes:   timecodes,
+		history:     history,
+		collections: collections,
+		anime:       anime,
+	}
+}
+
+// Record updates history and the collection after deviceID saved t. Nothing
+// changes until the episode counts as watched: marked so, or played into
+// its ending. History then moves forward to the episode, and the title goes
+// to "watched" if it was the last episode of a finished show and to
+// "watching" otherwise.
+func (s *ProgressService) Record(ctx context.Context, deviceID string, t model.Timecode) error {
+	return s.RecordBatch(ctx, deviceID, []model.Timecode{t})
+}
+
+// RecordBatch is Record for timecodes saved together. Each title is looked
+// up once and only its furthest watched episode is recorded. A title that
+// fails does not stop the others.
+func (s *ProgressService) RecordBatch(ctx context.Context, deviceID string, timecodes []model.Timecode) error {
+	var animeIDs []string
+	byAnime := make(map[string][]model.Timecode)
+	for _, t := range timecodes {
+		if _, ok := byAnime[t.AnimeID]; !ok {
+			animeIDs = append(animeIDs, t.AnimeID)
+		}
+		byAnime[t.AnimeID] = append(byAnime[t.AnimeID], t)
+	}
+
+	var errs []error
+	for _, animeID := range animeIDs {
+		if err := s.recordAnime(ctx, deviceID, animeID, byAnime[animeID]); err != nil {
+			errs = append(errs, fmt.Errorf("anime %s: %w", animeID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// recordAnime records the furthest of timecodes, which all belong to
+// animeID, that counts as watched.
+func (s *ProgressService) recordAnime(ctx context.Context, deviceID, animeID string, timecodes []model.Timecode) error {
+	type watchedEpisode struct {
+		id      string
+		ordinal int
+		stored  bool
+	}
+	var watched []watchedEpisode
+	for _, t := range timecodes {
+		episode, stored, err := s.progress.StoredEpisode(ctx, t.EpisodeID)
+		if err != nil {
+			return err
+		}
+		inEnding := stored && episode.Ending.Start > 0 && t.Time >= episode.Ending.Start
+		if t.IsWatched || inEnding {
+			watched = append(watched, watchedEpisode{id: t.EpisodeID, ordinal: episode.Ordinal, stored: stored})
+		}
+	}
+	if len(watched) == 0 {
+		return nil
+	}
+
+	lookupCtx, cancel := context.WithTimeout(ctx, recordLookupTimeout)
+	anime, err := s.anime.GetAnimeInfoByID(lookupCtx, animeID)
+	cancel()
+	if err != nil {
+		slog.WarnContext(ctx, "ProgressService: failed to get anime info", "anime_id", animeID, "error", err)
+	}
+	ordinal := 0
+	for _, e := range watched {
+		if !e.stored {
+			e.ordinal = episodeOrdinal(anime.Episodes, e.id)
+		}
+		ordinal = max(ordinal, e.ordinal)
+	}
+	finished := ordinal > 0 && isFinale(anime, ordinal)
+
+	if ordinal > 0 {
+		err := s.history.AddHistory(ctx, deviceID, model.History{
+			AnimeID:            animeID,
+			LastWatchedEpisode: ordinal,
+			IsWatched:          finished,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	collectionType := "watching"
+	if finished {
+		collectionType = "watched"
+	}
+	_, err = s.collections.PromoteCollection(ctx, deviceID, animeID, collectionType)
+	return err
+}
+
+// ContinueWatching returns up to limit titles deviceID is part way through,
+// each with the episode to play next and the position to resume it at.
+// Titles whose next episode has not come out yet are left out.
+func (s *ProgressService) ContinueWatching(ctx context.Context, deviceID string, limit int) ([]model.ContinueWatching, error) {
+	titles, err := s.progress.InProgress(ctx, deviceID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	keep := make([]bool, len(titles))
+	var wg sync.WaitGroup
+	for i := range titles {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			keep[i] = s.fillNext(ctx, deviceID, &titles[i])
+		}(i)
+	}
+	wg.Wait()
+
+	result := make([]model.ContinueWatching, 0, len(titles))
+	for i, t := range titles {
+		if keep[i] {
+			result = append(result, t)
+		}
+	}
+	return result, nil
+}
+
+// fillNext completes t with anime info and, unless t already resumes a
+// partly played episode, the episode after t.LastWatched. It reports
+// whether t has anything to play.
+func (s *ProgressService) fillNext(ctx context.Context, deviceID string, t *model.ContinueWatching) bool {
+	anime, err := s.anime.GetAnimeInfoByID(ctx, t.AnimeID)
+	if err != nil {
+		slog.WarnContext(ctx, "ProgressService: failed to get anime info", "anime_id", t.AnimeID, "error", err)
+		if t.EpisodeID == "" {
+			t.Ordinal = t.LastWatched + 1
+		}
+		return true
+	}
+	t.Title = anime.Title
+	t.Poster = anime.Poster
+
+	if t.EpisodeID != "" {
+		t.Ordinal = episodeOrdinal(anime.Episodes, t.EpisodeID)
+		return true
+	}
+
+	next, ok := episodeByOrdinal(anime.Episodes, t.LastWatched+1)
+	if !ok {
+		return false
+	}
+	t.EpisodeID = next.ID
+	t.Ordinal = next.Ordinal
+
+	timecode, err := s.timecodes.GetTimecode(ctx, deviceID, next.ID)
+	if err != nil {
+		slog.WarnContext(ctx, "ProgressService: failed to get timecode", "episode_id", next.ID, "error", err)
+	} else if timecode != nil && !timecode.IsWatched {
+		t.Time = timecode.Time
+	}
+	return true
+}
+
+// NextEpisode works out what deviceID should play next for animeID from the
+// provider's episode list, the device's timecodes and its history. audio is
+// "dub" or "sub" to only play episodes available that way, or "" for either.
+// Providers with a single audio track satisfy either.
+func (s *ProgressService) NextEpisode(ctx context.Context, deviceID, animeID, audio string) (model.NextEpisode, error) {
+	anime, err := s.anime.GetAnimeInfoByID(ctx, animeID)
+	if err != nil {
+		return model.NextEpisode{}, fmt.Errorf("%w: %w", ErrAnimeInfo, err)
+	}
+	timecodes, err := s.timecodes.GetTimecodesForAnime(ctx, deviceID, animeID)
+	if err != nil {
+		return model.NextEpisode{}, err
+	}
+	history, err := s.history.GetHistoryForAnime(ctx, deviceID, animeID)
+	if err != nil {
+		return model.NextEpisode{}, err
+	}
+
+	if s.anime.SingleAudio(animeID) {
+		audio = ""
+	}
+	return nextEpisode(animeID, anime, timecodes, history, audio), nil
+}
+
+func nextEpisode(animeID string, anime model.Anime, timecodes []model.Timecode, history *model.History, audio string) model.NextEpisode {
+	episodes := make([]model.PreviewEpisode, len(anime.Episodes))
+	copy(episodes, anime.Episodes)
+	sort.SliceStable(episodes, func(i, j int) bool {
+		return episodes[i].Ordinal < episodes[j].Ordinal
+	})
+
+	// History may lag behind timecodes saved before progress was tracked, so
+	// the furthest watched timecode counts too.
+	lastWatched := 0
+	if history != nil {
+		lastWatched = history.LastWatchedEpisode
+	}
+	byEpisode := make(map[string]model.Timecode, len(timecodes))
+	for _, t := range timecodes {
+		byEpisode[t.EpisodeID] = t
+		if t.IsWatched {
+			lastWatched = max(lastWatched, episodeOrdinal(episodes, t.EpisodeID))
+		}
+	}
+
+	next := model.NextEpisode{
+		AnimeID:       animeID,
+		LastWatched:   lastWatched,
+		TotalEpisodes: anime.TotalEpisodes,
+	}
+	for _, e := range episodes {
+		if e.Ordinal <= lastWatched || byEpisode[e.ID].IsWatched {
+			continue
+		}
+		// Dubs lag behind, so an episode without the requested audio means
+		// waiting rather than skipping ahead.
+		if !hasAudio(e, audio) {
+			next.Status = model.NextWaiting
+			return next
+		}
+		next.Status = model.NextReady
+		next.Episode = &e
+		next.Time = byEpisode[e.ID].Time
+		return next
+	}
+
+	next.Status = model.NextWaiting
+	if !airing(anime) && lastWatched >= anime.TotalEpisodes {
+		next.Status = model.NextFinished
+	}
+	return next
+}
+
+func hasAudio(e model.PreviewEpisode, audio string) bool {
+	switch audio {
+	case "dub":
+		return e.IsDubbed
+	case "sub":
+		return e.IsSubbed
+	}
+	return true
+}
+
+func episodeOrdinal(episodes []model.PreviewEpisode, episodeID string) int {
+	for _, e := range episodes {
+		if e.ID == episodeID {
+			return e.Ordinal
+		}
+	}
+	return 0
+}
+
+func episodeByOrdinal(episodes []model.PreviewEpisode, ordinal int) (model.PreviewEpisode, bool) {
+	for _, e := range episodes {
+		if e.Ordinal == ordinal {
+			return e, true
+		}
+	}
+	return model.PreviewEpisode{}, false
+}
+
+// airing reports whether the provider still expects new episodes of anime.
+// Anilibria reports "Ongoing", Consumet "Currently Airing".
+func airing(anime model.Anime) bool {
+	switch strings.ToLower(anime.Status) {
+	case "ongoing", "currently airing", "not yet aired":
+		return true
+	}
+	return false
+}
+
+// isFinale reports whether ordinal is the last episode of a show that has
+// finished airing.
+func isFinale(anime model.Anime, ordinal int) bool {
+	return anime.TotalEpisodes > 0 && ordinal >= anime.TotalEpisodes && !airing(anime)
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/astanx/anime_api/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextEpisode(t *testing.T) {
+	episodes := func(n int, dubbed int) []model.PreviewEpisode {
+		list := make([]model.PreviewEpisode, n)
+		for i := range list {
+			list[i] = model.PreviewEpisode{
+				ID:       string(rune('a' + i)),
+				Ordinal:  i + 1,
+				IsSubbed: true,
+				IsDubbed: i < dubbed,
+			}
+		}
+		return list
+	}
+	finished := model.Anime{Status: "Completed", TotalEpisodes: 3, Episodes: episodes(3, 3)}
+	airing := model.Anime{Status: "Currently Airing", TotalEpisodes: 12, Episodes: episodes(3, 1)}
+	anilibria := model.Anime{Status: "Ongoing", TotalEpisodes: 12, Episodes: []model.PreviewEpisode{
+		{ID: "1", Ordinal: 1, IsDubbed: true},
+		{ID: "2", Ordinal: 2, IsDubbed: true},
+	}}
+
+	tests := []struct {
+		name        string
+		anime       model.Anime
+		timecodes   []model.Timecode
+		history     *model.History
+		audio       string
+		status      string
+		episodeID   string
+		time        int
+		lastWatched int
+	}{
+		{
+			name:      "first episode when nothing was watched",
+			anime:     finished,
+			status:    model.NextReady,
+			episodeID: "a",
+		},
+		{
+			name:        "episode after history",
+			anime:       finished,
+			history:     &model.History{LastWatchedEpisode: 1},
+			status:      model.NextReady,
+			episodeID:   "b",
+			lastWatched: 1,
+		},
+		{
+			name:        "resumes a partly played episode",
+			anime:       finished,
+			history:     &model.History{LastWatchedEpisode: 1},
+			timecodes:   []model.Timecode{{EpisodeID: "b", Time: 300}},
+			status:      model.NextReady,
+			episodeID:   "b",
+			time:        300,
+			lastWatched: 1,
+		},
+		{
+			name:        "watched timecodes ahead of history",
+			anime:       finished,
+			history:     &model.History{LastWatchedEpisode: 1},
+			timecodes:   []model.Timecode{{EpisodeID: "b", IsWatched: true}},
+			status:      model.NextReady,
+			episodeID:   "c",
+			lastWatched: 2,
+		},
+		{
+			name: "episodes out of order",
+			anime: model.Anime{Status: "Completed", TotalEpisodes: 2, Episodes: []model.PreviewEpisode{
+				{ID: "b", Ordinal: 2},
+				{ID: "a", Ordinal: 1},
+			}},
+			status:    model.NextReady,
+			episodeID: "a",
+		},
+		{
+			name:        "next episode not released yet",
+			anime:       airing,
+			history:     &model.History{LastWatchedEpisode: 3},
+			status:      model.NextWaiting,
+			lastWatched: 3,
+		},
+		{
+			name:        "next episode not dubbed yet",
+			anime:       airing,
+			history:     &model.History{LastWatchedEpisode: 1},
+			audio:       "dub",
+			status:      model.NextWaiting,
+			lastWatched: 1,
+		},
+		{
+			name:        "next episode subbed",
+			anime:       airing,
+			history:     &model.History{LastWatchedEpisode: 1},
+			audio:       "sub",
+			status:      model.NextReady,
+			episodeID:   "b",
+			lastWatched: 1,
+		},
+		{
+			name:        "single audio track with the preference dropped",
+			anime:       anilibria,
+			history:     &model.History{LastWatchedEpisode: 1},
+			status:      model.NextReady,
+			episodeID:   "2",
+			lastWatched: 1,
+		},
+		{
+			name:        "finished show watched to the end",
+			anime:       finished,
+			history:     &model.History{LastWatchedEpisode: 3, IsWatched: true},
+			status:      model.NextFinished,
+			lastWatched: 3,
+		},
+		{
+			name:        "finished show missing episodes",
+			anime:       model.Anime{Status: "Completed", TotalEpisodes: 5, Episodes: episodes(3, 3)},
+			history:     &model.History{LastWatchedEpisode: 3},
+			status:      model.NextWaiting,
+			lastWatched: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := nextEpisode("anime", tt.anime, tt.timecodes, tt.history, tt.audio)
+
+			assert.Equal(t, "anime", next.AnimeID)
+			assert.Equal(t, tt.status, next.Status)
+			assert.Equal(t, tt.lastWatched, next.LastWatched)
+			assert.Equal(t, tt.time, next.Time)
+			if tt.episodeID == "" {
+				assert.Nil(t, next.Episode)
+				return
+			}
+			if assert.NotNil(t, next.Episode) {
+				assert.Equal(t, tt.episodeID, next.Episode.ID)
+			}
+		})
+	}
+}
+
+func TestHasAudio(t *testing.T) {
+	subOnly := model.PreviewEpisode{IsSubbed: true}
+	dubOnly := model.PreviewEpisode{IsDubbed: true}
+
+	tests := []struct {
+		episode model.PreviewEpisode
+		audio   string
+		want    bool
+	}{
+		{subOnly, "", true},
+		{subOnly, "sub", true},
+		{subOnly, "dub", false},
+		{dubOnly, "dub", true},
+		{dubOnly, "sub", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, hasAudio(tt.episode, tt.audio), "%+v %q", tt.episode, tt.audio)
+	}
+}
@@ -0,0 +1,247 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/astanx/anime_api/internal/model"
+	"github.com/astanx/anime_api/internal/repository"
+)
+
+type ProviderService struct {
+	providers *repository.ProviderRegistry
+	mappings  *repository.MappingRepo
+}
+
+func NewProviderService(providers *repository.ProviderRegistry, mappings *repository.MappingRepo) *ProviderService {
+	return &ProviderService{providers: providers, mappings: mappings}
+}
+
+func (s *ProviderService) Names() []string {
+	return s.providers.Names()
+}
+
+func (s *ProviderService) Search(ctx context.Context, provider, query string, page int) (model.PaginatedSearchAnime, error) {
+	p, err := s.providers.Get(provider)
+	if err != nil {
+		return model.PaginatedSearchAnime{}, err
+	}
+	return p.Search(ctx, query, page)
+}
+
+func (s *ProviderService) Latest(ctx context.Context, provider string, limit, page int) ([]model.SearchAnime, error) {
+	p, err := s.providers.Get(provider)
+	if err != nil {
+		return nil, err
+	}
+	return p.Latest(ctx, limit, page)
+}
+
+func (s *ProviderService) Recommended(ctx context.Context, provider string, limit, page int) ([]model.SearchAnime, error) {
+	p, err := s.providers.Get(provider)
+	if err != nil {
+		return nil, err
+	}
+	return p.Recommended(ctx, limit, page)
+}
+
+func (s *ProviderService) Genres(ctx context.Context, provider string) ([]model.Genre, error) {
+	p, err := s.providers.Get(provider)
+	if err != nil {
+		return nil, err
+	}
+	return p.Genres(ctx)
+}
+
+func (s *ProviderService) GenreReleases(ctx context.Context, provider, genre string, limit, page int) (model.PaginatedSearchAnime, error) {
+	p, err := s.providers.Get(provider)
+	if err != nil {
+		return model.PaginatedSearchAnime{}, err
+	}
+	return p.GenreReleases(ctx, genre, limit, page)
+}
+
+func (s *ProviderService) AnimeInfo(ctx context.Context, provider, id string) (model.Anime, error) {
+	p, err := s.providers.Get(provider)
+	if err != nil {
+		return model.Anime{}, err
+	}
+	return p.AnimeInfo(ctx, id)
+}
+
+func (s *ProviderService) EpisodeInfo(ctx context.Context, provider, id string, params repository.EpisodeParams) (model.Episode, error) {
+	p, err := s.providers.Get(provider)
+	if err != nil {
+		return model.Episode{}, err
+	}
+	return p.EpisodeInfo(ctx, id, params)
+}
+
+// SearchAll queries every registered provider concurrently and merges the
+// results. Providers that fail are reported in FailedProviders; an error is
+// only returned when no provider answered.
+func (s *ProviderService) SearchAll(ctx context.Context, query string, page int) (model.MergedSearchResult, error) {
+	providers := s.providers.All()
+	results := make([]model.PaginatedSearchAnime, len(providers))
+	errs := make([]error, len(providers))
+
+	var wg sync.WaitGroup
+	for i, p := range providers {
+		wg.Add(1)
+		go func(i int, p repository.Provider) {
+			defer wg.Done()
+			results[i], errs[i] = p.Search(ctx, query, page)
+		}(i, p)
+	}
+	wg.Wait()
+
+	merged := newSearchMerger()
+	failed := make([]string, 0)
+	for i, p := range providers {
+		if errs[i] != nil {
+			slog.WarnContext(ctx, "SearchAll: provider failed", "provider", p.Name(), "error", errs[i])
+			failed = append(failed, p.Name())
+			continue
+		}
+		s.fillMalIDs(ctx, p.Name(), results[i].Data)
+		for _, anime := range results[i].Data {
+			merged.add(p.Name(), anime)
+		}
+	}
+
+	if len(providers) > 0 && len(failed) == len(providers) {
+		return model.MergedSearchResult{}, errors.New("all providers failed")
+	}
+
+	return model.MergedSearchResult{
+		Data:            merged.data,
+		FailedProviders: failed,
+	}, nil
+}
+
+// fillMalIDs sets MalID on results whose provider does not report one but
+// which are known to the mapping table.
+func (s *ProviderService) fillMalIDs(ctx context.Context, provider string, anime []model.SearchAnime) {
+	ids := make([]string, 0, len(anime))
+	for _, a := range anime {
+		if a.MalID == 0 {
+			ids = append(ids, a.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+
+	malIDs, err := s.mappings.MalIDs(ctx, provider, ids)
+	if err != nil {
+		slog.ErrorContext(ctx, "SearchAll: failed to load mappings", "provider", provider, "error", err)
+		return
+	}
+	for i := range anime {
+		if anime[i].MalID == 0 {
+			anime[i].MalID = malIDs[anime[i].ID]
+		}
+	}
+}
+
+// searchMerger groups search results that represent the same title. Entries
+// are matched by MAL ID when both sides know it, otherwise by normalized
+// title with year and type treated as wildcards when a provider omits them.
+type searchMerger struct {
+	data    []model.MergedSearchAnime
+	byMalID map[int]int
+	byTitle map[string][]int
+}
+
+func newSearchMerger() *searchMerger {
+	return &searchMerger{
+		data:    make([]model.MergedSearchAnime, 0),
+		byMalID: make(map[int]int),
+		byTitle: make(map[string][]int),
+	}
+}
+
+func (m *searchMerger) add(provider string, anime model.SearchAnime) {
+	ref := model.ProviderAnime{Provider: provider, ID: anime.ID}
+	title := normalizeTitle(anime.Title)
+
+	idx, ok := m.find(anime, title)
+	if !ok {
+		m.data = append(m.data, model.MergedSearchAnime{
+			MalID:     anime.MalID,
+			Title:     anime.Title,
+			Poster:    anime.Poster,
+			Year:      anime.Year,
+			Type:      anime.Type,
+			Providers: []model.ProviderAnime{ref},
+		})
+		idx = len(m.data) - 1
+		if title != "" {
+			m.byTitle[title] = append(m.byTitle[title], idx)
+		}
+	} else {
+		group := &m.data[idx]
+		group.Providers = append(group.Providers, ref)
+		if group.MalID == 0 {
+			group.MalID = anime.MalID
+		}
+		if group.Year == 0 {
+			group.Year = anime.Year
+		}
+		if group.Type == "" {
+			group.Type = anime.Type
+		}
+		if group.Poster == "" {
+			group.Poster = anime.Poster
+		}
+	}
+
+	if anime.MalID != 0 {
+		if _, exists := m.byMalID[anime.MalID]; !exists {
+			m.byMalID[anime.MalID] = idx
+		}
+	}
+}
+
+func (m *searchMerger) find(anime model.SearchAnime, title string) (int, bool) {
+	if anime.MalID != 0 {
+		if idx, ok := m.byMalID[anime.MalID]; ok {
+			return idx, true
+		}
+	}
+	for _, idx := range m.byTitle[title] {
+		group := m.data[idx]
+		if anime.MalID != 0 && group.MalID != 0 && anime.MalID != group.MalID {
+			continue
+		}
+		if anime.Year != 0 && group.Year != 0 && anime.Year != group.Year {
+			continue
+		}
+		if anime.Type != "" && group.Type != "" && !strings.EqualFold(anime.Type, group.Type) {
+			continue
+		}
+		return idx, true
+	}
+	return 0, false
+}
+
+func normalizeTitle(title string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range strings.ToLower(title) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if space && b.Len() > 0 {
+				b.WriteRune(' ')
+			}
+			b.WriteRune(r)
+			space = false
+			continue
+		}
+		space = true
+	}
+	return b.String()
+}
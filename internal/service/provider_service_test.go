@@ -0,0 +1,145 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/astanx/anime_api/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeTitle(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"", ""},
+		{"Naruto", "naruto"},
+		{"Attack on Titan", "attack on titan"},
+		{"  Attack   on\tTitan  ", "attack on titan"},
+		{"Re:Zero - Starting Life", "re zero starting life"},
+		{"Steins;Gate 0", "steins gate 0"},
+		{"!!!", ""},
+		{"Ван-Пис", "ван пис"},
+		{"ONE PIECE", "one piece"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, normalizeTitle(tt.title), tt.title)
+	}
+}
+
+func TestSearchMerger(t *testing.T) {
+	type entry struct {
+		provider string
+		anime    model.SearchAnime
+	}
+
+	tests := []struct {
+		name    string
+		entries []entry
+		want    []model.MergedSearchAnime
+	}{
+		{
+			name: "same MAL ID groups different titles",
+			entries: []entry{
+				{"mal", model.SearchAnime{ID: "21", MalID: 21, Title: "One Piece"}},
+				{"anilibria", model.SearchAnime{ID: "9000", MalID: 21, Title: "Ван-Пис"}},
+			},
+			want: []model.MergedSearchAnime{{
+				MalID: 21,
+				Title: "One Piece",
+				Providers: []model.ProviderAnime{
+					{Provider: "mal", ID: "21"},
+					{Provider: "anilibria", ID: "9000"},
+				},
+			}},
+		},
+		{
+			name: "normalized title groups and fills gaps",
+			entries: []entry{
+				{"consumet", model.SearchAnime{ID: "one-piece-100", Title: "One Piece!", Type: "TV"}},
+				{"mal", model.SearchAnime{ID: "21", MalID: 21, Title: "one piece", Year: 1999, Type: "tv", Poster: "poster"}},
+			},
+			want: []model.MergedSearchAnime{{
+				MalID:  21,
+				Title:  "One Piece!",
+				Poster: "poster",
+				Year:   1999,
+				Type:   "TV",
+				Providers: []model.ProviderAnime{
+					{Provider: "consumet", ID: "one-piece-100"},
+					{Provider: "mal", ID: "21"},
+				},
+			}},
+		},
+		{
+			name: "different MAL IDs stay apart",
+			entries: []entry{
+				{"mal", model.SearchAnime{ID: "1", MalID: 1, Title: "Hunter x Hunter"}},
+				{"mal", model.SearchAnime{ID: "11061", MalID: 11061, Title: "Hunter x Hunter"}},
+			},
+			want: []model.MergedSearchAnime{
+				{MalID: 1, Title: "Hunter x Hunter", Providers: []model.ProviderAnime{{Provider: "mal", ID: "1"}}},
+				{MalID: 11061, Title: "Hunter x Hunter", Providers: []model.ProviderAnime{{Provider: "mal", ID: "11061"}}},
+			},
+		},
+		{
+			name: "different years stay apart",
+			entries: []entry{
+				{"anilibria", model.SearchAnime{ID: "1", Title: "Fruits Basket", Year: 2001}},
+				{"consumet", model.SearchAnime{ID: "fb", Title: "Fruits Basket", Year: 2019}},
+			},
+			want: []model.MergedSearchAnime{
+				{Title: "Fruits Basket", Year: 2001, Providers: []model.ProviderAnime{{Provider: "anilibria", ID: "1"}}},
+				{Title: "Fruits Basket", Year: 2019, Providers: []model.ProviderAnime{{Provider: "consumet", ID: "fb"}}},
+			},
+		},
+		{
+			name: "different types stay apart",
+			entries: []entry{
+				{"consumet", model.SearchAnime{ID: "tv", Title: "Akira", Type: "TV"}},
+				{"consumet", model.SearchAnime{ID: "movie", Title: "Akira", Type: "Movie"}},
+			},
+			want: []model.MergedSearchAnime{
+				{Title: "Akira", Type: "TV", Providers: []model.ProviderAnime{{Provider: "consumet", ID: "tv"}}},
+				{Title: "Akira", Type: "Movie", Providers: []model.ProviderAnime{{Provider: "consumet", ID: "movie"}}},
+			},
+		},
+		{
+			name: "missing year and type match anything",
+			entries: []entry{
+				{"anilibria", model.SearchAnime{ID: "1", Title: "Akira", Year: 1988, Type: "Movie"}},
+				{"consumet", model.SearchAnime{ID: "akira", Title: "Akira"}},
+			},
+			want: []model.MergedSearchAnime{{
+				Title: "Akira",
+				Year:  1988,
+				Type:  "Movie",
+				Providers: []model.ProviderAnime{
+					{Provider: "anilibria", ID: "1"},
+					{Provider: "consumet", ID: "akira"},
+				},
+			}},
+		},
+		{
+			name: "empty titles are not grouped",
+			entries: []entry{
+				{"consumet", model.SearchAnime{ID: "a", Title: "???"}},
+				{"consumet", model.SearchAnime{ID: "b", Title: "!!!"}},
+			},
+			want: []model.MergedSearchAnime{
+				{Title: "???", Providers: []model.ProviderAnime{{Provider: "consumet", ID: "a"}}},
+				{Title: "!!!", Providers: []model.ProviderAnime{{Provider: "consumet", ID: "b"}}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newSearchMerger()
+			for _, e := range tt.entries {
+				m.add(e.provider, e.anime)
+			}
+			assert.Equal(t, tt.want, m.data)
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/astanx/anime_api/internal/model"
+	"github.com/astanx/anime_api/internal/repository"
+)
+
+// tombstoneRetention matches sync_prune_tombstones in 0007_sync. Cursors older
+// than this may have missed deletions and get a full resync.
+const tombstoneRetention = 30 * 24 * time.Hour
+
+var ErrInvalidCursor = errors.New("invalid sync cursor")
+
+type SyncService struct {
+	repo *repository.SyncRepo
+}
+
+func NewSyncService(repo *repository.SyncRepo) *SyncService {
+	return &SyncService{repo: repo}
+}
+
+// Changes returns what ownerKey's library changed since cursor, or the whole
+// library when cursor is empty, was issued for another library (the device
+// has since been linked or merged) or is too old.
+func (s *SyncService) Changes(ctx context.Context, ownerKey, cursor string) (model.SyncChanges, error) {
+	since, reset, err := resumeFrom(ownerKey, cursor, time.Now())
+	if err != nil {
+		return model.SyncChanges{}, err
+	}
+
+	changes, next, err := s.repo.Changes(ctx, ownerKey, since)
+	if err != nil {
+		return model.SyncChanges{}, err
+	}
+	changes.Reset = reset
+	changes.Cursor = syncCursor{
+		position: next,
+		issuedAt: time.Now(),
+		owner:    ownerTag(ownerKey),
+	}.encode()
+
+	return changes, nil
+}
+
+// resumeFrom returns the position to read ownerKey's changes from and
+// whether that is a full resync rather than a continuation of cursor.
+func resumeFrom(ownerKey, cursor string, now time.Time) (uint64, bool, error) {
+	if cursor == "" {
+		return 0, true, nil
+	}
+	c, err := decodeCursor(cursor)
+	if err != nil {
+		return 0, false, err
+	}
+	if c.owner != ownerTag(ownerKey) || now.Sub(c.issuedAt) >= tombstoneRetention {
+		return 0, true, nil
+	}
+	return c.position, false, nil
+}
+
+// syncCursor is handed to clients as an opaque string. It ties the position
+// to the library it was read from so a device that changes library starts
+// over.
+type syncCursor struct {
+	position uint64
+	issuedAt time.Time
+	owner    string
+}
+
+func (c syncCursor) encode() string {
+	raw := fmt.Sprintf("%d.%d.%s", c.position, c.issuedAt.Unix(), c.owner)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(s string) (syncCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return syncCursor{}, ErrInvalidCursor
+	}
+	parts := strings.Split(string(raw), ".")
+	if len(parts) != 3 {
+		return syncCursor{}, ErrInvalidCursor
+	}
+	position, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return syncCursor{}, ErrInvalidCursor
+	}
+	issuedAt, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return syncCursor{}, ErrInvalidCursor
+	}
+	return syncCursor{
+		position: position,
+		issuedAt: time.Unix(issuedAt, 0),
+		owner:    parts[2],
+	}, nil
+}
+
+func ownerTag(ownerKey string) string {
+	sum := sha256.Sum256([]byte(ownerKey))
+	return hex.EncodeToString(sum[:8])
+}
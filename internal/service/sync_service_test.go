@@ -0,0 +1,86 @@
+package service
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	c := syncCursor{
+		position: 1<<63 + 42,
+		issuedAt: time.Unix(1760000000, 0),
+		owner:    ownerTag("library"),
+	}
+
+	decoded, err := decodeCursor(c.encode())
+	require.NoError(t, err)
+	assert.Equal(t, c.position, decoded.position)
+	assert.True(t, c.issuedAt.Equal(decoded.issuedAt))
+	assert.Equal(t, c.owner, decoded.owner)
+}
+
+func TestDecodeCursorRejects(t *testing.T) {
+	encode := func(raw string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(raw))
+	}
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"not base64", "!!!"},
+		{"too few parts", encode("1.2")},
+		{"too many parts", encode("1.2.3.4")},
+		{"negative position", encode("-1.2.abc")},
+		{"non-numeric issued at", encode("1.x.abc")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decodeCursor(tt.cursor)
+			assert.ErrorIs(t, err, ErrInvalidCursor)
+		})
+	}
+}
+
+func TestOwnerTag(t *testing.T) {
+	assert.Equal(t, ownerTag("a"), ownerTag("a"))
+	assert.NotEqual(t, ownerTag("a"), ownerTag("b"))
+	assert.Len(t, ownerTag("a"), 16)
+}
+
+func TestResumeFrom(t *testing.T) {
+	now := time.Unix(1760000000, 0)
+	cursor := func(owner string, age time.Duration) string {
+		return syncCursor{position: 7, issuedAt: now.Add(-age), owner: ownerTag(owner)}.encode()
+	}
+
+	tests := []struct {
+		name   string
+		cursor string
+		since  uint64
+		reset  bool
+		err    error
+	}{
+		{name: "no cursor", reset: true},
+		{name: "same library", cursor: cursor("library", time.Hour), since: 7},
+		{name: "library changed", cursor: cursor("account", time.Hour), reset: true},
+		{name: "older than tombstones", cursor: cursor("library", tombstoneRetention), reset: true},
+		{name: "malformed", cursor: "!!!", err: ErrInvalidCursor},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			since, reset, err := resumeFrom("library", tt.cursor, now)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.since, since)
+			assert.Equal(t, tt.reset, reset)
+		})
+	}
+}
@@ -1,30 +1,83 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
 	"github.com/astanx/anime_api/internal/model"
 	"github.com/astanx/anime_api/internal/repository"
 )
 
+// recordBatchTimeout bounds recording the progress of a timecode batch. It
+// runs after the response, since an upload spanning many titles can need
+// more cold anime lookups than the request deadline leaves time for.
+const recordBatchTimeout = time.Minute
+
 type TimecodeService struct {
-	repo *repository.TimecodeRepo
+	repo     *repository.TimecodeRepo
+	progress *ProgressService
+}
+
+func NewTimecodeService(repo *repository.TimecodeRepo, progress *ProgressService) *TimecodeService {
+	return &TimecodeService{repo: repo, progress: progress}
 }
 
-func NewTimecodeService(repo *repository.TimecodeRepo) *TimecodeService {
-	return &TimecodeService{repo: repo}
+func (s *TimecodeService) GetAllTimecodes(ctx context.Context, deviceID string) ([]model.Timecode, error) {
+	return s.repo.GetAllTimecodes(ctx, deviceID)
 }
 
-func (s *TimecodeService) GetAllTimecodes(deviceID string) ([]model.Timecode, error) {
-	return s.repo.GetAllTimecodes(deviceID)
+func (s *TimecodeService) GetTimecode(ctx context.Context, deviceID, episodeID string) (*model.Timecode, error) {
+	return s.repo.GetTimecode(ctx, deviceID, episodeID)
 }
 
-func (s *TimecodeService) GetTimecode(deviceID, episodeID string) (*model.Timecode, error) {
-	return s.repo.GetTimecode(deviceID, episodeID)
+// AddOrUpdateTimecode saves timecode and, once the episode is watched,
+// advances history and the collection to match.
+func (s *TimecodeService) AddOrUpdateTimecode(ctx context.Context, deviceID string, timecode model.Timecode) error {
+	if err := s.repo.AddTimecode(ctx, deviceID, timecode); err != nil {
+		return err
+	}
+	// The timecode is saved, so a retry must not be asked for; progress
+	// catches up the next time the episode is saved.
+	if err := s.progress.Record(ctx, deviceID, timecode); err != nil {
+		slog.ErrorContext(ctx, "TimecodeService: failed to record progress", "episode_id", timecode.EpisodeID, "error", err)
+	}
+	return nil
 }
 
-func (s *TimecodeService) AddOrUpdateTimecode(deviceID string, timecode model.Timecode) error {
-	return s.repo.AddTimecode(deviceID, timecode)
+// ApplyTimecodes writes a batch of timecodes recorded offline. Newer stored
+// timecodes win over older ones from the batch.
+func (s *TimecodeService) ApplyTimecodes(ctx context.Context, deviceID string, timecodes []model.SyncTimecode) ([]model.BatchResult, error) {
+	validate := func(t *model.SyncTimecode) error {
+		if t.EpisodeID == "" || t.AnimeID == "" {
+			return errors.New("episode_id and anime_id are required")
+		}
+		return clientTime(&t.UpdatedAt)
+	}
+	return applyBatch(timecodes, validate, func(valid []model.SyncTimecode) ([]bool, error) {
+		applied, err := s.repo.ApplyTimecodes(ctx, deviceID, valid)
+		if err != nil {
+			return nil, err
+		}
+		recorded := make([]model.Timecode, 0, len(valid))
+		for i, ok := range applied {
+			if ok {
+				recorded = append(recorded, valid[i].Timecode)
+			}
+		}
+		// The batch is committed, so progress errors only get logged.
+		go func() {
+			ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), recordBatchTimeout)
+			defer cancel()
+			if err := s.progress.RecordBatch(ctx, deviceID, recorded); err != nil {
+				slog.ErrorContext(ctx, "TimecodeService: failed to record progress", "device_id", deviceID, "error", err)
+			}
+		}()
+		return applied, nil
+	})
 }
 
-func (s *TimecodeService) GetTimecodesForAnime(deviceID, animeID string) ([]model.Timecode, error) {
-	return s.repo.GetTimecodesForAnime(deviceID, animeID)
+func (s *TimecodeService) GetTimecodesForAnime(ctx context.Context, deviceID, animeID string) ([]model.Timecode, error) {
+	return s.repo.GetTimecodesForAnime(ctx, deviceID, animeID)
 }
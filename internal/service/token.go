@@ -0,0 +1,53 @@
+package service
+
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Token audiences keep account and device tokens, which share a secret, from
+// being accepted in place of each other.
+const (
+	accountAudience = "account"
+	deviceAudience  = "device"
+)
+
+// tokenClaims adds the token version of a device to the registered claims.
+// Account tokens leave it at zero.
+type tokenClaims struct {
+	jwt.RegisteredClaims
+	Version int `json:"ver,omitempty"`
+}
+
+func signToken(secret []byte, subject, audience string, version int, ttl time.Duration) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, tokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   subject,
+			Audience:  jwt.ClaimStrings{audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+		Version: version,
+	}).SignedString(secret)
+	return token, expiresAt, err
+}
+
+// parseToken checks the signature, expiry and audience of token and returns
+// its subject and version.
+func parseToken(secret []byte, token, audience string) (string, int, error) {
+	var claims tokenClaims
+	_, err := jwt.ParseWithClaims(token, &claims, func(*jwt.Token) (any, error) {
+		return secret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithExpirationRequired(),
+		jwt.WithAudience(audience),
+	)
+	if err != nil || claims.Subject == "" {
+		return "", 0, ErrInvalidToken
+	}
+	return claims.Subject, claims.Version, nil
+}
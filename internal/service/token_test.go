@@ -0,0 +1,60 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenRoundTrip(t *testing.T) {
+	secret := []byte("secret")
+
+	tests := []struct {
+		name     string
+		audience string
+		version  int
+	}{
+		{"device token before any refresh", deviceAudience, 0},
+		{"refreshed device token", deviceAudience, 3},
+		{"account token", accountAudience, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, _, err := signToken(secret, "subject", tt.audience, tt.version, time.Hour)
+			require.NoError(t, err)
+
+			subject, version, err := parseToken(secret, token, tt.audience)
+			require.NoError(t, err)
+			assert.Equal(t, "subject", subject)
+			assert.Equal(t, tt.version, version)
+		})
+	}
+}
+
+func TestParseTokenRejects(t *testing.T) {
+	secret := []byte("secret")
+	device, _, err := signToken(secret, "subject", deviceAudience, 1, time.Hour)
+	require.NoError(t, err)
+	expired, _, err := signToken(secret, "subject", deviceAudience, 1, -time.Minute)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		secret   []byte
+		token    string
+		audience string
+	}{
+		{"wrong audience", secret, device, accountAudience},
+		{"wrong secret", []byte("other"), device, deviceAudience},
+		{"expired", secret, expired, deviceAudience},
+		{"garbage", secret, "not-a-token", deviceAudience},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := parseToken(tt.secret, tt.token, tt.audience)
+			assert.ErrorIs(t, err, ErrInvalidToken)
+		})
+	}
+}
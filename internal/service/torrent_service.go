@@ -1,6 +1,8 @@
 package service
 
 import (
+	"context"
+
 	"github.com/astanx/anime_api/internal/model"
 	"github.com/astanx/anime_api/internal/repository"
 )
@@ -13,22 +15,22 @@ func NewTorrentService(repo *repository.TorrentRepo) *TorrentService {
 	return &TorrentService{repo: repo}
 }
 
-func (s *TorrentService) SearchMALAnime(query string, page int) (model.PaginatedSearchAnime, error) {
-	return s.repo.SearchMALAnime(query, page)
+func (s *TorrentService) SearchMALAnime(ctx context.Context, query string, page int) (model.PaginatedSearchAnime, error) {
+	return s.repo.SearchMALAnime(ctx, query, page)
 }
 
-func (s *TorrentService) SearchMALRecommendedAnime(limit, page int) ([]model.SearchAnime, error) {
-	return s.repo.SearchMALRecommendedAnime(limit, page)
+func (s *TorrentService) SearchMALRecommendedAnime(ctx context.Context, limit, page int) ([]model.SearchAnime, error) {
+	return s.repo.SearchMALRecommendedAnime(ctx, limit, page)
 }
 
-func (s *TorrentService) SearchMALLatestReleases(limit, page int) ([]model.SearchAnime, error) {
-	return s.repo.SearchMALLatestReleases(limit, page)
+func (s *TorrentService) SearchMALLatestReleases(ctx context.Context, limit, page int) ([]model.SearchAnime, error) {
+	return s.repo.SearchMALLatestReleases(ctx, limit, page)
 }
 
-func (s *TorrentService) SearchMALById(id string) (model.Anime, error) {
-	return s.repo.SearchMALById(id)
+func (s *TorrentService) SearchMALById(ctx context.Context, id string) (model.Anime, error) {
+	return s.repo.SearchMALById(ctx, id)
 }
 
-func (s *TorrentService) SearchMALByEpisodeId(animeId, episodeId string) (model.Episode, error) {
-	return s.repo.SearchMALByEpisodeId(animeId, episodeId)
+func (s *TorrentService) SearchMALByEpisodeId(ctx context.Context, animeId, episodeId string) (model.Episode, error) {
+	return s.repo.SearchMALByEpisodeId(ctx, animeId, episodeId)
 }
@@ -0,0 +1,101 @@
+package upstream
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBreakerOpensAfterThreshold(t *testing.T) {
+	b := newBreaker("example.com", 2, time.Hour)
+
+	b.failure()
+	_, err := b.allow()
+	require.NoError(t, err)
+
+	b.failure()
+	_, err = b.allow()
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+}
+
+func TestBreakerSingleProbe(t *testing.T) {
+	b := newBreaker("example.com", 1, 0)
+	b.failure()
+
+	probe, err := b.allow()
+	require.NoError(t, err)
+	assert.True(t, probe)
+
+	_, err = b.allow()
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+
+	b.success()
+	probe, err = b.allow()
+	require.NoError(t, err)
+	assert.False(t, probe)
+	assert.Equal(t, StateClosed, b.status().State)
+}
+
+func TestBreakerReleaseAdmitsNextProbe(t *testing.T) {
+	b := newBreaker("example.com", 1, 0)
+	b.failure()
+
+	probe, err := b.allow()
+	require.NoError(t, err)
+	b.release(probe)
+
+	probe, err = b.allow()
+	require.NoError(t, err)
+	assert.True(t, probe)
+	assert.Equal(t, StateHalfOpen, b.status().State)
+}
+
+func TestBreakerReleaseIgnoresNonProbe(t *testing.T) {
+	b := newBreaker("example.com", 1, 0)
+	b.failure()
+
+	probe, err := b.allow()
+	require.NoError(t, err)
+	b.release(false)
+
+	_, err = b.allow()
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	b.release(probe)
+}
+
+func TestGetReleasesCancelledProbe(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			<-r.Context().Done()
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	opts := DefaultOptions()
+	opts.DefaultPolicy.MaxRetries = 0
+	opts.BreakerThreshold = 1
+	opts.BreakerCooldown = time.Millisecond
+	c := NewClient(opts)
+
+	_, err := c.Get(context.Background(), srv.URL+"/fail")
+	require.Error(t, err)
+	b := c.breaker(Host(srv.URL))
+	require.Equal(t, StateOpen, b.status().State)
+	time.Sleep(2 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = c.Get(ctx, srv.URL+"/slow")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	probe, err := b.allow()
+	require.NoError(t, err)
+	assert.True(t, probe)
+}
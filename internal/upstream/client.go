@@ -0,0 +1,363 @@
+package upstream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/astanx/anime_api/internal/logging"
+	"github.com/astanx/anime_api/internal/metrics"
+	"github.com/astanx/anime_api/internal/ratelimit"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// HostPolicy controls how calls to a single upstream host are made.
+type HostPolicy struct {
+	// Name labels the host's metrics; the host itself is used when empty.
+	Name       string
+	Timeout    time.Duration
+	MaxRetries int
+	// RateLimit throttles outbound calls; requests over the limit are queued
+	// rather than rejected. Retries count against it too.
+	RateLimit []ratelimit.Rate
+}
+
+type Options struct {
+	DefaultPolicy    HostPolicy
+	Hosts            map[string]HostPolicy
+	BackoffBase      time.Duration
+	BackoffMax       time.Duration
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+func DefaultOptions() Options {
+	return Options{
+		DefaultPolicy: HostPolicy{
+			Timeout:    15 * time.Second,
+			MaxRetries: 2,
+		},
+		Hosts:            make(map[string]HostPolicy),
+		BackoffBase:      300 * time.Millisecond,
+		BackoffMax:       10 * time.Second,
+		BreakerThreshold: 5,
+		BreakerCooldown:  30 * time.Second,
+	}
+}
+
+type StatusError struct {
+	StatusCode int
+	Body       []byte
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("request failed with status %d", e.StatusCode)
+}
+
+var tracer = otel.Tracer("github.com/astanx/anime_api/internal/upstream")
+
+// Client is the shared HTTP client for upstream providers. Only GET requests
+// are made, so every call is safe to retry.
+type Client struct {
+	http *http.Client
+	opts Options
+
+	mu       sync.Mutex
+	breakers map[string]*breaker
+	limiters map[string]*limiter
+}
+
+func NewClient(opts Options) *Client {
+	if opts.Hosts == nil {
+		opts.Hosts = make(map[string]HostPolicy)
+	}
+	return &Client{
+		http:     &http.Client{},
+		opts:     opts,
+		breakers: make(map[string]*breaker),
+		limiters: make(map[string]*limiter),
+	}
+}
+
+var (
+	defaultMu     sync.RWMutex
+	defaultClient = NewClient(DefaultOptions())
+)
+
+// Default returns the process-wide client used by the repositories.
+func Default() *Client {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultClient
+}
+
+func SetDefault(c *Client) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultClient = c
+}
+
+// Host returns the host part of a URL, for keying HostPolicy entries.
+func Host(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+func (c *Client) policy(host string) HostPolicy {
+	if p, ok := c.opts.Hosts[host]; ok {
+		return p
+	}
+	return c.opts.DefaultPolicy
+}
+
+func (c *Client) breaker(host string) *breaker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	b, ok := c.breakers[host]
+	if !ok {
+		b = newBreaker(host, c.opts.BreakerThreshold, c.opts.BreakerCooldown)
+		c.breakers[host] = b
+	}
+	return b
+}
+
+func (c *Client) limiter(host string) *limiter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	l, ok := c.limiters[host]
+	if !ok {
+		l = newLimiter(c.policy(host).RateLimit)
+		c.limiters[host] = l
+	}
+	return l
+}
+
+// Breakers reports the circuit breaker state of every host called so far.
+func (c *Client) Breakers() []BreakerStatus {
+	c.mu.Lock()
+	breakers := make([]*breaker, 0, len(c.breakers))
+	for _, b := range c.breakers {
+		breakers = append(breakers, b)
+	}
+	c.mu.Unlock()
+
+	statuses := make([]BreakerStatus, 0, len(breakers))
+	for _, b := range breakers {
+		statuses = append(statuses, b.status())
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Host < statuses[j].Host
+	})
+	return statuses
+}
+
+// GetJSON fetches rawURL and decodes the JSON body into target. 429 and 5xx
+// responses and network errors are retried with jittered exponential
+// backoff, honouring Retry-After when the upstream sends it.
+func (c *Client) GetJSON(ctx context.Context, rawURL string, target any) error {
+	body, err := c.Get(ctx, rawURL)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, target)
+}
+
+func (c *Client) Get(ctx context.Context, rawURL string) (body []byte, err error) {
+	host := Host(rawURL)
+	policy := c.policy(host)
+	b := c.breaker(host)
+	l := c.limiter(host)
+
+	provider := policy.Name
+	if provider == "" {
+		provider = host
+	}
+
+	// The span only names the host: query strings may carry API keys.
+	ctx, span := tracer.Start(ctx, "upstream "+provider,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("upstream.provider", provider),
+			semconv.ServerAddress(host),
+		),
+	)
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	var lastErr error
+	for attempt := 0; attempt <= policy.MaxRetries; attempt++ {
+		probe, err := b.allow()
+		if err != nil {
+			metrics.UpstreamRequests.WithLabelValues(provider, "circuit_open").Inc()
+			return nil, fmt.Errorf("%s: %w", host, err)
+		}
+		waitStart := time.Now()
+		if err := l.wait(ctx); err != nil {
+			b.release(probe)
+			return nil, err
+		}
+		if waited := time.Since(waitStart); waited > time.Millisecond {
+			span.AddEvent("rate limited", trace.WithAttributes(attribute.Int64("upstream.wait_ms", waited.Milliseconds())))
+		}
+
+		start := time.Now()
+		body, retryAfter, err := c.attempt(ctx, rawURL, attempt, policy.Timeout)
+		metrics.UpstreamDuration.WithLabelValues(provider).Observe(time.Since(start).Seconds())
+		metrics.UpstreamRequests.WithLabelValues(provider, statusLabel(err)).Inc()
+		if err == nil {
+			b.success()
+			return body, nil
+		}
+		lastErr = err
+
+		// The caller gave up, which says nothing about the host.
+		if ctx.Err() != nil {
+			b.release(probe)
+			return nil, ctx.Err()
+		}
+
+		var statusErr *StatusError
+		isStatus := errors.As(err, &statusErr)
+		switch {
+		case isStatus && statusErr.StatusCode == http.StatusTooManyRequests:
+			// The host is alive, just busy; do not count towards the breaker.
+			b.success()
+		case isStatus && statusErr.StatusCode < 500:
+			b.success()
+			return nil, err
+		default:
+			b.failure()
+		}
+
+		if attempt == policy.MaxRetries {
+			break
+		}
+
+		wait := c.backoff(attempt)
+		if retryAfter > 0 {
+			wait = min(retryAfter, c.opts.BackoffMax)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	return nil, lastErr
+}
+
+func (c *Client) attempt(ctx context.Context, rawURL string, n int, timeout time.Duration) (body []byte, retryAfter time.Duration, err error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ctx, span := tracer.Start(ctx, "GET",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodGet,
+			semconv.ServerAddress(req.URL.Hostname()),
+			semconv.HTTPRequestResendCount(n),
+		),
+	)
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	req.Header.Set("Content-Type", "application/json")
+	if id := logging.RequestID(ctx); id != "" {
+		req.Header.Set(logging.RequestIDHeader, id)
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+
+	body, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		slog.WarnContext(ctx, "upstream request failed", "host", req.URL.Host, "status", resp.StatusCode, "body", string(body))
+		return nil, parseRetryAfter(resp.Header.Get("Retry-After")), &StatusError{StatusCode: resp.StatusCode, Body: body}
+	}
+
+	return body, 0, nil
+}
+
+func statusLabel(err error) string {
+	var statusErr *StatusError
+	switch {
+	case err == nil:
+		return strconv.Itoa(http.StatusOK)
+	case errors.As(err, &statusErr):
+		return strconv.Itoa(statusErr.StatusCode)
+	default:
+		return "error"
+	}
+}
+
+// backoff returns a full-jitter exponential delay for the given attempt.
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.opts.BackoffBase << attempt
+	if d <= 0 || d > c.opts.BackoffMax {
+		d = c.opts.BackoffMax
+	}
+	return time.Duration(rand.Int64N(int64(d)) + 1)
+}
+
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(time.Until(t), 0)
+	}
+	return 0
+}
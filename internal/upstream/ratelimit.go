@@ -0,0 +1,92 @@
+package upstream
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/astanx/anime_api/internal/ratelimit"
+)
+
+// bucket is a token bucket that refills Limit tokens every Per and holds at
+// most Limit tokens. Tokens may go negative: each caller reserves its token
+// up front and sleeps off the debt, so waiters are served in arrival order.
+type bucket struct {
+	capacity float64
+	perToken time.Duration
+	tokens   float64
+	last     time.Time
+}
+
+func newBucket(rate ratelimit.Rate) *bucket {
+	return &bucket{
+		capacity: float64(rate.Limit),
+		perToken: rate.Per / time.Duration(rate.Limit),
+		tokens:   float64(rate.Limit),
+		last:     time.Now(),
+	}
+}
+
+func (b *bucket) reserve(now time.Time) time.Duration {
+	b.tokens += float64(now.Sub(b.last)) / float64(b.perToken)
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens * float64(b.perToken))
+}
+
+// limiter combines several buckets for one host, e.g. Jikan's per-second and
+// per-minute limits. A call must fit every bucket.
+type limiter struct {
+	mu      sync.Mutex
+	buckets []*bucket
+}
+
+func newLimiter(rates []ratelimit.Rate) *limiter {
+	l := &limiter{}
+	for _, rate := range rates {
+		if rate.Limit > 0 && rate.Per > 0 {
+			l.buckets = append(l.buckets, newBucket(rate))
+		}
+	}
+	return l
+}
+
+// wait blocks until the call may go out, queueing behind earlier callers.
+// If ctx ends first the reserved tokens are handed back.
+func (l *limiter) wait(ctx context.Context) error {
+	if len(l.buckets) == 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	var delay time.Duration
+	for _, b := range l.buckets {
+		delay = max(delay, b.reserve(now))
+	}
+	l.mu.Unlock()
+
+	if delay == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		for _, b := range l.buckets {
+			b.tokens = min(b.tokens+1, b.capacity)
+		}
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}